@@ -20,6 +20,7 @@ import (
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/offchainlabs/nitro/arbos/arbosState"
 	"github.com/offchainlabs/nitro/arbos/retryables"
+	"github.com/offchainlabs/nitro/arbutil"
 	"github.com/offchainlabs/nitro/util/arbmath"
 	"github.com/offchainlabs/nitro/validator"
 	"github.com/pkg/errors"
@@ -86,6 +87,182 @@ func (a *ArbAPI) CheckPublisherHealth(ctx context.Context) error {
 	return a.txPublisher.CheckHealth(ctx)
 }
 
+type BatchCostsAPI struct {
+	tracker *BatchCostTracker
+}
+
+// BatchPostingCosts returns the recorded L1 costs and L1 pricing credits of the batches from fromBatch to toBatch inclusive
+func (a *BatchCostsAPI) BatchPostingCosts(ctx context.Context, fromBatch, toBatch uint64) ([]*BatchPostingCost, error) {
+	return a.tracker.GetBatchPostingCosts(fromBatch, toBatch)
+}
+
+type MessageArchiveAPI struct {
+	node   *Node
+	config func() *MessageArchiveConfig
+}
+
+// ExportBatches writes a message archive of the batches fromBatch to toBatch inclusive to a file in the configured export directory
+func (a *MessageArchiveAPI) ExportBatches(ctx context.Context, fromBatch, toBatch uint64, name string) (*MessageArchiveHeader, error) {
+	path, err := MessageArchiveExportPath(a.config().ExportDir, name)
+	if err != nil {
+		return nil, err
+	}
+	return ExportMessageArchiveFile(path, a.node.TxStreamer, a.node.InboxTracker, fromBatch, toBatch)
+}
+
+// VerifyAgainstL1 checks the node's latest batch and delayed message accumulators match L1
+func (a *MessageArchiveAPI) VerifyAgainstL1(ctx context.Context) error {
+	if a.node.InboxReader == nil {
+		return errors.New("node isn't reading L1")
+	}
+	return VerifyInboxAgainstL1(ctx, a.node.InboxTracker, a.node.InboxReader.sequencerInbox, a.node.InboxReader.delayedBridge)
+}
+
+type ArbBundleAPI struct {
+	publisher BundlePublisher
+}
+
+// SendBundle sequences the given transactions together in one block, or none of them.
+func (a *ArbBundleAPI) SendBundle(ctx context.Context, args BundleArgs) ([]common.Hash, error) {
+	txs, targetBlock, err := args.Decode()
+	if err != nil {
+		return nil, err
+	}
+	err = a.publisher.PublishBundle(ctx, txs, targetBlock)
+	if err != nil {
+		return nil, err
+	}
+	hashes := make([]common.Hash, 0, len(txs))
+	for _, tx := range txs {
+		hashes = append(hashes, tx.Hash())
+	}
+	return hashes, nil
+}
+
+type ArbTransactionAPI struct {
+	txPublisher TransactionPublisher
+}
+
+// SendRawTransactionConditional publishes a transaction that's only sequenced if the given conditions
+// hold for the block and state it would be sequenced on.
+func (a *ArbTransactionAPI) SendRawTransactionConditional(ctx context.Context, input hexutil.Bytes, options *arbutil.ConditionalOptions) (common.Hash, error) {
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(input); err != nil {
+		return common.Hash{}, err
+	}
+	if options != nil {
+		if err := options.Validate(); err != nil {
+			return common.Hash{}, err
+		}
+	}
+	if err := a.txPublisher.PublishTransaction(ctx, tx, options); err != nil {
+		return common.Hash{}, err
+	}
+	return tx.Hash(), nil
+}
+
+type ArbSyncTransactionAPI struct {
+	txPublisher TransactionPublisher
+	// the node's own RPC, so receipts are formatted by eth_getTransactionReceipt, with the Arbitrum fields it adds
+	rpcClient *rpc.Client
+	waiter    *txReceiptWaiter
+	timeout   time.Duration
+}
+
+// SendRawTransactionSync publishes a transaction and waits for it to be included in a block,
+// returning its receipt as eth_getTransactionReceipt would.
+func (a *ArbSyncTransactionAPI) SendRawTransactionSync(ctx context.Context, input hexutil.Bytes) (json.RawMessage, error) {
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(input); err != nil {
+		return nil, err
+	}
+	receipt, err := a.waiter.publishAndWait(ctx, a.txPublisher, tx, a.timeout)
+	if err != nil {
+		return nil, err
+	}
+	var fields struct {
+		BlockHash common.Hash `json:"blockHash"`
+	}
+	var result json.RawMessage
+	err = a.rpcClient.CallContext(ctx, &result, "eth_getTransactionReceipt", receipt.TxHash)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(result, &fields); err != nil || fields.BlockHash != receipt.BlockHash {
+		// the block including the transaction was reorged out since
+		return nil, fmt.Errorf("receipt of transaction %v in block %v no longer available", receipt.TxHash, receipt.BlockHash)
+	}
+	return result, nil
+}
+
+type PendingTransactionsAPI struct {
+	feed *PendingTxFeed
+}
+
+const pendingTxSubscriptionBuffer = 1024
+
+// ArbPendingTransactions subscribes to the transactions being sequenced, and their results.
+// It's reached through eth_subscribe("arbPendingTransactions").
+func (a *PendingTransactionsAPI) ArbPendingTransactions(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+	events, sub := a.feed.Subscribe(pendingTxSubscriptionBuffer)
+	go func() {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case event := <-events:
+				if err := notifier.Notify(rpcSub.ID, event); err != nil {
+					return
+				}
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
+
+type ReorgEventsAPI struct {
+	streamer *TransactionStreamer
+}
+
+const reorgSubscriptionBuffer = 64
+
+// ArbReorgs subscribes to the reorgs of the node's messages and blocks.
+// It's reached through eth_subscribe("arbReorgs").
+func (a *ReorgEventsAPI) ArbReorgs(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+	events, sub := a.streamer.SubscribeReorgEvents(reorgSubscriptionBuffer)
+	go func() {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case event := <-events:
+				if err := notifier.Notify(rpcSub.ID, event); err != nil {
+					return
+				}
+			case <-sub.Err():
+				return
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
+
 type ArbDebugAPI struct {
 	blockchain        *core.BlockChain
 	blockRangeBound   uint64
@@ -0,0 +1,85 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	flag "github.com/spf13/pflag"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+var (
+	batchCompressionLevelGauge      = metrics.NewRegisteredGauge("arb/batchposter/compression/level", nil)
+	batchCompressionSavedBytes      = metrics.NewRegisteredCounter("arb/batchposter/compression/saved", nil)
+	batchCompressionRecompressTimer = metrics.NewRegisteredTimer("arb/batchposter/compression/recompress", nil)
+)
+
+// BatchCompressionConfig controls adaptive compression: batches are built at the static compression level,
+// so whether they're full is judged at a level no higher than the one they're posted at,
+// then recompressed before posting at a higher level picked from how urgently the batch poster needs to catch up.
+type BatchCompressionConfig struct {
+	Adaptive           bool          `koanf:"adaptive" reload:"hot"`
+	MaxLevel           int           `koanf:"max-level" reload:"hot"`
+	BacklogThreshold   uint64        `koanf:"backlog-threshold" reload:"hot"`
+	PostDelayThreshold time.Duration `koanf:"post-delay-threshold" reload:"hot"`
+	HighL1BaseFeeGwei  uint64        `koanf:"high-l1-base-fee-gwei" reload:"hot"`
+}
+
+func (c *BatchCompressionConfig) Validate() error {
+	if !c.Adaptive {
+		return nil
+	}
+	if c.MaxLevel > brotli.BestCompression {
+		return errors.New("invalid adaptive compression max level")
+	}
+	if c.BacklogThreshold == 0 || c.PostDelayThreshold <= 0 {
+		return errors.New("adaptive compression thresholds must be positive")
+	}
+	return nil
+}
+
+func BatchCompressionConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".adaptive", DefaultBatchCompressionConfig.Adaptive, "pick the compression level from the backlog, time since the last post and L1 base fee instead of using compression-level")
+	f.Int(prefix+".max-level", DefaultBatchCompressionConfig.MaxLevel, "compression level used before posting when there's no backlog")
+	f.Uint64(prefix+".backlog-threshold", DefaultBatchCompressionConfig.BacklogThreshold, "number of unposted messages at which batches are posted at compression-level without recompressing")
+	f.Duration(prefix+".post-delay-threshold", DefaultBatchCompressionConfig.PostDelayThreshold, "time since the last post at which batches are posted at compression-level without recompressing")
+	f.Uint64(prefix+".high-l1-base-fee-gwei", DefaultBatchCompressionConfig.HighL1BaseFeeGwei, "L1 base fee at which calldata is expensive enough to favor compression over posting speed")
+}
+
+var DefaultBatchCompressionConfig = BatchCompressionConfig{
+	Adaptive:           false,
+	MaxLevel:           brotli.BestCompression,
+	BacklogThreshold:   5000,
+	PostDelayThreshold: time.Minute * 10,
+	HighL1BaseFeeGwei:  100,
+}
+
+// postingLevel returns the level to recompress at before posting a batch.
+// The more urgently we need to catch up, the closer it is to the static level the batch was built at.
+func (c *BatchCompressionConfig) postingLevel(staticLevel int, backlog uint64, sinceLastPost time.Duration, l1BaseFee *big.Int) int {
+	if !c.Adaptive {
+		return staticLevel
+	}
+	urgency := float64(backlog) / float64(c.BacklogThreshold)
+	if delayUrgency := float64(sinceLastPost) / float64(c.PostDelayThreshold); delayUrgency > urgency {
+		urgency = delayUrgency
+	}
+	if l1BaseFee != nil {
+		highBaseFee := new(big.Int).Mul(new(big.Int).SetUint64(c.HighL1BaseFeeGwei), big.NewInt(params.GWei))
+		if l1BaseFee.Cmp(highBaseFee) >= 0 {
+			// Calldata is expensive, so saving bytes is worth posting a bit slower
+			urgency /= 2
+		}
+	}
+	if urgency >= 1 || c.MaxLevel <= staticLevel {
+		return staticLevel
+	}
+	return c.MaxLevel - int(urgency*float64(c.MaxLevel-staticLevel))
+}
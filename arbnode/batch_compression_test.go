@@ -0,0 +1,91 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/params"
+
+	"github.com/offchainlabs/nitro/arbos"
+	"github.com/offchainlabs/nitro/arbstate"
+)
+
+func TestBatchCompressionPostingLevel(t *testing.T) {
+	config := DefaultBatchCompressionConfig
+	lowBaseFee := big.NewInt(params.GWei)
+	highBaseFee := new(big.Int).Mul(big.NewInt(200), big.NewInt(params.GWei))
+
+	if level := config.postingLevel(5, 10_000, time.Hour, lowBaseFee); level != 5 {
+		Fail(t, "static compression level not used when not adaptive, got", level)
+	}
+
+	config.Adaptive = true
+	Require(t, config.Validate())
+	if level := config.postingLevel(5, 0, 0, lowBaseFee); level != config.MaxLevel {
+		Fail(t, "expected max level without a backlog, got", level)
+	}
+	if level := config.postingLevel(5, config.BacklogThreshold, 0, lowBaseFee); level != 5 {
+		Fail(t, "expected static level with a full backlog, got", level)
+	}
+	if level := config.postingLevel(5, 0, config.PostDelayThreshold*2, lowBaseFee); level != 5 {
+		Fail(t, "expected static level when overdue to post, got", level)
+	}
+	halfBacklog := config.postingLevel(5, config.BacklogThreshold/2, 0, lowBaseFee)
+	if halfBacklog <= 5 || halfBacklog >= config.MaxLevel {
+		Fail(t, "expected an intermediate level with half a backlog, got", halfBacklog)
+	}
+	if level := config.postingLevel(5, config.BacklogThreshold/2, 0, highBaseFee); level <= halfBacklog {
+		Fail(t, "expected a high L1 base fee to favor compression, got", level, "vs", halfBacklog)
+	}
+}
+
+// fullTestBatch adds messages to a batch at the config's compression level until it's full
+func fullTestBatch(t *testing.T, config *BatchPosterConfig) *batchSegments {
+	t.Helper()
+	segments := newBatchSegments(0, config)
+	for i := 0; ; i++ {
+		success, err := segments.AddMessage(&arbstate.MessageWithMetadata{
+			Message: &arbos.L1IncomingMessage{
+				Header: &arbos.L1IncomingMessageHeader{
+					Kind:      arbos.L1MessageType_L2Message,
+					Timestamp: uint64(i),
+				},
+				L2msg: []byte(fmt.Sprintf("message %v to address %x", i, i*7919)),
+			},
+		})
+		Require(t, err)
+		if !success {
+			return segments
+		}
+	}
+}
+
+func TestRecompressForPostingNeverGrows(t *testing.T) {
+	config := TestBatchPosterConfig
+	config.MaxBatchSize = 2000
+	config.CompressionLevel = 2
+
+	// A full batch isn't recompressed at a lower level than it was built at, which could overflow it
+	lower := fullTestBatch(t, &config)
+	saved, err := lower.recompressForPosting(1)
+	Require(t, err)
+	if saved != 0 || lower.compressionLevel != 2 {
+		Fail(t, "batch was recompressed at a lower level", saved, lower.compressionLevel)
+	}
+	built, err := lower.CloseAndGetBytes()
+	Require(t, err)
+
+	higher := fullTestBatch(t, &config)
+	_, err = higher.recompressForPosting(11)
+	Require(t, err)
+	posted, err := higher.CloseAndGetBytes()
+	Require(t, err)
+	if len(posted) > len(built) || len(posted) > config.MaxBatchSize {
+		Fail(t, "recompressed batch grew from", len(built), "to", len(posted))
+	}
+}
@@ -0,0 +1,500 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/pkg/errors"
+	flag "github.com/spf13/pflag"
+
+	"github.com/offchainlabs/nitro/arbos"
+	"github.com/offchainlabs/nitro/arbos/util"
+	"github.com/offchainlabs/nitro/arbstate"
+	"github.com/offchainlabs/nitro/arbutil"
+	"github.com/offchainlabs/nitro/util/arbmath"
+	"github.com/offchainlabs/nitro/util/stopwaiter"
+)
+
+var (
+	batchCostsBatchGauge          = metrics.NewRegisteredGauge("arb/batchcosts/batch", nil)
+	batchCostsL1GasCounter        = metrics.NewRegisteredCounter("arb/batchcosts/l1/gas", nil)
+	batchCostsL1GweiCounter       = metrics.NewRegisteredCounter("arb/batchcosts/l1/gwei", nil)
+	batchCostsCreditedGweiCounter = metrics.NewRegisteredCounter("arb/batchcosts/credited/gwei", nil)
+	batchCostsBytesCounter        = metrics.NewRegisteredCounter("arb/batchcosts/bytes", nil)
+	batchCostsMessagesCounter     = metrics.NewRegisteredCounter("arb/batchcosts/messages", nil)
+	batchCostsTxsCounter          = metrics.NewRegisteredCounter("arb/batchcosts/txs", nil)
+)
+
+var ErrBatchCostNotFound = errors.New("batch posting cost not recorded")
+
+// the size of the header SequencerInboxBatch.Serialize puts before the batch data
+const sequencerMessageHeaderSize = 40
+
+// the most batches recorded in a single database batch
+const maxBatchCostsPerUpdate = 100
+
+type BatchCostTrackerConfig struct {
+	Enable        bool          `koanf:"enable"`
+	PollInterval  time.Duration `koanf:"poll-interval"`
+	MaxBatchRange uint64        `koanf:"max-batch-range"`
+}
+
+type BatchCostTrackerConfigFetcher func() *BatchCostTrackerConfig
+
+var DefaultBatchCostTrackerConfig = BatchCostTrackerConfig{
+	Enable:        false,
+	PollInterval:  10 * time.Second,
+	MaxBatchRange: 1000,
+}
+
+var TestBatchCostTrackerConfig = BatchCostTrackerConfig{
+	Enable:        true,
+	PollInterval:  10 * time.Millisecond,
+	MaxBatchRange: 1000,
+}
+
+func BatchCostTrackerConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", DefaultBatchCostTrackerConfig.Enable, "record the L1 cost and L1 pricing credit of every posted batch, and serve them over arb_batchPostingCosts")
+	f.Duration(prefix+".poll-interval", DefaultBatchCostTrackerConfig.PollInterval, "how often to look for newly posted batches")
+	f.Uint64(prefix+".max-batch-range", DefaultBatchCostTrackerConfig.MaxBatchRange, "maximum number of batches returned by a single arb_batchPostingCosts call")
+}
+
+// BatchPostingCost is what posting a batch cost on L1, and what ArbOS credited the batch poster for it
+type BatchPostingCost struct {
+	BatchNumber    uint64      `json:"batchNumber"`
+	Accumulator    common.Hash `json:"accumulator"`
+	L1Block        uint64      `json:"l1Block"`
+	L1TxHash       common.Hash `json:"l1TxHash"`
+	L1GasUsed      uint64      `json:"l1GasUsed"`
+	L1GasPrice     *big.Int    `json:"l1EffectiveGasPrice"`
+	L1Cost         *big.Int    `json:"l1Cost"`
+	DataLocation   string      `json:"dataLocation"`
+	DataBytes      uint64      `json:"dataBytes"` // the calldata, or the DAS certificate or external commitment posted in its place
+	L2Messages     uint64      `json:"l2Messages"`
+	L2Transactions uint64      `json:"l2Transactions"`
+
+	// The BatchPostingReport delayed message for this batch, if it has one
+	HasReport            bool     `json:"hasReport"`
+	ReportDelayedMessage uint64   `json:"reportDelayedMessage"`
+	ReportL2Block        uint64   `json:"reportL2Block"`
+	ReportedDataGas      uint64   `json:"reportedDataGas"`
+	ReportedL1BaseFee    *big.Int `json:"reportedL1BaseFee"`
+	// The spending ArbOS's L1 pricer credited the batch poster with, before any amortized cost cap
+	L1PricingCredit *big.Int `json:"l1PricingCredit"`
+}
+
+// BatchCostTracker follows the sequencer inbox and records the cost of each batch, from any poster, in the arbDb
+type BatchCostTracker struct {
+	stopwaiter.StopWaiter
+	db           ethdb.Database
+	config       BatchCostTrackerConfigFetcher
+	l1Client     arbutil.L1Interface
+	seqInbox     *SequencerInbox
+	inboxTracker *InboxTracker
+	txStreamer   *TransactionStreamer
+}
+
+func NewBatchCostTracker(db ethdb.Database, l1Client arbutil.L1Interface, seqInbox *SequencerInbox, inboxTracker *InboxTracker, txStreamer *TransactionStreamer, config BatchCostTrackerConfigFetcher) *BatchCostTracker {
+	return &BatchCostTracker{
+		db:           db,
+		config:       config,
+		l1Client:     l1Client,
+		seqInbox:     seqInbox,
+		inboxTracker: inboxTracker,
+		txStreamer:   txStreamer,
+	}
+}
+
+// RecordedBatchCount is the number of batches whose costs have been recorded
+func (t *BatchCostTracker) RecordedBatchCount() (uint64, error) {
+	hasKey, err := t.db.Has(batchCostCountKey)
+	if err != nil || !hasKey {
+		return 0, err
+	}
+	data, err := t.db.Get(batchCostCountKey)
+	if err != nil {
+		return 0, err
+	}
+	var count uint64
+	err = rlp.DecodeBytes(data, &count)
+	return count, err
+}
+
+func (t *BatchCostTracker) GetBatchPostingCost(batchNum uint64) (*BatchPostingCost, error) {
+	key := dbKey(batchCostPrefix, batchNum)
+	hasKey, err := t.db.Has(key)
+	if err != nil {
+		return nil, err
+	}
+	if !hasKey {
+		return nil, ErrBatchCostNotFound
+	}
+	data, err := t.db.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	var cost BatchPostingCost
+	err = rlp.DecodeBytes(data, &cost)
+	return &cost, err
+}
+
+// GetBatchPostingCosts returns the recorded costs of the batches from fromBatch to toBatch inclusive
+func (t *BatchCostTracker) GetBatchPostingCosts(fromBatch, toBatch uint64) ([]*BatchPostingCost, error) {
+	if toBatch < fromBatch {
+		return nil, fmt.Errorf("batch range end %v is before its start %v", toBatch, fromBatch)
+	}
+	maxRange := t.config().MaxBatchRange
+	if maxRange != 0 && toBatch-fromBatch >= maxRange {
+		return nil, fmt.Errorf("batch range %v to %v is longer than the maximum of %v", fromBatch, toBatch, maxRange)
+	}
+	count, err := t.RecordedBatchCount()
+	if err != nil {
+		return nil, err
+	}
+	costs := []*BatchPostingCost{}
+	for batchNum := fromBatch; batchNum <= toBatch && batchNum < count; batchNum++ {
+		cost, err := t.GetBatchPostingCost(batchNum)
+		if err != nil {
+			return nil, err
+		}
+		costs = append(costs, cost)
+	}
+	return costs, nil
+}
+
+func effectiveGasPrice(tx *types.Transaction, baseFee *big.Int) *big.Int {
+	if baseFee == nil {
+		return tx.GasPrice()
+	}
+	// legacy transactions have both caps set to their gas price, so this is their gas price too
+	return arbmath.BigMin(tx.GasFeeCap(), arbmath.BigAdd(baseFee, tx.GasTipCap()))
+}
+
+func batchDataLocationName(payload []byte) string {
+	if len(payload) == 0 {
+		return "none"
+	}
+	if arbstate.IsDASMessageHeaderByte(payload[0]) {
+		return BatchSinkDAS
+	}
+	if arbstate.IsExternalDAMessageHeaderByte(payload[0]) {
+		return BatchSinkExternal
+	}
+	return BatchSinkCalldata
+}
+
+// findReport returns the index of the BatchPostingReport delayed message for a batch, or false if it has none.
+// The sequencer inbox enqueues the report after the batch, in the same L1 transaction.
+func (t *BatchCostTracker) findReport(batchNum uint64, meta BatchMetadata) (uint64, bool, error) {
+	delayedCount, err := t.inboxTracker.GetDelayedCount()
+	if err != nil {
+		return 0, false, err
+	}
+	for seqNum := meta.DelayedMessageCount; seqNum < delayedCount; seqNum++ {
+		msg, err := t.inboxTracker.GetDelayedMessage(seqNum)
+		if err != nil {
+			return 0, false, err
+		}
+		if msg.Header.BlockNumber > meta.L1Block {
+			break
+		}
+		if msg.Header.Kind != arbos.L1MessageType_BatchPostingReport {
+			continue
+		}
+		_, _, _, reportBatchNum, _, err := arbos.ParseBatchPostingReportMessageFields(bytes.NewReader(msg.L2msg))
+		if err != nil {
+			log.Warn("failed to parse batch posting report", "delayedMessage", seqNum, "err", err)
+			continue
+		}
+		if reportBatchNum == batchNum {
+			return seqNum, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// findDelayedMessageIndex returns the index of the message sequencing the given delayed message, or false if it hasn't been sequenced
+func (t *BatchCostTracker) findDelayedMessageIndex(delayedSeqNum uint64, from arbutil.MessageIndex) (arbutil.MessageIndex, bool, error) {
+	msgCount, err := t.txStreamer.GetMessageCount()
+	if err != nil {
+		return 0, false, err
+	}
+	// binary search for the first message that read past the delayed message
+	low, high := from, msgCount
+	for low < high {
+		mid := low + (high-low)/2
+		msg, err := t.txStreamer.GetMessage(mid)
+		if err != nil {
+			return 0, false, err
+		}
+		if msg.DelayedMessagesRead > delayedSeqNum {
+			high = mid
+		} else {
+			low = mid + 1
+		}
+	}
+	return low, low < msgCount, nil
+}
+
+func (t *BatchCostTracker) getBlockForMessage(index arbutil.MessageIndex) (*types.Block, error) {
+	blockNum, err := t.txStreamer.MessageCountToBlockNumber(index + 1)
+	if err != nil {
+		return nil, err
+	}
+	return t.txStreamer.bc.GetBlockByNumber(uint64(blockNum)), nil
+}
+
+// fillInReport links the cost to the batch's BatchPostingReport, returning false if the report hasn't been executed yet
+func (t *BatchCostTracker) fillInReport(cost *BatchPostingCost, meta BatchMetadata) (bool, error) {
+	delayedSeqNum, found, err := t.findReport(cost.BatchNumber, meta)
+	if err != nil || !found {
+		return !found, err
+	}
+	msgIndex, sequenced, err := t.findDelayedMessageIndex(delayedSeqNum, meta.MessageCount)
+	if err != nil || !sequenced {
+		return false, err
+	}
+	block, err := t.getBlockForMessage(msgIndex)
+	if err != nil || block == nil {
+		return false, err
+	}
+	for _, tx := range block.Transactions() {
+		if tx.Type() != types.ArbitrumInternalTxType || len(tx.Data()) < 4 || !bytes.Equal(tx.Data()[:4], arbos.InternalTxBatchPostingReportMethodID[:]) {
+			continue
+		}
+		inputs, err := util.UnpackInternalTxDataBatchPostingReport(tx.Data())
+		if err != nil {
+			return false, err
+		}
+		if util.SafeMapGet[uint64](inputs, "batchNumber") != cost.BatchNumber {
+			continue
+		}
+		cost.HasReport = true
+		cost.ReportDelayedMessage = delayedSeqNum
+		cost.ReportL2Block = block.NumberU64()
+		cost.ReportedDataGas = util.SafeMapGet[uint64](inputs, "batchDataGas")
+		cost.ReportedL1BaseFee = util.SafeMapGet[*big.Int](inputs, "l1BaseFeeWei")
+		perBatchGas, err := t.perBatchGasCost(cost.BatchNumber, block)
+		if err != nil {
+			return false, err
+		}
+		gasCredited := arbmath.SaturatingAdd(perBatchGas, arbmath.SaturatingCast(cost.ReportedDataGas))
+		cost.L1PricingCredit = arbmath.BigMulByUint(cost.ReportedL1BaseFee, arbmath.SaturatingUCast(gasCredited))
+		return true, nil
+	}
+	return false, fmt.Errorf("L2 block %v sequencing the report for batch %v has no batch posting report transaction", block.NumberU64(), cost.BatchNumber)
+}
+
+// perBatchGasCost reads the L1 pricer's per batch gas cost from the state the report was executed on.
+// If that's been pruned it falls back to the latest state, which may have a different cost, and logs that it did.
+func (t *BatchCostTracker) perBatchGasCost(batchNum uint64, block *types.Block) (int64, error) {
+	bc := t.txStreamer.bc
+	state, _, err := stateAndHeader(bc, block.NumberU64()-1)
+	if err != nil {
+		log.Warn("state before batch posting report unavailable, crediting batch with the latest per batch gas cost", "batch", batchNum, "reportBlock", block.NumberU64(), "err", err)
+		state, _, err = stateAndHeader(bc, bc.CurrentHeader().Number.Uint64())
+		if err != nil {
+			return 0, err
+		}
+	}
+	return state.L1PricingState().PerBatchGasCost()
+}
+
+// computeCost returns the cost of a batch, or nil if the L2 blocks it depends on haven't been built yet
+func (t *BatchCostTracker) computeCost(ctx context.Context, batchNum uint64) (*BatchPostingCost, error) {
+	meta, err := t.inboxTracker.GetBatchMetadata(batchNum)
+	if err != nil {
+		return nil, err
+	}
+	var prevMeta BatchMetadata
+	if batchNum > 0 {
+		prevMeta, err = t.inboxTracker.GetBatchMetadata(batchNum - 1)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	l1Block := new(big.Int).SetUint64(meta.L1Block)
+	batches, err := t.seqInbox.LookupBatchesInRange(ctx, l1Block, l1Block)
+	if err != nil {
+		return nil, err
+	}
+	var batch *SequencerInboxBatch
+	for _, candidate := range batches {
+		if candidate.SequenceNumber == batchNum && candidate.AfterInboxAcc == meta.Accumulator {
+			batch = candidate
+			break
+		}
+	}
+	if batch == nil {
+		return nil, fmt.Errorf("batch %v not found in L1 block %v", batchNum, meta.L1Block)
+	}
+	data, err := batch.Serialize(ctx, t.l1Client)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < sequencerMessageHeaderSize {
+		return nil, fmt.Errorf("serialized batch %v is too short", batchNum)
+	}
+	payload := data[sequencerMessageHeaderSize:]
+
+	txHash := batch.rawLog.TxHash
+	receipt, err := t.l1Client.TransactionReceipt(ctx, txHash)
+	if err != nil {
+		return nil, err
+	}
+	tx, _, err := t.l1Client.TransactionByHash(ctx, txHash)
+	if err != nil {
+		return nil, err
+	}
+	header, err := t.l1Client.HeaderByHash(ctx, receipt.BlockHash)
+	if err != nil {
+		return nil, err
+	}
+	gasPrice := effectiveGasPrice(tx, header.BaseFee)
+
+	cost := &BatchPostingCost{
+		BatchNumber:       batchNum,
+		Accumulator:       meta.Accumulator,
+		L1Block:           meta.L1Block,
+		L1TxHash:          txHash,
+		L1GasUsed:         receipt.GasUsed,
+		L1GasPrice:        gasPrice,
+		L1Cost:            arbmath.BigMulByUint(gasPrice, receipt.GasUsed),
+		DataLocation:      batchDataLocationName(payload),
+		DataBytes:         uint64(len(payload)),
+		L2Messages:        uint64(meta.MessageCount - prevMeta.MessageCount),
+		ReportedL1BaseFee: new(big.Int),
+		L1PricingCredit:   new(big.Int),
+	}
+	for index := prevMeta.MessageCount; index < meta.MessageCount; index++ {
+		block, err := t.getBlockForMessage(index)
+		if err != nil || block == nil {
+			return nil, err
+		}
+		for _, tx := range block.Transactions() {
+			if tx.Type() != types.ArbitrumInternalTxType {
+				cost.L2Transactions++
+			}
+		}
+	}
+	ready, err := t.fillInReport(cost, meta)
+	if err != nil || !ready {
+		return nil, err
+	}
+	return cost, nil
+}
+
+// unwindReorgedCosts returns how many recorded costs are still for batches in the inbox tracker
+func (t *BatchCostTracker) unwindReorgedCosts(count uint64) (uint64, error) {
+	for count > 0 {
+		cost, err := t.GetBatchPostingCost(count - 1)
+		if err != nil {
+			return 0, err
+		}
+		meta, err := t.inboxTracker.GetBatchMetadata(count - 1)
+		if err == nil && meta.Accumulator == cost.Accumulator {
+			break
+		}
+		if err != nil && !errors.Is(err, AccumulatorNotFoundErr) {
+			return 0, err
+		}
+		count--
+	}
+	return count, nil
+}
+
+func (t *BatchCostTracker) update(ctx context.Context) error {
+	oldCount, err := t.RecordedBatchCount()
+	if err != nil {
+		return err
+	}
+	count, err := t.unwindReorgedCosts(oldCount)
+	if err != nil {
+		return err
+	}
+	if count < oldCount {
+		log.Warn("batch cost tracker unwinding reorged batches", "from", oldCount, "to", count)
+	}
+	batchCount, err := t.inboxTracker.GetBatchCount()
+	if err != nil {
+		return err
+	}
+
+	dbBatch := t.db.NewBatch()
+	err = deleteStartingAt(t.db, dbBatch, batchCostPrefix, uint64ToKey(count))
+	if err != nil {
+		return err
+	}
+	var recorded []*BatchPostingCost
+	for count < batchCount && len(recorded) < maxBatchCostsPerUpdate && ctx.Err() == nil {
+		cost, err := t.computeCost(ctx, count)
+		if err != nil {
+			return err
+		}
+		if cost == nil {
+			break
+		}
+		costBytes, err := rlp.EncodeToBytes(cost)
+		if err != nil {
+			return err
+		}
+		err = dbBatch.Put(dbKey(batchCostPrefix, count), costBytes)
+		if err != nil {
+			return err
+		}
+		recorded = append(recorded, cost)
+		count++
+	}
+	countData, err := rlp.EncodeToBytes(count)
+	if err != nil {
+		return err
+	}
+	err = dbBatch.Put(batchCostCountKey, countData)
+	if err != nil {
+		return err
+	}
+	err = dbBatch.Write()
+	if err != nil {
+		return err
+	}
+
+	gwei := big.NewInt(params.GWei)
+	for _, cost := range recorded {
+		batchCostsL1GasCounter.Inc(int64(cost.L1GasUsed))
+		batchCostsL1GweiCounter.Inc(new(big.Int).Div(cost.L1Cost, gwei).Int64())
+		batchCostsCreditedGweiCounter.Inc(new(big.Int).Div(cost.L1PricingCredit, gwei).Int64())
+		batchCostsBytesCounter.Inc(int64(cost.DataBytes))
+		batchCostsMessagesCounter.Inc(int64(cost.L2Messages))
+		batchCostsTxsCounter.Inc(int64(cost.L2Transactions))
+		log.Debug("recorded batch posting cost", "batch", cost.BatchNumber, "l1Cost", cost.L1Cost, "credited", cost.L1PricingCredit)
+	}
+	if count > 0 {
+		batchCostsBatchGauge.Update(int64(count - 1))
+	}
+	return nil
+}
+
+func (t *BatchCostTracker) Start(ctxIn context.Context) {
+	t.StopWaiter.Start(ctxIn, t)
+	t.CallIteratively(func(ctx context.Context) time.Duration {
+		err := t.update(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Warn("error recording batch posting costs", "err", err)
+		}
+		return t.config().PollInterval
+	})
+}
@@ -0,0 +1,246 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+
+	"github.com/offchainlabs/nitro/arbos"
+	"github.com/offchainlabs/nitro/arbos/util"
+	"github.com/offchainlabs/nitro/arbstate"
+)
+
+func TestBatchCostEffectiveGasPrice(t *testing.T) {
+	legacyTx := types.NewTx(&types.LegacyTx{GasPrice: big.NewInt(30)})
+	dynamicTx := types.NewTx(&types.DynamicFeeTx{GasFeeCap: big.NewInt(50), GasTipCap: big.NewInt(2)})
+	cases := []struct {
+		tx       *types.Transaction
+		baseFee  *big.Int
+		expected int64
+	}{
+		{legacyTx, nil, 30},
+		{legacyTx, big.NewInt(10), 30},
+		{dynamicTx, big.NewInt(10), 12},
+		{dynamicTx, big.NewInt(49), 50},
+	}
+	for i, c := range cases {
+		price := effectiveGasPrice(c.tx, c.baseFee)
+		if price.Int64() != c.expected {
+			Fail(t, "case", i, "got effective gas price", price, "expected", c.expected)
+		}
+	}
+}
+
+func TestBatchCostDataLocation(t *testing.T) {
+	cases := map[string][]byte{
+		"none":            {},
+		BatchSinkDAS:      {arbstate.DASMessageHeaderFlag},
+		BatchSinkExternal: {arbstate.ExternalDAMessageHeaderFlag + 3, 1, 2},
+		BatchSinkCalldata: {0, 1, 2},
+	}
+	for expected, payload := range cases {
+		if location := batchDataLocationName(payload); location != expected {
+			Fail(t, "payload", payload, "has location", location, "expected", expected)
+		}
+	}
+}
+
+func TestBatchPostingCostEncoding(t *testing.T) {
+	cost := &BatchPostingCost{
+		BatchNumber:          7,
+		Accumulator:          common.Hash{1},
+		L1Block:              100,
+		L1TxHash:             common.Hash{2},
+		L1GasUsed:            90000,
+		L1GasPrice:           big.NewInt(12),
+		L1Cost:               big.NewInt(90000 * 12),
+		DataLocation:         BatchSinkCalldata,
+		DataBytes:            1000,
+		L2Messages:           3,
+		L2Transactions:       5,
+		HasReport:            true,
+		ReportDelayedMessage: 4,
+		ReportL2Block:        9,
+		ReportedDataGas:      17000,
+		ReportedL1BaseFee:    big.NewInt(10),
+		L1PricingCredit:      big.NewInt(10 * (17000 + 100000)),
+	}
+	encoded, err := rlp.EncodeToBytes(cost)
+	Require(t, err)
+	var decoded BatchPostingCost
+	Require(t, rlp.DecodeBytes(encoded, &decoded))
+	if !reflect.DeepEqual(&decoded, cost) {
+		Fail(t, "batch posting cost changed when RLP encoded", decoded, "expected", cost)
+	}
+}
+
+// newBatchCostTrackerForTest returns a tracker following an inbox with batches 0 to 2 in L1 blocks 0 to 2
+func newBatchCostTrackerForTest(t *testing.T) (*BatchCostTracker, *InboxTracker) {
+	streamer, inbox := newInboxForArchiveTest(t, true)
+	config := TestBatchCostTrackerConfig
+	tracker := NewBatchCostTracker(rawdb.NewMemoryDatabase(), nil, nil, inbox, streamer, func() *BatchCostTrackerConfig { return &config })
+	return tracker, inbox
+}
+
+// recordTestCosts records a cost for each of the first count batches in the inbox, as update would
+func recordTestCosts(t *testing.T, tracker *BatchCostTracker, count uint64) {
+	for batchNum := uint64(0); batchNum < count; batchNum++ {
+		meta, err := tracker.inboxTracker.GetBatchMetadata(batchNum)
+		Require(t, err)
+		costBytes, err := rlp.EncodeToBytes(&BatchPostingCost{
+			BatchNumber:       batchNum,
+			Accumulator:       meta.Accumulator,
+			L1Block:           meta.L1Block,
+			L1GasPrice:        new(big.Int),
+			L1Cost:            new(big.Int),
+			ReportedL1BaseFee: new(big.Int),
+			L1PricingCredit:   new(big.Int),
+		})
+		Require(t, err)
+		Require(t, tracker.db.Put(dbKey(batchCostPrefix, batchNum), costBytes))
+	}
+	countBytes, err := rlp.EncodeToBytes(count)
+	Require(t, err)
+	Require(t, tracker.db.Put(batchCostCountKey, countBytes))
+}
+
+// addTestReports adds delayed messages after the inbox's existing ones, each enqueued in the given L1 block
+func addTestReports(t *testing.T, inbox *InboxTracker, l1Blocks []uint64, l2msgs [][]byte) {
+	count, err := inbox.GetDelayedCount()
+	Require(t, err)
+	acc, err := inbox.GetDelayedAcc(count - 1)
+	Require(t, err)
+	var messages []*DelayedInboxMessage
+	for i, l2msg := range l2msgs {
+		requestId := common.BigToHash(new(big.Int).SetUint64(count + uint64(i)))
+		message := &DelayedInboxMessage{
+			BeforeInboxAcc: acc,
+			Message: &arbos.L1IncomingMessage{
+				Header: &arbos.L1IncomingMessageHeader{
+					Kind:        arbos.L1MessageType_BatchPostingReport,
+					BlockNumber: l1Blocks[i],
+					RequestId:   &requestId,
+					L1BaseFee:   common.Big0,
+				},
+				L2msg: l2msg,
+			},
+		}
+		acc = message.AfterInboxAcc()
+		messages = append(messages, message)
+	}
+	Require(t, inbox.AddDelayedMessages(messages, false))
+}
+
+func batchPostingReportForTest(t *testing.T, batchNum uint64) []byte {
+	var report bytes.Buffer
+	Require(t, util.HashToWriter(common.BigToHash(big.NewInt(1000)), &report))
+	Require(t, util.AddressToWriter(common.Address{1}, &report))
+	Require(t, util.HashToWriter(common.Hash{2}, &report))
+	Require(t, util.HashToWriter(common.BigToHash(new(big.Int).SetUint64(batchNum)), &report))
+	Require(t, util.HashToWriter(common.BigToHash(big.NewInt(10)), &report))
+	return report.Bytes()
+}
+
+func TestBatchCostFindReport(t *testing.T) {
+	tracker, inbox := newBatchCostTrackerForTest(t)
+	// delayed messages 2 to 4: an unparsable report, then the reports for batches 1 and 2
+	addTestReports(t, inbox, []uint64{1, 1, 2}, [][]byte{{1, 2, 3}, batchPostingReportForTest(t, 1), batchPostingReportForTest(t, 2)})
+	meta1, err := inbox.GetBatchMetadata(1)
+	Require(t, err)
+	meta2, err := inbox.GetBatchMetadata(2)
+	Require(t, err)
+
+	cases := []struct {
+		batchNum uint64
+		meta     BatchMetadata
+		found    bool
+		report   uint64
+	}{
+		{1, meta1, true, 3},
+		{2, meta2, true, 4},
+		// the report is enqueued in the batch's L1 transaction, so later L1 blocks aren't searched
+		{2, meta1, false, 0},
+		{5, meta2, false, 0},
+	}
+	for i, c := range cases {
+		report, found, err := tracker.findReport(c.batchNum, c.meta)
+		Require(t, err)
+		if found != c.found || report != c.report {
+			Fail(t, "case", i, "found report", report, found, "expected", c.report, c.found)
+		}
+	}
+}
+
+func TestBatchCostFillInUnexecutedReport(t *testing.T) {
+	tracker, inbox := newBatchCostTrackerForTest(t)
+	addTestReports(t, inbox, []uint64{1}, [][]byte{batchPostingReportForTest(t, 1)})
+
+	// batch 0 has no report, so its cost is complete without one
+	meta0, err := inbox.GetBatchMetadata(0)
+	Require(t, err)
+	cost := &BatchPostingCost{BatchNumber: 0}
+	ready, err := tracker.fillInReport(cost, meta0)
+	Require(t, err)
+	if !ready || cost.HasReport {
+		Fail(t, "batch without a report wasn't ready", ready, cost.HasReport)
+	}
+
+	// batch 1's report hasn't been sequenced, so its cost must wait for it
+	meta1, err := inbox.GetBatchMetadata(1)
+	Require(t, err)
+	cost = &BatchPostingCost{BatchNumber: 1}
+	ready, err = tracker.fillInReport(cost, meta1)
+	Require(t, err)
+	if ready || cost.HasReport {
+		Fail(t, "batch with an unsequenced report was ready", ready, cost.HasReport)
+	}
+}
+
+func TestBatchCostUnwindReorg(t *testing.T) {
+	ctx := context.Background()
+	tracker, inbox := newBatchCostTrackerForTest(t)
+	recordTestCosts(t, tracker, 3)
+	count, err := tracker.unwindReorgedCosts(3)
+	Require(t, err)
+	if count != 3 {
+		Fail(t, "unwound", 3-count, "costs without a reorg")
+	}
+
+	// batches 1 and 2 are reorged out, and a different batch 1 is posted in their place
+	Require(t, inbox.ReorgBatchesTo(1))
+	delayedAcc, err := inbox.GetDelayedAcc(1)
+	Require(t, err)
+	serialized := make([]byte, 40)
+	binary.BigEndian.PutUint64(serialized[32:], 2)
+	Require(t, inbox.AddSequencerBatches(ctx, nil, []*SequencerInboxBatch{{
+		BlockNumber:       3,
+		SequenceNumber:    1,
+		BeforeInboxAcc:    common.Hash{1},
+		AfterInboxAcc:     common.Hash{9},
+		AfterDelayedAcc:   delayedAcc,
+		AfterDelayedCount: 2,
+		serialized:        serialized,
+	}}))
+
+	count, err = tracker.unwindReorgedCosts(3)
+	Require(t, err)
+	if count != 1 {
+		Fail(t, "kept", count, "costs after a reorg back to batch 1")
+	}
+	cost, err := tracker.GetBatchPostingCost(0)
+	Require(t, err)
+	if cost.Accumulator != (common.Hash{1}) {
+		Fail(t, "unexpected cost kept for batch 0", cost)
+	}
+}
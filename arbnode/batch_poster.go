@@ -8,7 +8,10 @@ import (
 	"context"
 	"encoding/hex"
 	"fmt"
+	"math"
 	"math/big"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/andybalholm/brotli"
@@ -19,6 +22,7 @@ import (
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/rlp"
 
@@ -26,7 +30,6 @@ import (
 	"github.com/offchainlabs/nitro/arbos"
 	"github.com/offchainlabs/nitro/arbstate"
 	"github.com/offchainlabs/nitro/arbutil"
-	"github.com/offchainlabs/nitro/das"
 	"github.com/offchainlabs/nitro/solgen/go/bridgegen"
 	"github.com/offchainlabs/nitro/util/headerreader"
 	"github.com/offchainlabs/nitro/util/redisutil"
@@ -50,10 +53,19 @@ type BatchPoster struct {
 	seqInboxABI  *abi.ABI
 	seqInboxAddr common.Address
 	building     *buildingBatch
-	daWriter     das.DataAvailabilityServiceWriter
+	sink         BatchSink
 	dataPoster   *dataposter.DataPoster[batchPosterPosition]
 	redisLock    *SimpleRedisLock
 	firstAccErr  time.Time // first time a continuous missing accumulator occurred
+	lastPostTime time.Time
+
+	paused      int32 // atomic
+	forcePost   int32 // atomic
+	statusMutex sync.Mutex
+	status      batchPosterStatus
+
+	// in dry run mode, the position after the last batch written, as nothing is posted to L1
+	dryRunPosition *batchPosterPosition
 }
 
 type BatchPosterConfig struct {
@@ -64,12 +76,15 @@ type BatchPosterConfig struct {
 	BatchPollDelay                     time.Duration               `koanf:"poll-delay" reload:"hot"`
 	PostingErrorDelay                  time.Duration               `koanf:"error-delay" reload:"hot"`
 	CompressionLevel                   int                         `koanf:"compression-level" reload:"hot"`
+	Compression                        BatchCompressionConfig      `koanf:"compression" reload:"hot"`
 	DASRetentionPeriod                 time.Duration               `koanf:"das-retention-period" reload:"hot"`
 	GasRefunderAddress                 string                      `koanf:"gas-refunder-address" reload:"hot"`
 	DataPoster                         dataposter.DataPosterConfig `koanf:"data-poster" reload:"hot"`
 	RedisUrl                           string                      `koanf:"redis-url"`
 	RedisLock                          SimpleRedisLockConfig       `koanf:"redis-lock" reload:"hot"`
 	ExtraBatchGas                      uint64                      `koanf:"extra-batch-gas" reload:"hot"`
+	DryRun                             BatchPosterDryRunConfig     `koanf:"dry-run"`
+	Sink                               string                      `koanf:"sink"`
 }
 
 func (c *BatchPosterConfig) Validate() error {
@@ -79,6 +94,17 @@ func (c *BatchPosterConfig) Validate() error {
 	if c.MaxBatchSize <= 40 {
 		return errors.New("MaxBatchSize too small")
 	}
+	if err := c.Compression.Validate(); err != nil {
+		return err
+	}
+	if err := c.DryRun.Validate(); err != nil {
+		return err
+	}
+	switch c.Sink {
+	case BatchSinkAuto, BatchSinkCalldata, BatchSinkDAS, BatchSinkExternal:
+	default:
+		return fmt.Errorf("unknown batch poster sink \"%v\"", c.Sink)
+	}
 	return nil
 }
 
@@ -86,18 +112,21 @@ type BatchPosterConfigFetcher func() *BatchPosterConfig
 
 func BatchPosterConfigAddOptions(prefix string, f *flag.FlagSet) {
 	f.Bool(prefix+".enable", DefaultBatchPosterConfig.Enable, "enable posting batches to l1")
-	f.Bool(prefix+".disable-das-fallback-store-data-on-chain", DefaultBatchPosterConfig.DisableDasFallbackStoreDataOnChain, "If unable to batch to DAS, disable fallback storing data on chain")
+	f.Bool(prefix+".disable-das-fallback-store-data-on-chain", DefaultBatchPosterConfig.DisableDasFallbackStoreDataOnChain, "If unable to batch to DAS or an external data availability layer, disable fallback storing data on chain")
 	f.Int(prefix+".max-size", DefaultBatchPosterConfig.MaxBatchSize, "maximum batch size")
 	f.Duration(prefix+".max-interval", DefaultBatchPosterConfig.MaxBatchPostInterval, "maximum batch posting interval")
 	f.Duration(prefix+".poll-delay", DefaultBatchPosterConfig.BatchPollDelay, "how long to delay after successfully posting batch")
 	f.Duration(prefix+".error-delay", DefaultBatchPosterConfig.PostingErrorDelay, "how long to delay after error posting batch")
 	f.Int(prefix+".compression-level", DefaultBatchPosterConfig.CompressionLevel, "batch compression level")
+	BatchCompressionConfigAddOptions(prefix+".compression", f)
 	f.Duration(prefix+".das-retention-period", DefaultBatchPosterConfig.DASRetentionPeriod, "In AnyTrust mode, the period which DASes are requested to retain the stored batches.")
 	f.String(prefix+".gas-refunder-address", DefaultBatchPosterConfig.GasRefunderAddress, "The gas refunder contract address (optional)")
 	f.Uint64(prefix+".extra-batch-gas", DefaultBatchPosterConfig.ExtraBatchGas, "use this much more gas than estimation says is necessary to post batches")
 	f.String(prefix+".redis-url", DefaultBatchPosterConfig.RedisUrl, "if non-empty, the Redis URL to store queued transactions in")
 	RedisLockConfigAddOptions(prefix+".redis-lock", f)
-	dataposter.DataPosterConfigAddOptions(prefix+".data-poster", f)
+	BatchPosterDryRunConfigAddOptions(prefix+".dry-run", f)
+	f.String(prefix+".sink", DefaultBatchPosterConfig.Sink, "where to publish batch data: \"calldata\", \"das\", \"external\" (EXPERIMENTAL, uses node.external-da), or \"auto\" to use the DAS if enabled")
+	dataposter.DataPosterConfigAddOptions(prefix+".data-poster", f, DefaultBatchPosterConfig.DataPoster)
 }
 
 var DefaultBatchPosterConfig = BatchPosterConfig{
@@ -108,10 +137,13 @@ var DefaultBatchPosterConfig = BatchPosterConfig{
 	PostingErrorDelay:                  time.Second * 10,
 	MaxBatchPostInterval:               time.Hour,
 	CompressionLevel:                   brotli.DefaultCompression,
+	Compression:                        DefaultBatchCompressionConfig,
 	DASRetentionPeriod:                 time.Hour * 24 * 15,
 	GasRefunderAddress:                 "",
 	ExtraBatchGas:                      50_000,
 	DataPoster:                         dataposter.DefaultDataPosterConfig,
+	DryRun:                             DefaultBatchPosterDryRunConfig,
+	Sink:                               BatchSinkAuto,
 }
 
 var TestBatchPosterConfig = BatchPosterConfig{
@@ -121,13 +153,16 @@ var TestBatchPosterConfig = BatchPosterConfig{
 	PostingErrorDelay:    time.Millisecond * 10,
 	MaxBatchPostInterval: 0,
 	CompressionLevel:     2,
+	Compression:          DefaultBatchCompressionConfig,
 	DASRetentionPeriod:   time.Hour * 24 * 15,
 	GasRefunderAddress:   "",
 	ExtraBatchGas:        10_000,
 	DataPoster:           dataposter.TestDataPosterConfig,
+	DryRun:               DefaultBatchPosterDryRunConfig,
+	Sink:                 BatchSinkAuto,
 }
 
-func NewBatchPoster(l1Reader *headerreader.HeaderReader, inbox *InboxTracker, streamer *TransactionStreamer, syncMonitor *SyncMonitor, config BatchPosterConfigFetcher, contractAddress common.Address, transactOpts *bind.TransactOpts, daWriter das.DataAvailabilityServiceWriter) (*BatchPoster, error) {
+func NewBatchPoster(dataPosterDB ethdb.Database, l1Reader *headerreader.HeaderReader, inbox *InboxTracker, streamer *TransactionStreamer, syncMonitor *SyncMonitor, config BatchPosterConfigFetcher, contractAddress common.Address, transactOpts *bind.TransactOpts, sink BatchSink) (*BatchPoster, error) {
 	seqInbox, err := bridgegen.NewSequencerInbox(contractAddress, l1Reader.Client())
 	if err != nil {
 		return nil, err
@@ -150,6 +185,9 @@ func NewBatchPoster(l1Reader *headerreader.HeaderReader, inbox *InboxTracker, st
 	if err != nil {
 		return nil, err
 	}
+	if sink == nil {
+		sink = CalldataBatchSink{}
+	}
 	b := &BatchPoster{
 		l1Reader:     l1Reader,
 		inbox:        inbox,
@@ -159,19 +197,34 @@ func NewBatchPoster(l1Reader *headerreader.HeaderReader, inbox *InboxTracker, st
 		seqInbox:     seqInbox,
 		seqInboxABI:  seqInboxABI,
 		seqInboxAddr: contractAddress,
-		daWriter:     daWriter,
+		sink:         sink,
 		redisLock:    redisLock,
+		lastPostTime: time.Now(),
 	}
 	dataPosterConfigFetcher := func() *dataposter.DataPosterConfig {
 		return &config().DataPoster
 	}
-	b.dataPoster, err = dataposter.NewDataPoster(l1Reader, transactOpts, redisClient, redisLock, dataPosterConfigFetcher, b.getBatchPosterPosition)
+	b.dataPoster, err = dataposter.NewDataPoster(dataPosterDB, l1Reader, transactOpts, redisClient, redisLock, dataPosterConfigFetcher, b.getBatchPosterPosition)
 	if err != nil {
 		return nil, err
 	}
+	b.dataPoster.SetUrgencyWindowFetcher(b.forceInclusionWindow)
 	return b, nil
 }
 
+// forceInclusionWindow is how long after their messages batches must be posted, before delayed messages may be force included
+func (b *BatchPoster) forceInclusionWindow(ctx context.Context) (time.Duration, error) {
+	maxTimeVariation, err := b.seqInbox.MaxTimeVariation(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		return 0, err
+	}
+	delaySeconds := maxTimeVariation.DelaySeconds
+	if !delaySeconds.IsInt64() || delaySeconds.Int64() > int64(math.MaxInt64/time.Second) {
+		return 0, fmt.Errorf("sequencer inbox delay %v seconds is out of range", delaySeconds)
+	}
+	return time.Duration(delaySeconds.Int64()) * time.Second, nil
+}
+
 func (b *BatchPoster) getBatchPosterPosition(ctx context.Context, blockNum *big.Int) (batchPosterPosition, error) {
 	bigInboxBatchCount, err := b.seqInbox.BatchCount(&bind.CallOpts{Context: ctx, BlockNumber: blockNum})
 	if err != nil {
@@ -292,6 +345,39 @@ func (s *batchSegments) close() error {
 	return nil
 }
 
+// recompressForPosting closes the batch and recompresses it at the given level if it's higher than the one it was built at,
+// keeping the existing compression if the new level doesn't make it smaller.
+// The batch never grows, so it stays within the size limit it was judged full against.
+// Returns the number of bytes saved by recompressing.
+func (s *batchSegments) recompressForPosting(level int) (int, error) {
+	if !s.isDone {
+		if err := s.close(); err != nil {
+			return 0, err
+		}
+	}
+	if len(s.rawSegments) == 0 || level <= s.compressionLevel {
+		return 0, nil
+	}
+	if err := s.compressedWriter.Flush(); err != nil {
+		return 0, err
+	}
+	prevSize := s.compressedBuffer.Len()
+	prevLevel := s.compressionLevel
+	s.compressionLevel = level
+	if err := s.recompressAll(); err != nil {
+		return 0, err
+	}
+	if err := s.compressedWriter.Flush(); err != nil {
+		return 0, err
+	}
+	newSize := s.compressedBuffer.Len()
+	if newSize >= prevSize {
+		s.compressionLevel = prevLevel
+		return 0, s.recompressAll()
+	}
+	return prevSize - newSize, nil
+}
+
 func (s *batchSegments) addSegmentToCompressed(segment []byte) error {
 	encoded, err := rlp.EncodeToBytes(segment)
 	if err != nil {
@@ -470,8 +556,15 @@ func (b *BatchPoster) estimateGas(ctx context.Context, sequencerMessage []byte,
 	return gas + b.config().ExtraBatchGas, nil
 }
 
+func (b *BatchPoster) getNextNonceAndMeta(ctx context.Context) (uint64, batchPosterPosition, error) {
+	if b.dryRunPosition != nil {
+		return 0, *b.dryRunPosition, nil
+	}
+	return b.dataPoster.GetNextNonceAndMeta(ctx)
+}
+
 func (b *BatchPoster) maybePostSequencerBatch(ctx context.Context) (bool, error) {
-	nonce, batchPosition, err := b.dataPoster.GetNextNonceAndMeta(ctx)
+	nonce, batchPosition, err := b.getNextNonceAndMeta(ctx)
 	if err != nil {
 		return false, err
 	}
@@ -487,8 +580,15 @@ func (b *BatchPoster) maybePostSequencerBatch(ctx context.Context) (bool, error)
 	if err != nil {
 		return false, err
 	}
+	b.updateStatus(func(status *batchPosterStatus) {
+		status.position = &batchPosition
+	})
 	if msgCount <= batchPosition.MessageCount {
 		// There's nothing after the newest batch, therefore batch posting was not required
+		atomic.StoreInt32(&b.forcePost, 0)
+		b.updateStatus(func(status *batchPosterStatus) {
+			status.building = nil
+		})
 		return false, nil
 	}
 	firstMsg, err := b.streamer.GetMessage(batchPosition.MessageCount)
@@ -498,7 +598,7 @@ func (b *BatchPoster) maybePostSequencerBatch(ctx context.Context) (bool, error)
 	nextMessageTime := time.Unix(int64(firstMsg.Message.Header.Timestamp), 0)
 
 	config := b.config()
-	forcePostBatch := time.Since(nextMessageTime) >= config.MaxBatchPostInterval
+	forcePostBatch := time.Since(nextMessageTime) >= config.MaxBatchPostInterval || b.forcePostPending()
 	haveUsefulMessage := false
 
 	for b.building.msgCount < msgCount {
@@ -524,12 +624,40 @@ func (b *BatchPoster) maybePostSequencerBatch(ctx context.Context) (bool, error)
 		}
 		b.building.msgCount++
 	}
+	b.updateStatus(func(status *batchPosterStatus) {
+		status.building = &BuildingBatchStatus{
+			FromMessage:      b.building.startMsgCount,
+			ToMessage:        b.building.msgCount,
+			Segments:         len(b.building.segments.rawSegments),
+			EstimatedSize:    b.building.segments.lastCompressedSize + b.building.segments.newUncompressedSize,
+			firstMessageTime: nextMessageTime,
+		}
+	})
 
 	if !forcePostBatch || !haveUsefulMessage {
 		// the batch isn't full yet and we've posted a batch recently
 		// don't post anything for now
 		return false, nil
 	}
+	var l1BaseFee *big.Int
+	if config.Compression.Adaptive {
+		header, err := b.l1Reader.LastHeader(ctx)
+		if err != nil {
+			return false, err
+		}
+		l1BaseFee = header.BaseFee
+	}
+	backlog := uint64(msgCount - batchPosition.MessageCount)
+	compressionLevel := config.Compression.postingLevel(config.CompressionLevel, backlog, time.Since(b.lastPostTime), l1BaseFee)
+	recompressStart := time.Now()
+	saved, err := b.building.segments.recompressForPosting(compressionLevel)
+	if err != nil {
+		b.building = nil
+		return false, err
+	}
+	batchCompressionRecompressTimer.UpdateSince(recompressStart)
+	batchCompressionLevelGauge.Update(int64(b.building.segments.compressionLevel))
+	batchCompressionSavedBytes.Inc(int64(saved))
 	sequencerMsg, err := b.building.segments.CloseAndGetBytes()
 	if err != nil {
 		return false, err
@@ -540,48 +668,79 @@ func (b *BatchPoster) maybePostSequencerBatch(ctx context.Context) (bool, error)
 		return false, nil
 	}
 
-	if b.daWriter != nil {
-		cert, err := b.daWriter.Store(ctx, sequencerMsg, uint64(time.Now().Add(config.DASRetentionPeriod).Unix()), []byte{}) // b.daWriter will append signature if enabled
-		if err != nil {
-			log.Warn("Unable to batch to DAS, falling back to storing data on chain", "err", err)
-			if config.DisableDasFallbackStoreDataOnChain {
-				return false, errors.New("Unable to batch to DAS and fallback storing data on chain is disabled")
-			}
-		} else {
-			sequencerMsg = das.Serialize(cert)
-		}
-	}
-
-	gasLimit, err := b.estimateGas(ctx, sequencerMsg, b.building.segments.delayedMsg)
-	if err != nil {
-		return false, err
-	}
-	data, err := b.encodeAddBatch(new(big.Int).SetUint64(batchPosition.NextSeqNum), batchPosition.MessageCount, b.building.msgCount, sequencerMsg, b.building.segments.delayedMsg)
-	if err != nil {
-		return false, err
-	}
 	newMeta := batchPosterPosition{
 		MessageCount:        b.building.msgCount,
 		DelayedMessageCount: b.building.segments.delayedMsg,
 		NextSeqNum:          batchPosition.NextSeqNum + 1,
 	}
-	err = b.dataPoster.PostTransaction(ctx, nextMessageTime, nonce, newMeta, b.seqInboxAddr, data, gasLimit)
-	if err != nil {
+	if config.DryRun.Enable {
+		// Nothing leaves the node in dry run mode: the batch goes through a stand-in for the sink, and its L1 gas is estimated offline
+		if err := b.writeDryRunBatch(ctx, config, batchPosition, sequencerMsg); err != nil {
+			return false, fmt.Errorf("error writing dry run batch: %w", err)
+		}
+		// Fake our position forward, as nothing was posted to L1
+		b.dryRunPosition = &newMeta
+	} else if err := b.postBatch(ctx, config, nonce, nextMessageTime, batchPosition, newMeta, sequencerMsg); err != nil {
 		return false, err
 	}
 	log.Info(
 		"BatchPoster: batch sent",
+		"dry run", config.DryRun.Enable,
 		"sequence nr.", batchPosition.NextSeqNum,
 		"from", batchPosition.MessageCount,
 		"to", b.building.msgCount,
 		"prev delayed", batchPosition.DelayedMessageCount,
 		"current delayed", b.building.segments.delayedMsg,
 		"total segments", len(b.building.segments.rawSegments),
+		"compression level", b.building.segments.compressionLevel,
 	)
+	b.lastPostTime = time.Now()
+	atomic.StoreInt32(&b.forcePost, 0)
+	b.updateStatus(func(status *batchPosterStatus) {
+		status.position = &newMeta
+		status.lastPostTime = b.lastPostTime
+		status.building = nil
+	})
 	b.building = nil
 	return true, nil
 }
 
+// publishBatch publishes the batch to the sink, returning the payload to post on chain in its place,
+// and whether that's the sink's payload rather than the batch itself after falling back to calldata
+func publishBatch(ctx context.Context, config *BatchPosterConfig, sink BatchSink, seqNum uint64, sequencerMsg []byte) ([]byte, bool, error) {
+	if sink.Name() == BatchSinkCalldata {
+		return sequencerMsg, false, nil
+	}
+	published, err := sink.PublishBatch(ctx, seqNum, sequencerMsg)
+	if err != nil {
+		log.Warn("Unable to publish batch to data availability sink, falling back to storing data on chain", "sink", sink.Name(), "err", err)
+		if config.DisableDasFallbackStoreDataOnChain {
+			return nil, false, fmt.Errorf("Unable to publish batch to %v sink and fallback storing data on chain is disabled", sink.Name())
+		}
+		return sequencerMsg, false, nil
+	}
+	return published, true, nil
+}
+
+// postBatch publishes the batch to the sink, falling back to calldata if allowed, and queues its L1 transaction
+func (b *BatchPoster) postBatch(ctx context.Context, config *BatchPosterConfig, nonce uint64, nextMessageTime time.Time, batchPosition batchPosterPosition, newMeta batchPosterPosition, sequencerMsg []byte) error {
+	sequencerMsg, _, err := publishBatch(ctx, config, b.sink, batchPosition.NextSeqNum, sequencerMsg)
+	if err != nil {
+		return err
+	}
+
+	gasLimit, err := b.estimateGas(ctx, sequencerMsg, newMeta.DelayedMessageCount)
+	if err != nil {
+		return err
+	}
+	data, err := b.encodeAddBatch(new(big.Int).SetUint64(batchPosition.NextSeqNum), batchPosition.MessageCount, newMeta.MessageCount, sequencerMsg, newMeta.DelayedMessageCount)
+	if err != nil {
+		return err
+	}
+	_, err = b.dataPoster.PostTransaction(ctx, nextMessageTime, nonce, newMeta, b.seqInboxAddr, data, gasLimit, nil)
+	return err
+}
+
 func (b *BatchPoster) Start(ctxIn context.Context) {
 	b.dataPoster.Start(ctxIn)
 	b.redisLock.Start(ctxIn)
@@ -591,9 +750,16 @@ func (b *BatchPoster) Start(ctxIn context.Context) {
 			b.building = nil
 			return b.config().BatchPollDelay
 		}
+		if b.Paused() {
+			return b.config().BatchPollDelay
+		}
 		posted, err := b.maybePostSequencerBatch(ctx)
 		if err != nil {
 			b.building = nil
+			b.updateStatus(func(status *batchPosterStatus) {
+				status.lastError = err
+				status.lastErrorTime = time.Now()
+			})
 			logLevel := log.Error
 			if errors.Is(err, AccumulatorNotFoundErr) || errors.Is(err, dataposter.StorageRaceErr) {
 				// Likely the inbox tracker just isn't caught up.
@@ -0,0 +1,227 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/offchainlabs/nitro/arbutil"
+)
+
+// batchPosterStatus is written by the batch poster's thread, and read by the status RPC
+type batchPosterStatus struct {
+	position      *batchPosterPosition // the position after the last batch given to the data poster
+	lastPostTime  time.Time
+	building      *BuildingBatchStatus
+	lastError     error
+	lastErrorTime time.Time
+}
+
+type BuildingBatchStatus struct {
+	FromMessage     arbutil.MessageIndex `json:"fromMessage"`
+	ToMessage       arbutil.MessageIndex `json:"toMessage"` // exclusive
+	Segments        int                  `json:"segments"`
+	EstimatedSize   int                  `json:"estimatedSize"`
+	FirstMessageAge string               `json:"firstMessageAge"`
+
+	firstMessageTime time.Time
+}
+
+type BatchPosterQueuedTx struct {
+	Nonce           hexutil.Uint64       `json:"nonce"`
+	Hash            common.Hash          `json:"hash"`
+	FeeCap          *hexutil.Big         `json:"feeCap"`
+	TipCap          *hexutil.Big         `json:"tipCap"`
+	Gas             hexutil.Uint64       `json:"gas"`
+	Sent            bool                 `json:"sent"`
+	Age             string               `json:"age"`
+	NextReplacement time.Time            `json:"nextReplacement"`
+	SequenceNumber  uint64               `json:"sequenceNumber"`
+	MessageCount    arbutil.MessageIndex `json:"messageCount"`
+}
+
+type BatchPosterStatus struct {
+	Paused           bool `json:"paused"`
+	ForcePostPending bool `json:"forcePostPending"`
+	// The last batch handed to the data poster, which may not have landed on L1 yet
+	LastQueuedSequenceNumber *uint64               `json:"lastQueuedSequenceNumber,omitempty"`
+	LastQueuedMessageCount   *arbutil.MessageIndex `json:"lastQueuedMessageCount,omitempty"`
+	// The batches read back from the sequencer inbox on L1
+	PostedBatchCount   *uint64               `json:"postedBatchCount,omitempty"`
+	PostedMessageCount *arbutil.MessageIndex `json:"postedMessageCount,omitempty"`
+	LastPostTime       *time.Time            `json:"lastPostTime,omitempty"`
+	Building           *BuildingBatchStatus  `json:"building,omitempty"`
+	ConfirmedNonce     *hexutil.Uint64       `json:"confirmedNonce,omitempty"`
+	QueuedTransactions []BatchPosterQueuedTx `json:"queuedTransactions"`
+	LastError          string                `json:"lastError,omitempty"`
+	LastErrorTime      *time.Time            `json:"lastErrorTime,omitempty"`
+}
+
+func (b *BatchPoster) updateStatus(update func(status *batchPosterStatus)) {
+	b.statusMutex.Lock()
+	defer b.statusMutex.Unlock()
+	update(&b.status)
+}
+
+func (b *BatchPoster) Paused() bool {
+	return atomic.LoadInt32(&b.paused) != 0
+}
+
+// Pause stops posting batches until resumed. Already queued transactions are still replaced by fee as needed.
+func (b *BatchPoster) Pause() {
+	if atomic.SwapInt32(&b.paused, 1) == 0 {
+		log.Info("batch posting paused")
+	}
+}
+
+func (b *BatchPoster) Resume() {
+	if atomic.SwapInt32(&b.paused, 0) != 0 {
+		log.Info("batch posting resumed")
+	}
+}
+
+// ForcePost makes the batch poster post whatever it has on its next iteration, without waiting for the batch to fill
+func (b *BatchPoster) ForcePost() {
+	atomic.StoreInt32(&b.forcePost, 1)
+}
+
+func (b *BatchPoster) forcePostPending() bool {
+	return atomic.LoadInt32(&b.forcePost) != 0
+}
+
+func (b *BatchPoster) Status(ctx context.Context) (*BatchPosterStatus, error) {
+	result := &BatchPosterStatus{
+		Paused:             b.Paused(),
+		ForcePostPending:   b.forcePostPending(),
+		QueuedTransactions: []BatchPosterQueuedTx{},
+	}
+	b.statusMutex.Lock()
+	if b.status.position != nil && b.status.position.NextSeqNum > 0 {
+		lastSeqNum := b.status.position.NextSeqNum - 1
+		msgCount := b.status.position.MessageCount
+		result.LastQueuedSequenceNumber = &lastSeqNum
+		result.LastQueuedMessageCount = &msgCount
+	}
+	if !b.status.lastPostTime.IsZero() {
+		lastPostTime := b.status.lastPostTime
+		result.LastPostTime = &lastPostTime
+	}
+	if b.status.building != nil {
+		building := *b.status.building
+		building.FirstMessageAge = time.Since(building.firstMessageTime).String()
+		result.Building = &building
+	}
+	if b.status.lastError != nil {
+		lastErrorTime := b.status.lastErrorTime
+		result.LastError = b.status.lastError.Error()
+		result.LastErrorTime = &lastErrorTime
+	}
+	b.statusMutex.Unlock()
+
+	if b.inbox != nil {
+		batchCount, err := b.inbox.GetBatchCount()
+		if err != nil {
+			return nil, err
+		}
+		result.PostedBatchCount = &batchCount
+		if batchCount > 0 {
+			msgCount, err := b.inbox.GetBatchMessageCount(batchCount - 1)
+			if err != nil {
+				return nil, err
+			}
+			result.PostedMessageCount = &msgCount
+		}
+	}
+	if b.config().DryRun.Enable {
+		return result, nil
+	}
+	confirmedNonce, queued, err := b.dataPoster.QueuedTransactions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	nonce := hexutil.Uint64(confirmedNonce)
+	result.ConfirmedNonce = &nonce
+	for _, tx := range queued {
+		result.QueuedTransactions = append(result.QueuedTransactions, BatchPosterQueuedTx{
+			Nonce:           hexutil.Uint64(tx.Nonce),
+			Hash:            tx.Hash,
+			FeeCap:          (*hexutil.Big)(tx.FeeCap),
+			TipCap:          (*hexutil.Big)(tx.TipCap),
+			Gas:             hexutil.Uint64(tx.Gas),
+			Sent:            tx.Sent,
+			Age:             time.Since(tx.Created).String(),
+			NextReplacement: tx.NextReplacement,
+			SequenceNumber:  tx.Meta.NextSeqNum - 1,
+			MessageCount:    tx.Meta.MessageCount,
+		})
+	}
+	return result, nil
+}
+
+// BatchPosterAPI lets operators inspect and control the batch poster.
+// It isn't public, so it's only served when explicitly enabled.
+type BatchPosterAPI struct {
+	batchPoster *BatchPoster
+}
+
+func (a *BatchPosterAPI) Status(ctx context.Context) (*BatchPosterStatus, error) {
+	return a.batchPoster.Status(ctx)
+}
+
+func (a *BatchPosterAPI) ForcePost(ctx context.Context) error {
+	a.batchPoster.ForcePost()
+	return nil
+}
+
+func (a *BatchPosterAPI) Pause(ctx context.Context) error {
+	a.batchPoster.Pause()
+	return nil
+}
+
+func (a *BatchPosterAPI) Resume(ctx context.Context) error {
+	a.batchPoster.Resume()
+	return nil
+}
+
+func (a *BatchPosterAPI) QueuedTransactions(ctx context.Context) ([]BatchPosterQueuedTx, error) {
+	status, err := a.batchPoster.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return status.QueuedTransactions, nil
+}
+
+// ReplaceByFee immediately replaces the queued transaction with the given nonce, using the given fee cap
+func (a *BatchPosterAPI) ReplaceByFee(ctx context.Context, nonce hexutil.Uint64, feeCap *hexutil.Big) error {
+	if feeCap == nil {
+		return errors.New("fee cap is required")
+	}
+	return a.batchPoster.dataPoster.ReplaceByFee(ctx, uint64(nonce), feeCap.ToInt())
+}
+
+// CancelNonce replaces the transaction with the given nonce with a zero value transfer to the batch poster itself
+func (a *BatchPosterAPI) CancelNonce(ctx context.Context, nonce hexutil.Uint64, feeCap *hexutil.Big) error {
+	if feeCap == nil {
+		return errors.New("fee cap is required")
+	}
+	return a.batchPoster.dataPoster.CancelNonce(ctx, uint64(nonce), feeCap.ToInt())
+}
+
+// ResyncQueue discards all queued transactions and returns the L1 nonce posting will resume from
+func (a *BatchPosterAPI) ResyncQueue(ctx context.Context) (hexutil.Uint64, error) {
+	// Don't post batches based on the old queue while it's being discarded
+	if !a.batchPoster.Paused() {
+		a.batchPoster.Pause()
+		defer a.batchPoster.Resume()
+	}
+	nonce, err := a.batchPoster.dataPoster.ResyncQueue(ctx)
+	return hexutil.Uint64(nonce), err
+}
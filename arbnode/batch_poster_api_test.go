@@ -0,0 +1,71 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBatchPosterStatus(t *testing.T) {
+	config := TestBatchPosterConfig
+	config.DryRun = BatchPosterDryRunConfig{Enable: true, Directory: t.TempDir()}
+	_, inbox := newInboxForArchiveTest(t, true)
+	b := &BatchPoster{
+		inbox:  inbox,
+		config: func() *BatchPosterConfig { return &config },
+	}
+
+	b.Pause()
+	b.ForcePost()
+	b.updateStatus(func(status *batchPosterStatus) {
+		status.position = &batchPosterPosition{MessageCount: 20, NextSeqNum: 3}
+		status.building = &BuildingBatchStatus{FromMessage: 20, ToMessage: 25, firstMessageTime: time.Now().Add(-time.Minute)}
+		status.lastError = errors.New("test error")
+		status.lastErrorTime = time.Now()
+	})
+
+	status, err := b.Status(context.Background())
+	Require(t, err)
+	if !status.Paused || !status.ForcePostPending {
+		Fail(t, "pause and force post not reported", status)
+	}
+	if status.LastQueuedSequenceNumber == nil || *status.LastQueuedSequenceNumber != 2 || *status.LastQueuedMessageCount != 20 {
+		Fail(t, "unexpected last queued batch", status)
+	}
+	batchCount, err := inbox.GetBatchCount()
+	Require(t, err)
+	msgCount, err := inbox.GetBatchMessageCount(batchCount - 1)
+	Require(t, err)
+	if status.PostedBatchCount == nil || *status.PostedBatchCount != batchCount || status.PostedMessageCount == nil || *status.PostedMessageCount != msgCount {
+		Fail(t, "posted batches not read from the inbox", status)
+	}
+	if status.Building == nil || status.Building.ToMessage != 25 || status.Building.FirstMessageAge == "" {
+		Fail(t, "unexpected building batch status", status.Building)
+	}
+	if status.LastError != "test error" {
+		Fail(t, "unexpected last error", status.LastError)
+	}
+
+	output, err := json.Marshal(status)
+	Require(t, err)
+	var fields map[string]interface{}
+	Require(t, json.Unmarshal(output, &fields))
+	for _, field := range []string{"paused", "lastQueuedSequenceNumber", "lastQueuedMessageCount", "postedBatchCount", "postedMessageCount", "building", "queuedTransactions", "lastError"} {
+		if _, ok := fields[field]; !ok {
+			Fail(t, "status output is missing", field, string(output))
+		}
+	}
+	if _, ok := fields["confirmedNonce"]; ok {
+		Fail(t, "dry run status output has a data poster nonce", string(output))
+	}
+
+	b.Resume()
+	if b.Paused() {
+		Fail(t, "batch poster still paused after resuming")
+	}
+}
@@ -0,0 +1,223 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+
+	"github.com/offchainlabs/nitro/arbstate"
+	"github.com/offchainlabs/nitro/arbutil"
+	"github.com/offchainlabs/nitro/blsSignatures"
+	"github.com/offchainlabs/nitro/das"
+	"github.com/offchainlabs/nitro/das/dastree"
+)
+
+// BatchPosterDryRunConfig makes the batch poster write batches to local files instead of posting them to L1
+type BatchPosterDryRunConfig struct {
+	Enable    bool   `koanf:"enable"`
+	Directory string `koanf:"directory"`
+	FailSink  bool   `koanf:"fail-sink"`
+}
+
+func (c *BatchPosterDryRunConfig) Validate() error {
+	if c.Enable && c.Directory == "" {
+		return fmt.Errorf("batch poster dry run enabled without a directory to write batches to")
+	}
+	return nil
+}
+
+func BatchPosterDryRunConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", DefaultBatchPosterDryRunConfig.Enable, "build batches as usual, but write them to the dry run directory instead of posting them to L1")
+	f.String(prefix+".directory", DefaultBatchPosterDryRunConfig.Directory, "directory to write dry run batches to")
+	f.Bool(prefix+".fail-sink", DefaultBatchPosterDryRunConfig.FailSink, "make publishing dry run batches to the sink fail, to exercise falling back to storing data on chain")
+}
+
+var DefaultBatchPosterDryRunConfig = BatchPosterDryRunConfig{
+	Enable:    false,
+	Directory: "",
+	FailSink:  false,
+}
+
+// DryRunBatchInfo is the JSON sidecar written alongside each dry run batch
+type DryRunBatchInfo struct {
+	SequenceNumber   uint64               `json:"sequenceNumber"`
+	FromMessage      arbutil.MessageIndex `json:"fromMessage"`
+	ToMessage        arbutil.MessageIndex `json:"toMessage"` // exclusive
+	PrevDelayedCount uint64               `json:"prevDelayedCount"`
+	DelayedCount     uint64               `json:"delayedCount"`
+	Segments         int                  `json:"segments"`
+	CompressedSize   int                  `json:"compressedSize"`
+	DataAvailability bool                 `json:"dataAvailability"` // whether the sink's payload would be posted rather than the batch
+	PostedSize       int                  `json:"postedSize"`
+	EstimatedGas     uint64               `json:"estimatedGas"`
+}
+
+// dryRunBatchSink stands in for the configured sink in dry run mode. Instead of storing the batch, it returns
+// a placeholder shaped like the payload the sink would post, so its size and gas cost can be recorded.
+type dryRunBatchSink struct {
+	sink BatchSink
+	fail bool
+}
+
+func (s dryRunBatchSink) Name() string {
+	return s.sink.Name()
+}
+
+func (s dryRunBatchSink) PublishBatch(ctx context.Context, seqNum uint64, data []byte) ([]byte, error) {
+	if s.fail {
+		return nil, errors.New("dry run sink configured to fail")
+	}
+	switch sink := s.sink.(type) {
+	case *DASBatchSink:
+		// An unsigned certificate is the same size as a signed one
+		return das.Serialize(&arbstate.DataAvailabilityCertificate{
+			DataHash: dastree.Hash(data),
+			Timeout:  uint64(time.Now().Add(sink.retentionPeriod()).Unix()),
+			Sig:      blsSignatures.AggregateSignatures(nil),
+			Version:  1,
+		}), nil
+	case *ExternalDABatchSink:
+		// The layer's commitment is unknown without storing the batch, so it's left empty
+		return arbstate.SerializeExternalDACommitment(sink.header, crypto.Keccak256Hash(data), nil), nil
+	default:
+		return data, nil
+	}
+}
+
+// estimateGasOffline estimates the gas to post the payload from its calldata alone, as intrinsic gas plus the data's cost,
+// where estimateGas would ask L1. Execution isn't accounted for beyond the configured extra batch gas.
+func (b *BatchPoster) estimateGasOffline(sequencerMessage []byte, delayedMessages uint64) (uint64, error) {
+	data, err := b.encodeAddBatch(abi.MaxUint256, 0, 1, sequencerMessage, delayedMessages)
+	if err != nil {
+		return 0, err
+	}
+	gas := params.TxGas
+	for _, dataByte := range data {
+		if dataByte == 0 {
+			gas += params.TxDataZeroGas
+		} else {
+			gas += params.TxDataNonZeroGasEIP2028
+		}
+	}
+	return gas + b.config().ExtraBatchGas, nil
+}
+
+// dryRunSequencerMessage prepends the L1 header the sequencer inbox would add to the batch data,
+// so the result can be read back through arbstate's inbox multiplexer.
+func (b *BatchPoster) dryRunSequencerMessage(from, to arbutil.MessageIndex, delayedCount uint64, batchData []byte) ([]byte, error) {
+	var minTimestamp, maxTimestamp, minL1Block, maxL1Block uint64
+	for pos := from; pos < to; pos++ {
+		msg, err := b.streamer.GetMessage(pos)
+		if err != nil {
+			return nil, err
+		}
+		header := msg.Message.Header
+		if pos == from || header.Timestamp < minTimestamp {
+			minTimestamp = header.Timestamp
+		}
+		if header.Timestamp > maxTimestamp {
+			maxTimestamp = header.Timestamp
+		}
+		if pos == from || header.BlockNumber < minL1Block {
+			minL1Block = header.BlockNumber
+		}
+		if header.BlockNumber > maxL1Block {
+			maxL1Block = header.BlockNumber
+		}
+	}
+	return encodeDryRunSequencerMessage(minTimestamp, maxTimestamp, minL1Block, maxL1Block, delayedCount, batchData), nil
+}
+
+func encodeDryRunSequencerMessage(minTimestamp, maxTimestamp, minL1Block, maxL1Block, delayedCount uint64, batchData []byte) []byte {
+	message := make([]byte, 40, 40+len(batchData))
+	binary.BigEndian.PutUint64(message[:8], minTimestamp)
+	binary.BigEndian.PutUint64(message[8:16], maxTimestamp)
+	binary.BigEndian.PutUint64(message[16:24], minL1Block)
+	binary.BigEndian.PutUint64(message[24:32], maxL1Block)
+	binary.BigEndian.PutUint64(message[32:40], delayedCount)
+	return append(message, batchData...)
+}
+
+// writeDryRunBatch writes the batch being built, which starts at batchPosition, instead of posting it
+func (b *BatchPoster) writeDryRunBatch(ctx context.Context, config *BatchPosterConfig, batchPosition batchPosterPosition, batchData []byte) error {
+	sink := dryRunBatchSink{sink: b.sink, fail: config.DryRun.FailSink}
+	posted, dataAvailability, err := publishBatch(ctx, config, sink, batchPosition.NextSeqNum, batchData)
+	if err != nil {
+		return err
+	}
+	gas, err := b.estimateGasOffline(posted, b.building.segments.delayedMsg)
+	if err != nil {
+		return err
+	}
+	// The batch itself is written even if the sink's payload would be posted, so it can be decoded
+	fullMsg, err := b.dryRunSequencerMessage(batchPosition.MessageCount, b.building.msgCount, b.building.segments.delayedMsg, batchData)
+	if err != nil {
+		return err
+	}
+	log.Info("BatchPoster: dry run, writing batch instead of posting it", "sequence nr.", batchPosition.NextSeqNum, "dir", config.DryRun.Directory)
+	return writeDryRunBatchFiles(config.DryRun.Directory, fullMsg, &DryRunBatchInfo{
+		SequenceNumber:   batchPosition.NextSeqNum,
+		FromMessage:      batchPosition.MessageCount,
+		ToMessage:        b.building.msgCount,
+		PrevDelayedCount: batchPosition.DelayedMessageCount,
+		DelayedCount:     b.building.segments.delayedMsg,
+		Segments:         len(b.building.segments.rawSegments),
+		CompressedSize:   len(batchData),
+		DataAvailability: dataAvailability,
+		PostedSize:       len(posted),
+		EstimatedGas:     gas,
+	})
+}
+
+func dryRunBatchPaths(dir string, seqNum uint64) (string, string) {
+	base := filepath.Join(dir, fmt.Sprintf("%d", seqNum))
+	return base + ".bin", base + ".json"
+}
+
+func writeDryRunBatchFiles(dir string, sequencerMessage []byte, info *DryRunBatchInfo) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	infoJson, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return err
+	}
+	binPath, jsonPath := dryRunBatchPaths(dir, info.SequenceNumber)
+	if err := os.WriteFile(binPath, sequencerMessage, 0o600); err != nil {
+		return err
+	}
+	return os.WriteFile(jsonPath, infoJson, 0o600)
+}
+
+// ReadDryRunBatch reads a batch written in dry run mode, returning the sequencer message including its L1 header.
+func ReadDryRunBatch(dir string, seqNum uint64) ([]byte, *DryRunBatchInfo, error) {
+	binPath, jsonPath := dryRunBatchPaths(dir, seqNum)
+	sequencerMessage, err := os.ReadFile(binPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	infoJson, err := os.ReadFile(jsonPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	var info DryRunBatchInfo
+	if err := json.Unmarshal(infoJson, &info); err != nil {
+		return nil, nil, fmt.Errorf("error parsing dry run batch %v info: %w", seqNum, err)
+	}
+	return sequencerMessage, &info, nil
+}
@@ -0,0 +1,178 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+
+	"github.com/offchainlabs/nitro/arbos"
+	"github.com/offchainlabs/nitro/arbos/l1pricing"
+	"github.com/offchainlabs/nitro/arbstate"
+	"github.com/offchainlabs/nitro/solgen/go/bridgegen"
+)
+
+type dryRunInboxBackend struct {
+	batch                 []byte
+	batchSeqNum           uint64
+	positionWithinMessage uint64
+}
+
+func (b *dryRunInboxBackend) PeekSequencerInbox() ([]byte, error) {
+	if b.batchSeqNum != 0 {
+		return nil, errors.New("reading unknown sequencer batch")
+	}
+	return b.batch, nil
+}
+
+func (b *dryRunInboxBackend) GetSequencerInboxPosition() uint64 {
+	return b.batchSeqNum
+}
+
+func (b *dryRunInboxBackend) AdvanceSequencerInbox() {
+	b.batchSeqNum++
+}
+
+func (b *dryRunInboxBackend) GetPositionWithinMessage() uint64 {
+	return b.positionWithinMessage
+}
+
+func (b *dryRunInboxBackend) SetPositionWithinMessage(pos uint64) {
+	b.positionWithinMessage = pos
+}
+
+func (b *dryRunInboxBackend) ReadDelayedInbox(seqNum uint64) (*arbos.L1IncomingMessage, error) {
+	return nil, errors.New("no delayed messages in dry run test")
+}
+
+func TestDryRunBatchRoundTrip(t *testing.T) {
+	var messages []*arbstate.MessageWithMetadata
+	for i := uint64(0); i < 3; i++ {
+		messages = append(messages, &arbstate.MessageWithMetadata{
+			Message: &arbos.L1IncomingMessage{
+				Header: &arbos.L1IncomingMessageHeader{
+					Kind:        arbos.L1MessageType_L2Message,
+					Poster:      l1pricing.BatchPosterAddress,
+					BlockNumber: 100 + i,
+					Timestamp:   1000 + i*10,
+				},
+				L2msg: []byte{arbos.L2MessageKind_SignedTx, byte(i), 1, 2, 3},
+			},
+		})
+	}
+
+	segments := newBatchSegments(0, &TestBatchPosterConfig)
+	for _, msg := range messages {
+		success, err := segments.AddMessage(msg)
+		Require(t, err)
+		if !success {
+			Fail(t, "failed to add message to batch")
+		}
+	}
+	batchData, err := segments.CloseAndGetBytes()
+	Require(t, err)
+
+	dir := t.TempDir()
+	fullMsg := encodeDryRunSequencerMessage(1000, 1020, 100, 102, 0, batchData)
+	Require(t, writeDryRunBatchFiles(dir, fullMsg, &DryRunBatchInfo{
+		SequenceNumber: 7,
+		FromMessage:    10,
+		ToMessage:      13,
+		Segments:       len(segments.rawSegments),
+		CompressedSize: len(batchData),
+	}))
+
+	readMsg, info, err := ReadDryRunBatch(dir, 7)
+	Require(t, err)
+	if !bytes.Equal(readMsg, fullMsg) {
+		Fail(t, "dry run batch changed when read back")
+	}
+	if info.FromMessage != 10 || info.ToMessage != 13 || info.CompressedSize != len(batchData) {
+		Fail(t, "unexpected dry run batch info", info)
+	}
+
+	multiplexer := arbstate.NewInboxMultiplexer(&dryRunInboxBackend{batch: readMsg}, 0, nil, nil, arbstate.KeysetValidate)
+	for i, expected := range messages {
+		msg, err := multiplexer.Pop(context.Background())
+		Require(t, err)
+		if !bytes.Equal(msg.Message.L2msg, expected.Message.L2msg) {
+			Fail(t, "message", i, "has unexpected contents", msg.Message.L2msg)
+		}
+		if msg.Message.Header.Timestamp != expected.Message.Header.Timestamp || msg.Message.Header.BlockNumber != expected.Message.Header.BlockNumber {
+			Fail(t, "message", i, "has unexpected header", msg.Message.Header)
+		}
+	}
+}
+
+func TestDryRunOnlyWritesBatch(t *testing.T) {
+	ownerKey, err := crypto.GenerateKey()
+	Require(t, err)
+	seqInboxABI, err := bridgegen.SequencerInboxMetaData.GetAbi()
+	Require(t, err)
+
+	for _, failSink := range []bool{false, true} {
+		streamer, _, _ := NewTransactionStreamerForTest(t, crypto.PubkeyToAddress(ownerKey.PublicKey))
+		Require(t, streamer.AddMessages(1, false, signedTxMessages(t, ownerKey, 2, 1)))
+
+		config := TestBatchPosterConfig
+		config.DryRun = BatchPosterDryRunConfig{
+			Enable:    true,
+			Directory: t.TempDir(),
+			FailSink:  failSink,
+		}
+		// Without an L1 reader, data poster or DAS writer, any attempt to estimate gas on L1, post the batch or store it would panic
+		b := &BatchPoster{
+			streamer:       streamer,
+			config:         func() *BatchPosterConfig { return &config },
+			seqInboxABI:    seqInboxABI,
+			sink:           NewDASBatchSink(nil, func() time.Duration { return time.Hour }),
+			dryRunPosition: &batchPosterPosition{MessageCount: 1, DelayedMessageCount: 1},
+		}
+		posted, err := b.maybePostSequencerBatch(context.Background())
+		Require(t, err)
+		if !posted {
+			Fail(t, "dry run didn't write a batch")
+		}
+		_, info, err := ReadDryRunBatch(config.DryRun.Directory, 0)
+		Require(t, err)
+		if info.FromMessage != 1 || info.ToMessage != 3 || info.DelayedCount != 1 {
+			Fail(t, "unexpected dry run batch info", info)
+		}
+		if info.DataAvailability == failSink {
+			Fail(t, "dry run with a failing sink", failSink, "recorded data availability", info.DataAvailability)
+		}
+		if failSink && info.PostedSize != info.CompressedSize {
+			Fail(t, "dry run fell back to posting", info.PostedSize, "bytes for a batch of", info.CompressedSize)
+		}
+		if info.EstimatedGas <= params.TxGas+config.ExtraBatchGas {
+			Fail(t, "dry run estimated", info.EstimatedGas, "gas, less than the batch's calldata costs")
+		}
+		if b.dryRunPosition.MessageCount != 3 || b.dryRunPosition.NextSeqNum != 1 {
+			Fail(t, "dry run position wasn't advanced past the batch", b.dryRunPosition)
+		}
+	}
+}
+
+func TestDryRunEstimateGasOffline(t *testing.T) {
+	seqInboxABI, err := bridgegen.SequencerInboxMetaData.GetAbi()
+	Require(t, err)
+	config := TestBatchPosterConfig
+	b := &BatchPoster{
+		config:      func() *BatchPosterConfig { return &config },
+		seqInboxABI: seqInboxABI,
+	}
+	zeros, err := b.estimateGasOffline(make([]byte, 64), 0)
+	Require(t, err)
+	ones, err := b.estimateGasOffline(bytes.Repeat([]byte{1}, 64), 0)
+	Require(t, err)
+	if ones-zeros != 64*(params.TxDataNonZeroGasEIP2028-params.TxDataZeroGas) {
+		Fail(t, "estimated", ones, "gas for nonzero data and", zeros, "for zero data")
+	}
+}
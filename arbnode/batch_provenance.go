@@ -0,0 +1,287 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/offchainlabs/nitro/arbstate"
+	"github.com/offchainlabs/nitro/arbutil"
+	"github.com/offchainlabs/nitro/util/containers"
+	"github.com/offchainlabs/nitro/validator"
+)
+
+// DASCertificateInfo is the part of a DAS certificate posted in place of a batch's data that identifies the data and who stored it
+type DASCertificateInfo struct {
+	KeysetHash  common.Hash `json:"keysetHash"`
+	DataHash    common.Hash `json:"dataHash"`
+	Timeout     uint64      `json:"timeout"`
+	SignersMask uint64      `json:"signersMask"`
+	Version     uint8       `json:"version"`
+}
+
+// BatchInfo describes where a sequencer batch was posted on L1, what it sequenced, and how final it is
+type BatchInfo struct {
+	BatchNumber uint64      `json:"batchNumber"`
+	Accumulator common.Hash `json:"accumulator"`
+	L1Block     uint64      `json:"l1Block"`
+	L1BlockHash common.Hash `json:"l1BlockHash"`
+	L1TxHash    common.Hash `json:"l1TxHash"`
+	// The batch sequenced the messages from FirstMessage up to but not including MessageCount,
+	// which became the L2 blocks from FirstL2Block to LastL2Block inclusive. Both ranges are empty for empty batches.
+	FirstMessage        arbutil.MessageIndex `json:"firstMessage"`
+	MessageCount        arbutil.MessageIndex `json:"messageCount"`
+	FirstL2Block        uint64               `json:"firstL2Block"`
+	LastL2Block         uint64               `json:"lastL2Block"`
+	DelayedMessageCount uint64               `json:"delayedMessageCount"`
+	DataLocation        string               `json:"dataLocation"`
+	DASCertificate      *DASCertificateInfo  `json:"dasCertificate,omitempty"`
+	L1Confirmations     uint64               `json:"l1Confirmations"`
+	// Whether the latest confirmed rollup node read the whole batch
+	Confirmed bool `json:"confirmed"`
+}
+
+// BlockProvenance describes the batch that posted an L2 block to L1
+type BlockProvenance struct {
+	L2Block      uint64               `json:"l2Block"`
+	MessageIndex arbutil.MessageIndex `json:"messageIndex"`
+	Batch        *BatchInfo           `json:"batch"`
+	// Whether the latest confirmed rollup node covers the block, which may be before the whole batch is covered
+	Confirmed bool `json:"confirmed"`
+}
+
+// The number of batches whose L1 info is cached, so repeated queries don't each read the batch from L1
+const batchL1InfoCacheSize = 1024
+
+// batchL1Info is the part of a batch's info read from L1, which only changes if the batch is reorged
+type batchL1Info struct {
+	accumulator    common.Hash
+	blockHash      common.Hash
+	txHash         common.Hash
+	dataLocation   string
+	dasCertificate *DASCertificateInfo
+}
+
+type BatchProvenanceAPI struct {
+	txStreamer   *TransactionStreamer
+	inboxTracker *InboxTracker
+	inboxReader  *InboxReader
+	rollup       *validator.RollupWatcher
+
+	l1InfoCacheMutex sync.Mutex
+	l1InfoCache      *containers.LruCache[uint64, *batchL1Info]
+
+	confirmedMutex   sync.Mutex
+	confirmedL1Block uint64
+	confirmedState   *validator.GoGlobalState
+}
+
+func NewBatchProvenanceAPI(txStreamer *TransactionStreamer, inboxTracker *InboxTracker, inboxReader *InboxReader, rollup *validator.RollupWatcher) *BatchProvenanceAPI {
+	return &BatchProvenanceAPI{
+		txStreamer:   txStreamer,
+		inboxTracker: inboxTracker,
+		inboxReader:  inboxReader,
+		rollup:       rollup,
+		l1InfoCache:  containers.NewLruCache[uint64, *batchL1Info](batchL1InfoCacheSize),
+	}
+}
+
+// cachedBatchL1Info returns the cached L1 info of a batch, if it was cached for the batch with this accumulator
+func (a *BatchProvenanceAPI) cachedBatchL1Info(seqNum uint64, accumulator common.Hash) *batchL1Info {
+	a.l1InfoCacheMutex.Lock()
+	defer a.l1InfoCacheMutex.Unlock()
+	info, found := a.l1InfoCache.Get(seqNum)
+	if !found || info.accumulator != accumulator {
+		// the batch was reorged since it was cached
+		return nil
+	}
+	return info
+}
+
+func (a *BatchProvenanceAPI) cacheBatchL1Info(seqNum uint64, info *batchL1Info) {
+	a.l1InfoCacheMutex.Lock()
+	defer a.l1InfoCacheMutex.Unlock()
+	a.l1InfoCache.Add(seqNum, info)
+}
+
+// readBatchL1Info reads a batch from L1, unless its info is cached
+func (a *BatchProvenanceAPI) readBatchL1Info(ctx context.Context, seqNum uint64, accumulator common.Hash) (*batchL1Info, error) {
+	if info := a.cachedBatchL1Info(seqNum, accumulator); info != nil {
+		return info, nil
+	}
+	batch, err := a.inboxReader.GetSequencerBatch(ctx, seqNum)
+	if err != nil {
+		return nil, err
+	}
+	if batch.AfterInboxAcc != accumulator {
+		return nil, fmt.Errorf("batch %v read from L1 has accumulator %v but the inbox has %v", seqNum, batch.AfterInboxAcc, accumulator)
+	}
+	data, err := batch.Serialize(ctx, a.inboxReader.client)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < sequencerMessageHeaderSize {
+		return nil, fmt.Errorf("serialized batch %v is too short", seqNum)
+	}
+	payload := data[sequencerMessageHeaderSize:]
+	info := &batchL1Info{
+		accumulator:  accumulator,
+		blockHash:    batch.rawLog.BlockHash,
+		txHash:       batch.rawLog.TxHash,
+		dataLocation: batchDataLocationName(payload),
+	}
+	if info.dataLocation == BatchSinkDAS {
+		cert, err := arbstate.DeserializeDASCertFrom(bytes.NewReader(payload))
+		if err != nil {
+			return nil, fmt.Errorf("failed to deserialize DAS certificate of batch %v: %w", seqNum, err)
+		}
+		info.dasCertificate = &DASCertificateInfo{
+			KeysetHash:  cert.KeysetHash,
+			DataHash:    cert.DataHash,
+			Timeout:     cert.Timeout,
+			SignersMask: cert.SignersMask,
+			Version:     cert.Version,
+		}
+	}
+	a.cacheBatchL1Info(seqNum, info)
+	return info, nil
+}
+
+// latestConfirmedState is the global state after the latest confirmed rollup node.
+// It's only read from L1 again once the inbox reader has read a new L1 block.
+func (a *BatchProvenanceAPI) latestConfirmedState(ctx context.Context) (validator.GoGlobalState, error) {
+	l1Block, _ := a.inboxReader.GetLastReadBlockAndBatchCount()
+	a.confirmedMutex.Lock()
+	defer a.confirmedMutex.Unlock()
+	if a.confirmedState != nil && a.confirmedL1Block == l1Block {
+		return *a.confirmedState, nil
+	}
+	confirmedNum, err := a.rollup.LatestConfirmed(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		return validator.GoGlobalState{}, err
+	}
+	confirmed, err := a.rollup.LookupNode(ctx, confirmedNum)
+	if err != nil {
+		return validator.GoGlobalState{}, err
+	}
+	state := confirmed.AfterState().GlobalState
+	a.confirmedState = &state
+	a.confirmedL1Block = l1Block
+	return state, nil
+}
+
+func (a *BatchProvenanceAPI) getBatch(ctx context.Context, seqNum uint64, confirmedState validator.GoGlobalState) (*BatchInfo, error) {
+	latestL1Block, latestBatchCount := a.inboxReader.GetLastReadBlockAndBatchCount()
+	if seqNum >= latestBatchCount {
+		return nil, fmt.Errorf("batch %v hasn't been read from L1, which has %v batches", seqNum, latestBatchCount)
+	}
+	meta, err := a.inboxTracker.GetBatchMetadata(seqNum)
+	if err != nil {
+		return nil, err
+	}
+	var prevMeta BatchMetadata
+	if seqNum > 0 {
+		prevMeta, err = a.inboxTracker.GetBatchMetadata(seqNum - 1)
+		if err != nil {
+			return nil, err
+		}
+	}
+	l1Info, err := a.readBatchL1Info(ctx, seqNum, meta.Accumulator)
+	if err != nil {
+		return nil, err
+	}
+	firstBlock, err := a.txStreamer.MessageCountToBlockNumber(prevMeta.MessageCount)
+	if err != nil {
+		return nil, err
+	}
+	lastBlock, err := a.txStreamer.MessageCountToBlockNumber(meta.MessageCount)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &BatchInfo{
+		BatchNumber:         seqNum,
+		Accumulator:         meta.Accumulator,
+		L1Block:             meta.L1Block,
+		L1BlockHash:         l1Info.blockHash,
+		L1TxHash:            l1Info.txHash,
+		FirstMessage:        prevMeta.MessageCount,
+		MessageCount:        meta.MessageCount,
+		FirstL2Block:        uint64(firstBlock + 1),
+		LastL2Block:         uint64(lastBlock),
+		DelayedMessageCount: meta.DelayedMessageCount,
+		DataLocation:        l1Info.dataLocation,
+		DASCertificate:      l1Info.dasCertificate,
+		Confirmed:           seqNum < confirmedState.Batch,
+	}
+	if latestL1Block >= meta.L1Block {
+		info.L1Confirmations = (latestL1Block - meta.L1Block) + 1 + a.inboxReader.GetDelayBlocks()
+	}
+	return info, nil
+}
+
+// GetBatch returns where a sequencer batch was posted on L1, what it sequenced, and how final it is
+func (a *BatchProvenanceAPI) GetBatch(ctx context.Context, seqNum uint64) (*BatchInfo, error) {
+	confirmedState, err := a.latestConfirmedState(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return a.getBatch(ctx, seqNum, confirmedState)
+}
+
+// GetBlockProvenance returns the batch that posted an L2 block to L1, and whether the block is confirmed
+func (a *BatchProvenanceAPI) GetBlockProvenance(ctx context.Context, l2Block uint64) (*BlockProvenance, error) {
+	genesis, err := a.txStreamer.GetGenesisBlockNumber()
+	if err != nil {
+		return nil, err
+	}
+	if l2Block <= genesis {
+		return nil, fmt.Errorf("block %v is part of genesis", l2Block)
+	}
+	pos, err := a.txStreamer.BlockNumberToMessageCount(l2Block)
+	if err != nil {
+		return nil, err
+	}
+	pos--
+	batchCount, err := a.inboxTracker.GetBatchCount()
+	if err != nil {
+		return nil, err
+	}
+	if batchCount == 0 {
+		return nil, fmt.Errorf("block %v hasn't been posted to L1", l2Block)
+	}
+	latestMessageCount, err := a.inboxTracker.GetBatchMessageCount(batchCount - 1)
+	if err != nil {
+		return nil, err
+	}
+	if pos >= latestMessageCount {
+		return nil, fmt.Errorf("block %v hasn't been posted to L1, which has messages up to %v", l2Block, latestMessageCount)
+	}
+	seqNum, err := validator.FindBatchContainingMessageIndex(a.inboxTracker, pos, batchCount-1)
+	if err != nil {
+		return nil, err
+	}
+
+	confirmedState, err := a.latestConfirmedState(ctx)
+	if err != nil {
+		return nil, err
+	}
+	batch, err := a.getBatch(ctx, seqNum, confirmedState)
+	if err != nil {
+		return nil, err
+	}
+	posInBatch := uint64(pos - batch.FirstMessage)
+	return &BlockProvenance{
+		L2Block:      l2Block,
+		MessageIndex: pos,
+		Batch:        batch,
+		Confirmed:    seqNum < confirmedState.Batch || (seqNum == confirmedState.Batch && posInBatch < confirmedState.PosInBatch),
+	}, nil
+}
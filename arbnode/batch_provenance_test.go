@@ -0,0 +1,38 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestBatchL1InfoCache(t *testing.T) {
+	api := NewBatchProvenanceAPI(nil, nil, nil, nil)
+	info := &batchL1Info{
+		accumulator:  common.Hash{1},
+		txHash:       common.Hash{2},
+		dataLocation: BatchSinkCalldata,
+	}
+	api.cacheBatchL1Info(5, info)
+
+	if api.cachedBatchL1Info(5, info.accumulator) != info {
+		Fail(t, "batch L1 info wasn't cached")
+	}
+	if api.cachedBatchL1Info(6, info.accumulator) != nil {
+		Fail(t, "found cached L1 info for another batch")
+	}
+	// a reorged batch has a different accumulator, so it's read from L1 again
+	if api.cachedBatchL1Info(5, common.Hash{3}) != nil {
+		Fail(t, "found cached L1 info for a reorged batch")
+	}
+
+	for i := uint64(0); i < batchL1InfoCacheSize; i++ {
+		api.cacheBatchL1Info(100+i, &batchL1Info{})
+	}
+	if api.cachedBatchL1Info(5, info.accumulator) != nil {
+		Fail(t, "the cache grew past its size")
+	}
+}
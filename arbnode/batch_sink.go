@@ -0,0 +1,116 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/offchainlabs/nitro/arbstate"
+	"github.com/offchainlabs/nitro/das"
+)
+
+const (
+	BatchSinkAuto     = "auto"
+	BatchSinkCalldata = "calldata"
+	BatchSinkDAS      = "das"
+	BatchSinkExternal = "external"
+)
+
+// BatchSink publishes a batch's data, and returns the sequencer message payload to post on chain in its place
+type BatchSink interface {
+	Name() string
+	PublishBatch(ctx context.Context, seqNum uint64, data []byte) ([]byte, error)
+}
+
+// CalldataBatchSink posts the data itself on chain
+type CalldataBatchSink struct{}
+
+func (CalldataBatchSink) Name() string {
+	return BatchSinkCalldata
+}
+
+func (CalldataBatchSink) PublishBatch(ctx context.Context, seqNum uint64, data []byte) ([]byte, error) {
+	return data, nil
+}
+
+// DASBatchSink stores the data with an AnyTrust DAS, and posts its certificate on chain
+type DASBatchSink struct {
+	writer          das.DataAvailabilityServiceWriter
+	retentionPeriod func() time.Duration
+}
+
+func NewDASBatchSink(writer das.DataAvailabilityServiceWriter, retentionPeriod func() time.Duration) *DASBatchSink {
+	return &DASBatchSink{writer: writer, retentionPeriod: retentionPeriod}
+}
+
+func (s *DASBatchSink) Name() string {
+	return BatchSinkDAS
+}
+
+func (s *DASBatchSink) PublishBatch(ctx context.Context, seqNum uint64, data []byte) ([]byte, error) {
+	timeout := uint64(time.Now().Add(s.retentionPeriod()).Unix())
+	cert, err := s.writer.Store(ctx, data, timeout, []byte{}) // the writer will append signature if enabled
+	if err != nil {
+		return nil, err
+	}
+	return das.Serialize(cert), nil
+}
+
+// ExternalDABatchSink stores the data with an experimental external data availability layer,
+// and posts the data hash and the layer's commitment on chain
+type ExternalDABatchSink struct {
+	da     das.ExternalDA
+	header byte
+}
+
+func NewExternalDABatchSink(da das.ExternalDA, header byte) (*ExternalDABatchSink, error) {
+	if !arbstate.IsExternalDAMessageHeaderByte(header) {
+		return nil, fmt.Errorf("invalid external data availability header byte %#x", header)
+	}
+	return &ExternalDABatchSink{da: da, header: header}, nil
+}
+
+func (s *ExternalDABatchSink) Name() string {
+	return BatchSinkExternal
+}
+
+func (s *ExternalDABatchSink) PublishBatch(ctx context.Context, seqNum uint64, data []byte) ([]byte, error) {
+	commitment, err := s.da.Store(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+	return arbstate.SerializeExternalDACommitment(s.header, crypto.Keccak256Hash(data), commitment), nil
+}
+
+func newBatchSink(name string, daWriter das.DataAvailabilityServiceWriter, externalDA das.ExternalDA, externalDAHeader byte, retentionPeriod func() time.Duration) (BatchSink, error) {
+	if name == BatchSinkAuto {
+		// The sink used before sinks were configurable
+		if daWriter != nil {
+			name = BatchSinkDAS
+		} else {
+			name = BatchSinkCalldata
+		}
+	}
+	switch name {
+	case BatchSinkCalldata:
+		return CalldataBatchSink{}, nil
+	case BatchSinkDAS:
+		if daWriter == nil {
+			return nil, errors.New("batch poster das sink requires data availability to be enabled")
+		}
+		return NewDASBatchSink(daWriter, retentionPeriod), nil
+	case BatchSinkExternal:
+		if externalDA == nil {
+			return nil, errors.New("batch poster external sink requires an external data availability mode")
+		}
+		return NewExternalDABatchSink(externalDA, externalDAHeader)
+	default:
+		return nil, fmt.Errorf("unknown batch poster sink \"%v\"", name)
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/go-redis/redis/v8"
@@ -48,15 +49,23 @@ type DataPosterConfig struct {
 	L1LookBehind      uint64                     `koanf:"l1-look-behind" reload:"hot"`
 	MaxFeeCapGwei     float64                    `koanf:"max-fee-cap-gwei" reload:"hot"`
 	MaxFeeCapDoubling time.Duration              `koanf:"max-fee-cap-doubling" reload:"hot"`
+	UseDBStorage      bool                       `koanf:"use-db-storage"`
+	FeeStrategy       string                     `koanf:"fee-strategy" reload:"hot"`
+	FeeHistory        FeeHistoryConfig           `koanf:"fee-history" reload:"hot"`
+	Urgency           UrgencyConfig              `koanf:"urgency" reload:"hot"`
 }
 
 type DataPosterConfigFetcher func() *DataPosterConfig
 
-func DataPosterConfigAddOptions(prefix string, f *flag.FlagSet) {
-	f.String(prefix+".replacement-times", DefaultDataPosterConfig.ReplacementTimes, "comma-separated list of durations since first posting to attempt a replace-by-fee")
-	f.Uint64(prefix+".l1-look-behind", DefaultDataPosterConfig.L1LookBehind, "look at state this many blocks behind the latest (fixes L1 node inconsistencies)")
-	f.Float64(prefix+".max-fee-cap-gwei", DefaultDataPosterConfig.MaxFeeCapGwei, "the maximum fee cap to use, doubled every max-fee-cap-doubling")
-	f.Duration(prefix+".max-fee-cap-doubling", DefaultDataPosterConfig.MaxFeeCapDoubling, "after this duration, double the fee cap (repeats)")
+func DataPosterConfigAddOptions(prefix string, f *flag.FlagSet, defaultConfig DataPosterConfig) {
+	f.String(prefix+".replacement-times", defaultConfig.ReplacementTimes, "comma-separated list of durations since first posting to attempt a replace-by-fee")
+	f.Uint64(prefix+".l1-look-behind", defaultConfig.L1LookBehind, "look at state this many blocks behind the latest (fixes L1 node inconsistencies)")
+	f.Float64(prefix+".max-fee-cap-gwei", defaultConfig.MaxFeeCapGwei, "the maximum fee cap to use, doubled every max-fee-cap-doubling")
+	f.Duration(prefix+".max-fee-cap-doubling", defaultConfig.MaxFeeCapDoubling, "after this duration, double the fee cap (repeats)")
+	f.Bool(prefix+".use-db-storage", defaultConfig.UseDBStorage, "store queued transactions in the node's database, so they survive restarts without redis")
+	f.String(prefix+".fee-strategy", defaultConfig.FeeStrategy, "strategy to pick fee and tip caps with: \"default\", \"fee-history\" or \"urgency\"")
+	FeeHistoryConfigAddOptions(prefix+".fee-history", f)
+	UrgencyConfigAddOptions(prefix+".urgency", f)
 	signature.SimpleHmacConfigAddOptions(prefix+".redis-signer", f)
 }
 
@@ -65,6 +74,9 @@ var DefaultDataPosterConfig = DataPosterConfig{
 	L1LookBehind:      2,
 	MaxFeeCapGwei:     100.,
 	MaxFeeCapDoubling: 2 * time.Hour,
+	FeeStrategy:       FeeStrategyDefault,
+	FeeHistory:        DefaultFeeHistoryConfig,
+	Urgency:           DefaultUrgencyConfig,
 }
 
 var TestDataPosterConfig = DataPosterConfig{
@@ -73,6 +85,9 @@ var TestDataPosterConfig = DataPosterConfig{
 	L1LookBehind:      0,
 	MaxFeeCapGwei:     100.,
 	MaxFeeCapDoubling: 5 * time.Second,
+	FeeStrategy:       FeeStrategyDefault,
+	FeeHistory:        DefaultFeeHistoryConfig,
+	Urgency:           DefaultUrgencyConfig,
 }
 
 // DataPoster must be RLP serializable and deserializable
@@ -85,6 +100,8 @@ type DataPoster[Meta any] struct {
 	config            DataPosterConfigFetcher
 	replacementTimes  []time.Duration
 	metadataRetriever func(ctx context.Context, blockNum *big.Int) (Meta, error)
+	// if set, reads the window the urgency fee strategy must post within from L1
+	urgencyWindowFetcher func(ctx context.Context) (time.Duration, error)
 
 	// these fields are protected by the mutex
 	mutex      sync.Mutex
@@ -99,7 +116,10 @@ type AttemptLocker interface {
 	AttemptLock(context.Context) bool
 }
 
-func NewDataPoster[Meta any](headerReader *headerreader.HeaderReader, auth *bind.TransactOpts, redisClient redis.UniversalClient, redisLock AttemptLocker, config DataPosterConfigFetcher, metadataRetriever func(ctx context.Context, blockNum *big.Int) (Meta, error)) (*DataPoster[Meta], error) {
+func NewDataPoster[Meta any](db ethdb.Database, headerReader *headerreader.HeaderReader, auth *bind.TransactOpts, redisClient redis.UniversalClient, redisLock AttemptLocker, config DataPosterConfigFetcher, metadataRetriever func(ctx context.Context, blockNum *big.Int) (Meta, error)) (*DataPoster[Meta], error) {
+	if err := validateFeeStrategy(config()); err != nil {
+		return nil, err
+	}
 	var replacementTimes []time.Duration
 	var lastReplacementTime time.Duration
 	for _, s := range strings.Split(config().ReplacementTimes, ",") {
@@ -119,7 +139,15 @@ func NewDataPoster[Meta any](headerReader *headerreader.HeaderReader, auth *bind
 	// To avoid special casing "don't replace again", replace in 10 years
 	replacementTimes = append(replacementTimes, time.Hour*24*365*10)
 	var queue QueueStorage[queuedTransaction[Meta]]
-	if redisClient == nil {
+	if config().UseDBStorage {
+		if redisClient != nil {
+			return nil, errors.New("data poster can't use both redis and database storage")
+		}
+		if db == nil {
+			return nil, errors.New("data poster database storage enabled without a database")
+		}
+		queue = NewDatabaseStorage[queuedTransaction[Meta]](db)
+	} else if redisClient == nil {
 		queue = NewSliceStorage[queuedTransaction[Meta]]()
 	} else {
 		var err error
@@ -141,6 +169,12 @@ func NewDataPoster[Meta any](headerReader *headerreader.HeaderReader, auth *bind
 	}, nil
 }
 
+// SetUrgencyWindowFetcher makes the urgency fee strategy read its window from L1 instead of its config.
+// It must be called before the data poster is started.
+func (p *DataPoster[Meta]) SetUrgencyWindowFetcher(fetcher func(ctx context.Context) (time.Duration, error)) {
+	p.urgencyWindowFetcher = fetcher
+}
+
 func (p *DataPoster[Meta]) From() common.Address {
 	return p.auth.From
 }
@@ -164,6 +198,59 @@ func (p *DataPoster[Meta]) GetNextNonceAndMeta(ctx context.Context) (uint64, Met
 	return p.nonce, meta, err
 }
 
+// HasPendingTransactions updates the confirmed nonce, and returns whether any queued transactions are still unconfirmed
+func (p *DataPoster[Meta]) HasPendingTransactions(ctx context.Context) (bool, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	err := p.updateState(ctx)
+	if err != nil {
+		return false, err
+	}
+	lastQueueItem, err := p.queue.GetLast(ctx)
+	if err != nil {
+		return false, err
+	}
+	return lastQueueItem != nil && lastQueueItem.Data.Nonce >= p.nonce, nil
+}
+
+// QueuedTransactionInfo describes a transaction the data poster hasn't seen confirmed yet
+type QueuedTransactionInfo[Meta any] struct {
+	Nonce           uint64
+	Hash            common.Hash
+	FeeCap          *big.Int
+	TipCap          *big.Int
+	Gas             uint64
+	Meta            Meta
+	Sent            bool
+	Created         time.Time
+	NextReplacement time.Time
+}
+
+// QueuedTransactions returns the confirmed nonce, and the transactions queued after it
+func (p *DataPoster[Meta]) QueuedTransactions(ctx context.Context) (uint64, []QueuedTransactionInfo[Meta], error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	queueContents, err := p.queue.GetContents(ctx, p.nonce, maxTxsToRbf)
+	if err != nil {
+		return 0, nil, err
+	}
+	infos := make([]QueuedTransactionInfo[Meta], 0, len(queueContents))
+	for _, tx := range queueContents {
+		infos = append(infos, QueuedTransactionInfo[Meta]{
+			Nonce:           tx.Data.Nonce,
+			Hash:            tx.FullTx.Hash(),
+			FeeCap:          tx.Data.GasFeeCap,
+			TipCap:          tx.Data.GasTipCap,
+			Gas:             tx.Data.Gas,
+			Meta:            tx.Meta,
+			Sent:            tx.Sent,
+			Created:         tx.Created,
+			NextReplacement: tx.NextReplacement,
+		})
+	}
+	return p.nonce, infos, nil
+}
+
 const minRbfIncrease arbmath.Bips = arbmath.OneInBips * 11 / 10
 
 func (p *DataPoster[Meta]) getFeeAndTipCaps(ctx context.Context, lastTipCap *big.Int, dataCreatedAt time.Time) (*big.Int, *big.Int, error) {
@@ -171,18 +258,27 @@ func (p *DataPoster[Meta]) getFeeAndTipCaps(ctx context.Context, lastTipCap *big
 	if err != nil {
 		return nil, nil, err
 	}
-	newTipCap, err := p.client.SuggestGasTipCap(ctx)
+	config := p.config()
+	var urgencyWindow time.Duration
+	if config.FeeStrategy == FeeStrategyUrgency && p.urgencyWindowFetcher != nil {
+		urgencyWindow, err = p.urgencyWindowFetcher(ctx)
+		if err != nil {
+			log.Warn("failed to read the urgency window, using the configured window", "window", config.Urgency.Window, "err", err)
+			urgencyWindow = 0
+		}
+	}
+	strategy, strategyName := newFeeStrategy(config, urgencyWindow)
+	newTipCap, newFeeCap, err := strategy.SuggestCaps(ctx, p.client, latestHeader, dataCreatedAt)
 	if err != nil {
 		return nil, nil, err
 	}
+	suggestedTipCap := newTipCap
 	if lastTipCap != nil {
 		newTipCap = arbmath.BigMax(newTipCap, arbmath.BigMulByBips(lastTipCap, minRbfIncrease))
 	}
-	newFeeCap := new(big.Int).Mul(latestHeader.BaseFee, big.NewInt(2))
 	newFeeCap.Add(newFeeCap, newTipCap)
 
 	elapsed := time.Since(dataCreatedAt)
-	config := p.config()
 	maxFeeCap := new(big.Int).SetUint64(uint64(config.MaxFeeCapGwei * params.GWei))
 	maxFeeCapDoublings := int64(elapsed / config.MaxFeeCapDoubling)
 	// in tests, this could get way too big
@@ -191,6 +287,9 @@ func (p *DataPoster[Meta]) getFeeAndTipCaps(ctx context.Context, lastTipCap *big
 	}
 	multiplier := new(big.Int).Exp(big.NewInt(2), big.NewInt(maxFeeCapDoublings), nil)
 	maxFeeCap.Mul(maxFeeCap, multiplier)
+	if scaler, ok := strategy.(maxFeeCapScaler); ok {
+		maxFeeCap = scaler.scaleMaxFeeCap(maxFeeCap, dataCreatedAt)
+	}
 	if arbmath.BigGreaterThan(newFeeCap, maxFeeCap) {
 		logLevel := log.Info
 		if maxFeeCapDoublings >= 3 {
@@ -207,15 +306,31 @@ func (p *DataPoster[Meta]) getFeeAndTipCaps(ctx context.Context, lastTipCap *big
 		newFeeCap = maxFeeCap
 	}
 
+	log.Debug(
+		"DataPoster fee decision",
+		"strategy", strategyName,
+		"baseFee", latestHeader.BaseFee,
+		"suggestedTipCap", suggestedTipCap,
+		"lastTipCap", lastTipCap,
+		"tipCap", newTipCap,
+		"feeCap", newFeeCap,
+		"elapsed", elapsed,
+	)
+	recordFeeDecision(strategyName, newFeeCap, newTipCap)
 	return newFeeCap, newTipCap, nil
 }
 
-func (p *DataPoster[Meta]) PostTransaction(ctx context.Context, dataCreatedAt time.Time, nonce uint64, meta Meta, to common.Address, calldata []byte, gasLimit uint64) error {
+// PostTransaction queues and sends a transaction, returning the signed transaction as first sent.
+// The value may be nil, and is kept when the transaction is replaced by fee.
+func (p *DataPoster[Meta]) PostTransaction(ctx context.Context, dataCreatedAt time.Time, nonce uint64, meta Meta, to common.Address, calldata []byte, gasLimit uint64, value *big.Int) (*types.Transaction, error) {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
 	feeCap, tipCap, err := p.getFeeAndTipCaps(ctx, nil, dataCreatedAt)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if value == nil {
+		value = new(big.Int)
 	}
 	inner := types.DynamicFeeTx{
 		Nonce:     nonce,
@@ -223,12 +338,12 @@ func (p *DataPoster[Meta]) PostTransaction(ctx context.Context, dataCreatedAt ti
 		GasFeeCap: feeCap,
 		Gas:       gasLimit,
 		To:        &to,
-		Value:     new(big.Int),
+		Value:     value,
 		Data:      calldata,
 	}
 	fullTx, err := p.auth.Signer(p.auth.From, types.NewTx(&inner))
 	if err != nil {
-		return err
+		return nil, err
 	}
 	queuedTx := queuedTransaction[Meta]{
 		Data:            inner,
@@ -238,7 +353,10 @@ func (p *DataPoster[Meta]) PostTransaction(ctx context.Context, dataCreatedAt ti
 		Created:         dataCreatedAt,
 		NextReplacement: time.Now().Add(p.replacementTimes[0]),
 	}
-	return p.sendTx(ctx, nil, &queuedTx)
+	if err := p.sendTx(ctx, nil, &queuedTx); err != nil {
+		return nil, err
+	}
+	return fullTx, nil
 }
 
 // the mutex must be held by the caller
@@ -281,7 +399,9 @@ func (p *DataPoster[Meta]) replaceTx(ctx context.Context, prevTx *queuedTransact
 	}
 
 	desiredFeeCap := newFeeCap
-	maxFeeCap := new(big.Int).Div(p.balance, new(big.Int).SetUint64(prevTx.Data.Gas))
+	// The value is paid from the same balance as the fees
+	balanceForFees := arbmath.BigSub(p.balance, prevTx.Data.Value)
+	maxFeeCap := new(big.Int).Div(balanceForFees, new(big.Int).SetUint64(prevTx.Data.Gas))
 	newFeeCap = arbmath.BigMin(newFeeCap, maxFeeCap)
 	minNewFeeCap := arbmath.BigMulByBips(prevTx.Data.GasFeeCap, minRbfIncrease)
 	newTx := *prevTx
@@ -0,0 +1,138 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package dataposter
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// DatabaseStorage stores queued items in a key-value database, keyed by their big-endian index.
+// It requires that Item is RLP encodable/decodable, and that nothing else writes to the database.
+type DatabaseStorage[Item any] struct {
+	db    ethdb.KeyValueStore
+	mutex sync.Mutex
+}
+
+func NewDatabaseStorage[Item any](db ethdb.KeyValueStore) *DatabaseStorage[Item] {
+	return &DatabaseStorage[Item]{db: db}
+}
+
+func dbStorageKey(index uint64) []byte {
+	var key [8]byte
+	binary.BigEndian.PutUint64(key[:], index)
+	return key[:]
+}
+
+func (s *DatabaseStorage[Item]) decodeItem(data []byte) (*Item, error) {
+	var item Item
+	if err := rlp.DecodeBytes(data, &item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+func (s *DatabaseStorage[Item]) GetContents(ctx context.Context, startingIndex uint64, maxResults uint64) ([]*Item, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	iter := s.db.NewIterator(nil, dbStorageKey(startingIndex))
+	defer iter.Release()
+	var items []*Item
+	for uint64(len(items)) < maxResults && iter.Next() {
+		item, err := s.decodeItem(iter.Value())
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, iter.Error()
+}
+
+func (s *DatabaseStorage[Item]) GetLast(ctx context.Context) (*Item, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	// The queue is pruned as transactions are confirmed, so it's short enough to scan
+	iter := s.db.NewIterator(nil, nil)
+	defer iter.Release()
+	var lastValue []byte
+	for iter.Next() {
+		lastValue = iter.Value()
+	}
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+	if lastValue == nil {
+		return nil, nil
+	}
+	return s.decodeItem(lastValue)
+}
+
+func (s *DatabaseStorage[Item]) Prune(ctx context.Context, keepStartingAt uint64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	endKey := dbStorageKey(keepStartingAt)
+	iter := s.db.NewIterator(nil, nil)
+	defer iter.Release()
+	batch := s.db.NewBatch()
+	for iter.Next() {
+		if bytes.Compare(iter.Key(), endKey) >= 0 {
+			break
+		}
+		if err := batch.Delete(iter.Key()); err != nil {
+			return err
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	return batch.Write()
+}
+
+func (s *DatabaseStorage[Item]) Put(ctx context.Context, index uint64, prevItem *Item, newItem *Item) error {
+	if newItem == nil {
+		return fmt.Errorf("tried to insert nil item at index %v", index)
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	key := dbStorageKey(index)
+	has, err := s.db.Has(key)
+	if err != nil {
+		return err
+	}
+	if !has {
+		if prevItem != nil {
+			return fmt.Errorf("%w: tried to replace item at index %v but no item exists there", StorageRaceErr, index)
+		}
+	} else {
+		if prevItem == nil {
+			return fmt.Errorf("%w: tried to insert new item at index %v but an item exists there", StorageRaceErr, index)
+		}
+		haveItemEncoded, err := s.db.Get(key)
+		if err != nil {
+			return err
+		}
+		prevItemEncoded, err := rlp.EncodeToBytes(prevItem)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(haveItemEncoded, prevItemEncoded) {
+			return fmt.Errorf("%w: replacing different item than expected at index %v", StorageRaceErr, index)
+		}
+	}
+	newItemEncoded, err := rlp.EncodeToBytes(newItem)
+	if err != nil {
+		return err
+	}
+	batch := s.db.NewBatch()
+	if err := batch.Put(key, newItemEncoded); err != nil {
+		return err
+	}
+	return batch.Write()
+}
@@ -0,0 +1,58 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package dataposter
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/rawdb"
+)
+
+type testQueueItem struct {
+	Nonce uint64
+	Data  []byte
+}
+
+func TestDatabaseStorage(t *testing.T) {
+	ctx := context.Background()
+	db := rawdb.NewMemoryDatabase()
+	storage := NewDatabaseStorage[testQueueItem](db)
+
+	for nonce := uint64(5); nonce < 10; nonce++ {
+		if err := storage.Put(ctx, nonce, nil, &testQueueItem{Nonce: nonce}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := storage.Put(ctx, 7, nil, &testQueueItem{Nonce: 7}); !errors.Is(err, StorageRaceErr) {
+		t.Fatal("expected a storage race inserting over an existing item, got", err)
+	}
+	if err := storage.Put(ctx, 7, &testQueueItem{Nonce: 7, Data: []byte{1}}, &testQueueItem{Nonce: 7}); !errors.Is(err, StorageRaceErr) {
+		t.Fatal("expected a storage race replacing a different item, got", err)
+	}
+	if err := storage.Put(ctx, 7, &testQueueItem{Nonce: 7}, &testQueueItem{Nonce: 7, Data: []byte{2}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new storage on the same database sees the same queue, as it would after a restart
+	storage = NewDatabaseStorage[testQueueItem](db)
+	last, err := storage.GetLast(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last == nil || last.Nonce != 9 {
+		t.Fatal("unexpected last item", last)
+	}
+	if err := storage.Prune(ctx, 7); err != nil {
+		t.Fatal(err)
+	}
+	contents, err := storage.GetContents(ctx, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(contents) != 2 || contents[0].Nonce != 7 || len(contents[0].Data) != 1 || contents[1].Nonce != 8 {
+		t.Fatal("unexpected queue contents after pruning", contents)
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package dataposter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+
+	"github.com/offchainlabs/nitro/arbutil"
+	"github.com/offchainlabs/nitro/util/arbmath"
+)
+
+const (
+	FeeStrategyDefault    = "default"
+	FeeStrategyFeeHistory = "fee-history"
+	FeeStrategyUrgency    = "urgency"
+)
+
+var (
+	feeCapGauge = metrics.NewRegisteredGauge("arb/dataposter/fee/feecap", nil)
+	tipCapGauge = metrics.NewRegisteredGauge("arb/dataposter/fee/tipcap", nil)
+)
+
+// FeeStrategy suggests the caps to post a transaction with.
+// The data poster adds the tip cap to the base fee cap, after raising the tip cap as needed to replace by fee,
+// and then limits the result to the configured maximum fee cap.
+type FeeStrategy interface {
+	SuggestCaps(ctx context.Context, client arbutil.L1Interface, latestHeader *types.Header, dataCreatedAt time.Time) (tipCap *big.Int, baseFeeCap *big.Int, err error)
+}
+
+type FeeHistoryConfig struct {
+	Blocks     uint64  `koanf:"blocks" reload:"hot"`
+	Percentile float64 `koanf:"percentile" reload:"hot"`
+}
+
+func FeeHistoryConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Uint64(prefix+".blocks", DefaultFeeHistoryConfig.Blocks, "number of recent L1 blocks to take tips from")
+	f.Float64(prefix+".percentile", DefaultFeeHistoryConfig.Percentile, "percentile of tips paid in each block to use")
+}
+
+var DefaultFeeHistoryConfig = FeeHistoryConfig{
+	Blocks:     20,
+	Percentile: 50,
+}
+
+type UrgencyConfig struct {
+	Window        time.Duration `koanf:"window" reload:"hot"`
+	MaxMultiplier float64       `koanf:"max-multiplier" reload:"hot"`
+}
+
+func UrgencyConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Duration(prefix+".window", DefaultUrgencyConfig.Window, "time after the data was created by which it must be posted, used when the poster doesn't read it from L1 (the batch poster uses the sequencer inbox's force inclusion delay)")
+	f.Float64(prefix+".max-multiplier", DefaultUrgencyConfig.MaxMultiplier, "multiplier applied to the suggested caps when the window has elapsed")
+}
+
+var DefaultUrgencyConfig = UrgencyConfig{
+	Window:        time.Hour * 24,
+	MaxMultiplier: 4,
+}
+
+func validateFeeStrategy(config *DataPosterConfig) error {
+	switch config.FeeStrategy {
+	case FeeStrategyDefault:
+	case FeeStrategyFeeHistory:
+		if config.FeeHistory.Blocks == 0 || config.FeeHistory.Percentile < 0 || config.FeeHistory.Percentile > 100 {
+			return errors.New("invalid fee history strategy config")
+		}
+	case FeeStrategyUrgency:
+		if config.Urgency.Window <= 0 || config.Urgency.MaxMultiplier < 1 {
+			return errors.New("invalid urgency fee strategy config")
+		}
+	default:
+		return fmt.Errorf("unknown data poster fee strategy \"%v\"", config.FeeStrategy)
+	}
+	return nil
+}
+
+// newFeeStrategy is called for each decision, so config changes take effect immediately.
+// The urgency strategy uses urgencyWindow rather than its configured window.
+func newFeeStrategy(config *DataPosterConfig, urgencyWindow time.Duration) (FeeStrategy, string) {
+	if err := validateFeeStrategy(config); err != nil {
+		log.Error("invalid data poster fee strategy config, using the default strategy", "err", err)
+		return defaultStrategy{}, FeeStrategyDefault
+	}
+	switch config.FeeStrategy {
+	case FeeStrategyFeeHistory:
+		return &feeHistoryStrategy{config.FeeHistory}, config.FeeStrategy
+	case FeeStrategyUrgency:
+		urgencyConfig := config.Urgency
+		if urgencyWindow > 0 {
+			urgencyConfig.Window = urgencyWindow
+		}
+		return &urgencyStrategy{urgencyConfig}, config.FeeStrategy
+	default:
+		return defaultStrategy{}, FeeStrategyDefault
+	}
+}
+
+// maxFeeCapScaler is implemented by strategies that need to exceed the configured maximum fee cap
+type maxFeeCapScaler interface {
+	scaleMaxFeeCap(maxFeeCap *big.Int, dataCreatedAt time.Time) *big.Int
+}
+
+// defaultStrategy uses the L1 node's suggested tip, and allows the base fee to double
+type defaultStrategy struct{}
+
+func (defaultStrategy) SuggestCaps(ctx context.Context, client arbutil.L1Interface, latestHeader *types.Header, dataCreatedAt time.Time) (*big.Int, *big.Int, error) {
+	tipCap, err := client.SuggestGasTipCap(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tipCap, new(big.Int).Mul(latestHeader.BaseFee, big.NewInt(2)), nil
+}
+
+type feeHistoryClient interface {
+	FeeHistory(ctx context.Context, blockCount uint64, lastBlock *big.Int, rewardPercentiles []float64) (*ethereum.FeeHistory, error)
+}
+
+// feeHistoryStrategy uses the average of a percentile of the tips paid over recent blocks
+type feeHistoryStrategy struct {
+	config FeeHistoryConfig
+}
+
+func (s *feeHistoryStrategy) SuggestCaps(ctx context.Context, client arbutil.L1Interface, latestHeader *types.Header, dataCreatedAt time.Time) (*big.Int, *big.Int, error) {
+	historyClient, ok := client.(feeHistoryClient)
+	if !ok {
+		return nil, nil, errors.New("L1 client doesn't support fee history")
+	}
+	history, err := historyClient.FeeHistory(ctx, s.config.Blocks, latestHeader.Number, []float64{s.config.Percentile})
+	if err != nil {
+		return nil, nil, err
+	}
+	total := new(big.Int)
+	count := int64(0)
+	for _, rewards := range history.Reward {
+		if len(rewards) > 0 && rewards[0] != nil {
+			total.Add(total, rewards[0])
+			count++
+		}
+	}
+	if count == 0 {
+		// No transactions paid tips recently, so fall back to the node's suggestion
+		return defaultStrategy{}.SuggestCaps(ctx, client, latestHeader, dataCreatedAt)
+	}
+	tipCap := total.Div(total, big.NewInt(count))
+	return tipCap, new(big.Int).Mul(latestHeader.BaseFee, big.NewInt(2)), nil
+}
+
+// urgencyStrategy scales up the default caps as the data approaches the end of the window it must be posted in
+type urgencyStrategy struct {
+	config UrgencyConfig
+}
+
+func (s *urgencyStrategy) multiplier(elapsed time.Duration) float64 {
+	urgency := float64(elapsed) / float64(s.config.Window)
+	if urgency > 1 {
+		urgency = 1
+	} else if urgency < 0 {
+		urgency = 0
+	}
+	// Stay close to the default caps until the window is mostly used up
+	return 1 + (s.config.MaxMultiplier-1)*math.Pow(urgency, 2)
+}
+
+func (s *urgencyStrategy) multiplierBips(dataCreatedAt time.Time) arbmath.Bips {
+	return arbmath.Bips(s.multiplier(time.Since(dataCreatedAt)) * float64(arbmath.OneInBips))
+}
+
+func (s *urgencyStrategy) SuggestCaps(ctx context.Context, client arbutil.L1Interface, latestHeader *types.Header, dataCreatedAt time.Time) (*big.Int, *big.Int, error) {
+	tipCap, baseFeeCap, err := defaultStrategy{}.SuggestCaps(ctx, client, latestHeader, dataCreatedAt)
+	if err != nil {
+		return nil, nil, err
+	}
+	bips := s.multiplierBips(dataCreatedAt)
+	return arbmath.BigMulByBips(tipCap, bips), arbmath.BigMulByBips(baseFeeCap, bips), nil
+}
+
+// scaleMaxFeeCap raises the maximum fee cap along with the caps, or it'd clamp away the urgency
+func (s *urgencyStrategy) scaleMaxFeeCap(maxFeeCap *big.Int, dataCreatedAt time.Time) *big.Int {
+	return arbmath.BigMulByBips(maxFeeCap, s.multiplierBips(dataCreatedAt))
+}
+
+func recordFeeDecision(strategy string, feeCap *big.Int, tipCap *big.Int) {
+	metrics.GetOrRegisterCounter("arb/dataposter/fee/strategy/"+strategy, nil).Inc(1)
+	if feeCap.IsInt64() {
+		feeCapGauge.Update(feeCap.Int64())
+	}
+	if tipCap.IsInt64() {
+		tipCapGauge.Update(tipCap.Int64())
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package dataposter
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+
+	"github.com/offchainlabs/nitro/arbutil"
+)
+
+func TestUrgencyMultiplier(t *testing.T) {
+	strategy := &urgencyStrategy{DefaultUrgencyConfig}
+	if m := strategy.multiplier(0); m != 1 {
+		t.Fatal("expected no increase for new data, got", m)
+	}
+	half := strategy.multiplier(DefaultUrgencyConfig.Window / 2)
+	if half <= 1 || half >= DefaultUrgencyConfig.MaxMultiplier {
+		t.Fatal("expected an intermediate multiplier halfway through the window, got", half)
+	}
+	if m := strategy.multiplier(DefaultUrgencyConfig.Window * 2); m != DefaultUrgencyConfig.MaxMultiplier {
+		t.Fatal("expected the max multiplier past the window, got", m)
+	}
+}
+
+func TestFeeStrategySelection(t *testing.T) {
+	config := DefaultDataPosterConfig
+	for _, name := range []string{FeeStrategyDefault, FeeStrategyFeeHistory, FeeStrategyUrgency} {
+		config.FeeStrategy = name
+		if _, selected := newFeeStrategy(&config, 0); selected != name {
+			t.Fatal("selected strategy", selected, "instead of", name)
+		}
+	}
+	config.FeeStrategy = "unknown"
+	if validateFeeStrategy(&config) == nil {
+		t.Fatal("unknown strategy passed validation")
+	}
+	if _, selected := newFeeStrategy(&config, 0); selected != FeeStrategyDefault {
+		t.Fatal("invalid strategy didn't fall back to the default, got", selected)
+	}
+	config.FeeStrategy = FeeStrategyUrgency
+	config.Urgency.Window = -time.Second
+	if validateFeeStrategy(&config) == nil {
+		t.Fatal("negative urgency window passed validation")
+	}
+}
+
+func TestUrgencyWindowFromL1(t *testing.T) {
+	config := DefaultDataPosterConfig
+	config.FeeStrategy = FeeStrategyUrgency
+	strategy, _ := newFeeStrategy(&config, time.Hour)
+	urgency, ok := strategy.(*urgencyStrategy)
+	if !ok || urgency.config.Window != time.Hour {
+		t.Fatal("urgency strategy didn't use the window read from L1", strategy)
+	}
+	strategy, _ = newFeeStrategy(&config, 0)
+	if urgency := strategy.(*urgencyStrategy); urgency.config.Window != config.Urgency.Window {
+		t.Fatal("urgency strategy didn't fall back to the configured window", urgency.config.Window)
+	}
+
+	// Past the window, the maximum fee cap is raised as much as the caps are
+	maxFeeCap := big.NewInt(100 * params.GWei)
+	scaled := urgency.scaleMaxFeeCap(maxFeeCap, time.Now().Add(-2*time.Hour))
+	expected := big.NewInt(int64(100 * params.GWei * config.Urgency.MaxMultiplier))
+	if scaled.Cmp(expected) != 0 {
+		t.Fatal("max fee cap scaled to", scaled, "instead of", expected)
+	}
+	if scaled := urgency.scaleMaxFeeCap(maxFeeCap, time.Now()); scaled.Cmp(maxFeeCap) != 0 {
+		t.Fatal("max fee cap scaled for new data to", scaled)
+	}
+}
+
+type feeHistoryTestClient struct {
+	arbutil.L1Interface
+	rewards            [][]*big.Int
+	suggestedTipCap    *big.Int
+	requestedBlocks    uint64
+	requestedLastBlock *big.Int
+}
+
+func (c *feeHistoryTestClient) FeeHistory(ctx context.Context, blockCount uint64, lastBlock *big.Int, rewardPercentiles []float64) (*ethereum.FeeHistory, error) {
+	c.requestedBlocks = blockCount
+	c.requestedLastBlock = lastBlock
+	return &ethereum.FeeHistory{Reward: c.rewards}, nil
+}
+
+func (c *feeHistoryTestClient) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return c.suggestedTipCap, nil
+}
+
+func TestFeeHistoryAveraging(t *testing.T) {
+	ctx := context.Background()
+	header := &types.Header{Number: big.NewInt(1000), BaseFee: big.NewInt(30 * params.GWei)}
+	strategy := &feeHistoryStrategy{DefaultFeeHistoryConfig}
+	client := &feeHistoryTestClient{
+		// Blocks without tips are left out of the average
+		rewards: [][]*big.Int{
+			{big.NewInt(1 * params.GWei)},
+			{},
+			{big.NewInt(3 * params.GWei)},
+			{nil},
+			{big.NewInt(5 * params.GWei)},
+		},
+		suggestedTipCap: big.NewInt(7 * params.GWei),
+	}
+	tipCap, baseFeeCap, err := strategy.SuggestCaps(ctx, client, header, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tipCap.Cmp(big.NewInt(3*params.GWei)) != 0 {
+		t.Fatal("expected the average tip of 3 gwei, got", tipCap)
+	}
+	if baseFeeCap.Cmp(big.NewInt(60*params.GWei)) != 0 {
+		t.Fatal("expected the base fee cap to be twice the base fee, got", baseFeeCap)
+	}
+	if client.requestedBlocks != DefaultFeeHistoryConfig.Blocks || client.requestedLastBlock.Cmp(header.Number) != 0 {
+		t.Fatal("fee history requested for", client.requestedBlocks, "blocks up to", client.requestedLastBlock)
+	}
+
+	// Without any tips paid, the node's suggestion is used
+	client.rewards = [][]*big.Int{{}, {nil}}
+	tipCap, _, err = strategy.SuggestCaps(ctx, client, header, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tipCap.Cmp(client.suggestedTipCap) != 0 {
+		t.Fatal("expected the suggested tip cap without fee history, got", tipCap)
+	}
+}
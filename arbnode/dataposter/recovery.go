@@ -0,0 +1,183 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package dataposter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+
+	"github.com/offchainlabs/nitro/util/arbmath"
+)
+
+var ErrNotLocked = errors.New("data poster doesn't hold the lock, so can't modify its queue")
+
+// lockForAdmin acquires the mutex, and checks that this data poster holds the redis lock
+func (p *DataPoster[Meta]) lockForAdmin(ctx context.Context) error {
+	p.mutex.Lock()
+	if !p.redisLock.AttemptLock(ctx) {
+		p.mutex.Unlock()
+		return ErrNotLocked
+	}
+	if err := p.updateState(ctx); err != nil {
+		p.mutex.Unlock()
+		return err
+	}
+	return nil
+}
+
+// the mutex must be held by the caller
+func (p *DataPoster[Meta]) getQueuedTx(ctx context.Context, nonce uint64) (*queuedTransaction[Meta], error) {
+	if nonce < p.nonce {
+		return nil, fmt.Errorf("nonce %v is already confirmed, the next nonce is %v", nonce, p.nonce)
+	}
+	contents, err := p.queue.GetContents(ctx, nonce, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(contents) == 0 || contents[0].Data.Nonce != nonce {
+		return nil, nil
+	}
+	return contents[0], nil
+}
+
+// the mutex must be held by the caller
+func (p *DataPoster[Meta]) manualReplacementCaps(ctx context.Context, prevTx *types.DynamicFeeTx, feeCap *big.Int) (*big.Int, error) {
+	tipCap, err := p.client.SuggestGasTipCap(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if prevTx != nil {
+		minFeeCap := arbmath.BigMulByBips(prevTx.GasFeeCap, minRbfIncrease)
+		if feeCap.Cmp(minFeeCap) < 0 {
+			return nil, fmt.Errorf("fee cap %v is less than the minimum replacement fee cap %v", feeCap, minFeeCap)
+		}
+		tipCap = arbmath.BigMax(tipCap, arbmath.BigMulByBips(prevTx.GasTipCap, minRbfIncrease))
+	}
+	if tipCap.Cmp(feeCap) > 0 {
+		if prevTx != nil {
+			return nil, fmt.Errorf("fee cap %v is less than the minimum replacement tip cap %v", feeCap, tipCap)
+		}
+		tipCap = feeCap
+	}
+	return tipCap, nil
+}
+
+// ReplaceByFee immediately replaces the queued transaction with the given nonce, using the given fee cap
+func (p *DataPoster[Meta]) ReplaceByFee(ctx context.Context, nonce uint64, feeCap *big.Int) error {
+	if err := p.lockForAdmin(ctx); err != nil {
+		return err
+	}
+	defer p.mutex.Unlock()
+	prevTx, err := p.getQueuedTx(ctx, nonce)
+	if err != nil {
+		return err
+	}
+	if prevTx == nil {
+		return fmt.Errorf("no transaction queued with nonce %v", nonce)
+	}
+	tipCap, err := p.manualReplacementCaps(ctx, &prevTx.Data, feeCap)
+	if err != nil {
+		return err
+	}
+	newTx := *prevTx
+	newTx.Sent = false
+	newTx.Data.GasFeeCap = feeCap
+	newTx.Data.GasTipCap = tipCap
+	newTx.NextReplacement = time.Now().Add(p.replacementTimes[0])
+	newTx.FullTx, err = p.auth.Signer(p.auth.From, types.NewTx(&newTx.Data))
+	if err != nil {
+		return err
+	}
+	log.Warn("DataPoster manually replacing transaction", "nonce", nonce, "prevFeeCap", prevTx.Data.GasFeeCap, "feeCap", feeCap, "tipCap", tipCap)
+	return p.sendTx(ctx, prevTx, &newTx)
+}
+
+// metaBefore returns the metadata from before the transaction with the given nonce, as if it had posted nothing.
+// The mutex must be held by the caller.
+func (p *DataPoster[Meta]) metaBefore(ctx context.Context, nonce uint64) (Meta, error) {
+	if nonce > p.nonce {
+		prevTx, err := p.getQueuedTx(ctx, nonce-1)
+		if err != nil {
+			var emptyMeta Meta
+			return emptyMeta, err
+		}
+		if prevTx != nil {
+			return prevTx.Meta, nil
+		}
+	}
+	return p.metadataRetriever(ctx, p.lastBlock)
+}
+
+// CancelNonce replaces the transaction with the given nonce with a zero value transfer to ourselves.
+// If the nonce was queued, the entry's metadata is reset to that from before it, as its data won't be posted.
+// Later queued transactions will likely revert, so the queue should be resynced once the cancellation is confirmed.
+func (p *DataPoster[Meta]) CancelNonce(ctx context.Context, nonce uint64, feeCap *big.Int) error {
+	if err := p.lockForAdmin(ctx); err != nil {
+		return err
+	}
+	defer p.mutex.Unlock()
+	prevTx, err := p.getQueuedTx(ctx, nonce)
+	if err != nil {
+		return err
+	}
+	var prevData *types.DynamicFeeTx
+	if prevTx != nil {
+		prevData = &prevTx.Data
+	}
+	tipCap, err := p.manualReplacementCaps(ctx, prevData, feeCap)
+	if err != nil {
+		return err
+	}
+	to := p.auth.From
+	inner := types.DynamicFeeTx{
+		Nonce:     nonce,
+		GasTipCap: tipCap,
+		GasFeeCap: feeCap,
+		Gas:       params.TxGas,
+		To:        &to,
+		Value:     new(big.Int),
+	}
+	fullTx, err := p.auth.Signer(p.auth.From, types.NewTx(&inner))
+	if err != nil {
+		return err
+	}
+	log.Warn("DataPoster cancelling nonce", "nonce", nonce, "queued", prevTx != nil, "feeCap", feeCap, "tipCap", tipCap)
+	if prevTx == nil {
+		// Not ours to track, e.g. left over from another process using the same key
+		return p.client.SendTransaction(ctx, fullTx)
+	}
+	newTx := *prevTx
+	newTx.Meta, err = p.metaBefore(ctx, nonce)
+	if err != nil {
+		return err
+	}
+	newTx.Sent = false
+	newTx.Data = inner
+	newTx.FullTx = fullTx
+	newTx.NextReplacement = time.Now().Add(p.replacementTimes[0])
+	return p.sendTx(ctx, prevTx, &newTx)
+}
+
+// ResyncQueue discards every queued transaction, so the next transaction is posted at the L1 nonce,
+// with its metadata retrieved from L1.
+func (p *DataPoster[Meta]) ResyncQueue(ctx context.Context) (uint64, error) {
+	if err := p.lockForAdmin(ctx); err != nil {
+		return 0, err
+	}
+	defer p.mutex.Unlock()
+	if err := p.queue.Prune(ctx, math.MaxUint64); err != nil {
+		return 0, err
+	}
+	p.errorCount = make(map[uint64]int)
+	log.Warn("DataPoster discarded its queue to resync from L1", "nonce", p.nonce)
+	return p.nonce, nil
+}
@@ -0,0 +1,271 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package dataposter
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+
+	"github.com/offchainlabs/nitro/arbutil"
+)
+
+type recoveryTestClient struct {
+	arbutil.L1Interface
+	nonce           uint64
+	suggestedTipCap *big.Int
+	sent            []*types.Transaction
+}
+
+func (c *recoveryTestClient) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	return &types.Header{Number: big.NewInt(100)}, nil
+}
+
+func (c *recoveryTestClient) NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error) {
+	return c.nonce, nil
+}
+
+func (c *recoveryTestClient) BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error) {
+	return big.NewInt(params.Ether), nil
+}
+
+func (c *recoveryTestClient) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return c.suggestedTipCap, nil
+}
+
+func (c *recoveryTestClient) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	c.sent = append(c.sent, tx)
+	return nil
+}
+
+type recoveryTestLock struct {
+	locked bool
+}
+
+func (l *recoveryTestLock) AttemptLock(context.Context) bool {
+	return l.locked
+}
+
+// The metadata is the next batch's sequence number, which L1 says is l1Meta while nothing queued has been confirmed
+const l1Meta uint64 = 10
+
+func newRecoveryTestPoster(t *testing.T) (*DataPoster[uint64], *recoveryTestClient, *recoveryTestLock) {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth, err := bind.NewKeyedTransactorWithChainID(key, big.NewInt(1337))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &recoveryTestClient{suggestedTipCap: big.NewInt(params.GWei)}
+	lock := &recoveryTestLock{locked: true}
+	config := TestDataPosterConfig
+	p := &DataPoster[uint64]{
+		client:           client,
+		auth:             auth,
+		redisLock:        lock,
+		config:           func() *DataPosterConfig { return &config },
+		replacementTimes: []time.Duration{time.Minute},
+		metadataRetriever: func(ctx context.Context, blockNum *big.Int) (uint64, error) {
+			return l1Meta, nil
+		},
+		queue:      NewSliceStorage[queuedTransaction[uint64]](),
+		errorCount: make(map[uint64]int),
+	}
+	return p, client, lock
+}
+
+// queueTestTxs queues transactions with the given nonces as sent, with metadata following on from l1Meta
+func queueTestTxs(t *testing.T, p *DataPoster[uint64], nonces ...uint64) {
+	t.Helper()
+	for i, nonce := range nonces {
+		inner := types.DynamicFeeTx{
+			Nonce:     nonce,
+			GasTipCap: big.NewInt(params.GWei),
+			GasFeeCap: big.NewInt(10 * params.GWei),
+			Gas:       100_000,
+			To:        &common.Address{1},
+			Value:     new(big.Int),
+			Data:      []byte{byte(nonce)},
+		}
+		fullTx, err := p.auth.Signer(p.auth.From, types.NewTx(&inner))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = p.queue.Put(context.Background(), nonce, nil, &queuedTransaction[uint64]{
+			FullTx:          fullTx,
+			Data:            inner,
+			Meta:            l1Meta + uint64(i) + 1,
+			Sent:            true,
+			Created:         time.Now(),
+			NextReplacement: time.Now().Add(time.Hour),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func getTestQueuedTx(t *testing.T, p *DataPoster[uint64], nonce uint64) *queuedTransaction[uint64] {
+	t.Helper()
+	contents, err := p.queue.GetContents(context.Background(), nonce, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(contents) == 0 || contents[0].Data.Nonce != nonce {
+		t.Fatal("no transaction queued with nonce", nonce)
+	}
+	return contents[0]
+}
+
+func TestManualReplacementCaps(t *testing.T) {
+	ctx := context.Background()
+	p, client, _ := newRecoveryTestPoster(t)
+	prevTx := &types.DynamicFeeTx{
+		GasFeeCap: big.NewInt(10 * params.GWei),
+		GasTipCap: big.NewInt(params.GWei),
+	}
+
+	if _, err := p.manualReplacementCaps(ctx, prevTx, big.NewInt(10*params.GWei+params.GWei/2)); err == nil {
+		t.Fatal("accepted a fee cap below the minimum replacement increase")
+	}
+	// The tip cap must also increase enough to replace, even if the suggested tip is lower
+	tipCap, err := p.manualReplacementCaps(ctx, prevTx, big.NewInt(20*params.GWei))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tipCap.Cmp(big.NewInt(11*params.GWei/10)) != 0 {
+		t.Fatal("expected the minimum replacement tip cap, got", tipCap)
+	}
+
+	client.suggestedTipCap = big.NewInt(30 * params.GWei)
+	if _, err := p.manualReplacementCaps(ctx, prevTx, big.NewInt(20*params.GWei)); err == nil {
+		t.Fatal("accepted a replacement with a tip cap above its fee cap")
+	}
+	// Without a transaction to replace, the tip cap is limited to the fee cap instead
+	tipCap, err = p.manualReplacementCaps(ctx, nil, big.NewInt(20*params.GWei))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tipCap.Cmp(big.NewInt(20*params.GWei)) != 0 {
+		t.Fatal("expected the tip cap to be limited to the fee cap, got", tipCap)
+	}
+}
+
+func TestReplaceByFee(t *testing.T) {
+	ctx := context.Background()
+	p, client, lock := newRecoveryTestPoster(t)
+	queueTestTxs(t, p, 0, 1)
+	prevTx := getTestQueuedTx(t, p, 1)
+
+	feeCap := big.NewInt(20 * params.GWei)
+	if err := p.ReplaceByFee(ctx, 1, feeCap); err != nil {
+		t.Fatal(err)
+	}
+	replaced := getTestQueuedTx(t, p, 1)
+	if replaced.Data.GasFeeCap.Cmp(feeCap) != 0 || !replaced.Sent || replaced.Meta != prevTx.Meta {
+		t.Fatal("unexpected replacement", replaced.Data.GasFeeCap, replaced.Sent, replaced.Meta)
+	}
+	if replaced.FullTx.Hash() == prevTx.FullTx.Hash() || replaced.Data.Nonce != 1 || common.Bytes2Hex(replaced.Data.Data) != common.Bytes2Hex(prevTx.Data.Data) {
+		t.Fatal("replacement didn't re-sign the same data at the same nonce")
+	}
+	if len(client.sent) != 1 || client.sent[0].Hash() != replaced.FullTx.Hash() {
+		t.Fatal("replacement wasn't sent", client.sent)
+	}
+
+	if err := p.ReplaceByFee(ctx, 1, feeCap); err == nil {
+		t.Fatal("replaced a transaction without increasing its fee cap")
+	}
+	if err := p.ReplaceByFee(ctx, 5, big.NewInt(100*params.GWei)); err == nil {
+		t.Fatal("replaced a nonce that isn't queued")
+	}
+	client.nonce = 1
+	if err := p.ReplaceByFee(ctx, 0, big.NewInt(100*params.GWei)); err == nil {
+		t.Fatal("replaced a confirmed nonce")
+	}
+	lock.locked = false
+	if err := p.ReplaceByFee(ctx, 1, big.NewInt(100*params.GWei)); !errors.Is(err, ErrNotLocked) {
+		t.Fatal("expected replacing without the lock to fail, got", err)
+	}
+}
+
+func TestCancelNonce(t *testing.T) {
+	ctx := context.Background()
+	p, client, _ := newRecoveryTestPoster(t)
+	queueTestTxs(t, p, 0, 1)
+
+	if err := p.CancelNonce(ctx, 1, big.NewInt(20*params.GWei)); err != nil {
+		t.Fatal(err)
+	}
+	cancelled := getTestQueuedTx(t, p, 1)
+	if *cancelled.Data.To != p.auth.From || cancelled.Data.Value.Sign() != 0 || cancelled.Data.Gas != params.TxGas || len(cancelled.Data.Data) != 0 {
+		t.Fatal("nonce wasn't cancelled with an empty transfer to ourselves", cancelled.Data)
+	}
+	// The cancelled batch won't be posted, so the next batch follows on from the one before it
+	if cancelled.Meta != getTestQueuedTx(t, p, 0).Meta {
+		t.Fatal("cancelled transaction kept its metadata", cancelled.Meta)
+	}
+	nonce, meta, err := p.GetNextNonceAndMeta(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nonce != 2 || meta != l1Meta+1 {
+		t.Fatal("unexpected next nonce and metadata after cancelling", nonce, meta)
+	}
+
+	// Without a queued transaction before it, the metadata is read from L1
+	if err := p.CancelNonce(ctx, 0, big.NewInt(20*params.GWei)); err != nil {
+		t.Fatal(err)
+	}
+	if meta := getTestQueuedTx(t, p, 0).Meta; meta != l1Meta {
+		t.Fatal("cancelled first transaction has metadata", meta, "instead of L1's", l1Meta)
+	}
+
+	// Nonces that aren't queued are cancelled without being tracked
+	sentBefore := len(client.sent)
+	if err := p.CancelNonce(ctx, 5, big.NewInt(20*params.GWei)); err != nil {
+		t.Fatal(err)
+	}
+	if len(client.sent) != sentBefore+1 || client.sent[sentBefore].Nonce() != 5 {
+		t.Fatal("cancellation of an untracked nonce wasn't sent")
+	}
+	if last, err := p.queue.GetLast(ctx); err != nil || last.Data.Nonce != 1 {
+		t.Fatal("cancelling an untracked nonce changed the queue", err)
+	}
+}
+
+func TestResyncQueue(t *testing.T) {
+	ctx := context.Background()
+	p, client, _ := newRecoveryTestPoster(t)
+	queueTestTxs(t, p, 0, 1, 2)
+	client.nonce = 1
+
+	nonce, err := p.ResyncQueue(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nonce != 1 {
+		t.Fatal("resynced to nonce", nonce, "instead of the L1 nonce")
+	}
+	if last, err := p.queue.GetLast(ctx); err != nil || last != nil {
+		t.Fatal("queue not empty after resyncing", last, err)
+	}
+	nonce, meta, err := p.GetNextNonceAndMeta(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nonce != 1 || meta != l1Meta {
+		t.Fatal("unexpected next nonce and metadata after resyncing", nonce, meta)
+	}
+}
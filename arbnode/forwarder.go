@@ -5,8 +5,10 @@ package arbnode
 
 import (
 	"context"
+	"fmt"
 	"net"
 	"net/http"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -14,37 +16,53 @@ import (
 	"github.com/pkg/errors"
 	flag "github.com/spf13/pflag"
 
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/offchainlabs/nitro/arbutil"
+	"github.com/offchainlabs/nitro/util/stopwaiter"
 )
 
 type ForwarderConfig struct {
-	ConnectionTimeout     time.Duration `koanf:"connection-timeout"`
-	IdleConnectionTimeout time.Duration `koanf:"idle-connection-timeout"`
-	MaxIdleConnections    int           `koanf:"max-idle-connections"`
+	ConnectionTimeout     time.Duration     `koanf:"connection-timeout"`
+	IdleConnectionTimeout time.Duration     `koanf:"idle-connection-timeout"`
+	MaxIdleConnections    int               `koanf:"max-idle-connections"`
+	HealthCheckInterval   time.Duration     `koanf:"health-check-interval"`
+	RaceTargets           bool              `koanf:"race-targets"`
+	RateLimit             TxRateLimitConfig `koanf:"rate-limit"`
 }
 
 var DefaultTestForwarderConfig = ForwarderConfig{
 	ConnectionTimeout:     2 * time.Second,
 	IdleConnectionTimeout: 2 * time.Second,
 	MaxIdleConnections:    1,
+	HealthCheckInterval:   time.Second,
 }
 
 var DefaultNodeForwarderConfig = ForwarderConfig{
 	ConnectionTimeout:     30 * time.Second,
 	IdleConnectionTimeout: 15 * time.Second,
 	MaxIdleConnections:    1,
+	HealthCheckInterval:   5 * time.Second,
+	RaceTargets:           false,
+	RateLimit:             DefaultTxRateLimitConfig,
 }
 
 var DefaultSequencerForwarderConfig = ForwarderConfig{
 	ConnectionTimeout:     30 * time.Second,
 	IdleConnectionTimeout: 60 * time.Second,
 	MaxIdleConnections:    100,
+	HealthCheckInterval:   5 * time.Second,
+	RaceTargets:           false,
 }
 
 func AddOptionsForNodeForwarderConfig(prefix string, f *flag.FlagSet) {
 	AddOptionsForForwarderConfigImpl(prefix, &DefaultNodeForwarderConfig, f)
+	// The sequencer applies its own rate limits, so only the node's forwarder has them
+	TxRateLimitConfigAddOptions(prefix+".rate-limit", f)
 }
 
 func AddOptionsForSequencerForwarderConfig(prefix string, f *flag.FlagSet) {
@@ -55,22 +73,101 @@ func AddOptionsForForwarderConfigImpl(prefix string, defaultConfig *ForwarderCon
 	f.Duration(prefix+".connection-timeout", defaultConfig.ConnectionTimeout, "total time to wait before cancelling connection")
 	f.Duration(prefix+".idle-connection-timeout", defaultConfig.IdleConnectionTimeout, "time until idle connections are closed")
 	f.Int(prefix+".max-idle-connections", defaultConfig.MaxIdleConnections, "maximum number of idle connections to keep open")
+	f.Duration(prefix+".health-check-interval", defaultConfig.HealthCheckInterval, "how often to check the health of each forwarding target, which health checks of this node are answered from (0 = disable, checking the targets on each health check)")
+	f.Bool(prefix+".race-targets", defaultConfig.RaceTargets, "send transactions to the first two healthy forwarding targets at once, using the first to accept")
 }
 
-type TxForwarder struct {
-	enabled   int32
-	target    string
-	timeout   time.Duration
-	transport *http.Transport
+// forwardingTarget is one of the endpoints a TxForwarder may forward to
+type forwardingTarget struct {
+	url       string
 	rpcClient *rpc.Client
 	ethClient *ethclient.Client
+	healthy   int32 // atomic
+
+	healthMutex sync.Mutex
+	healthErr   error // from the last health check
+
+	successCounter metrics.Counter
+	failureCounter metrics.Counter
+	latencyTimer   metrics.Timer
+	healthyGauge   metrics.Gauge
+}
+
+func newForwardingTarget(index int, url string) *forwardingTarget {
+	prefix := fmt.Sprintf("arb/forwarder/target/%v/", index)
+	target := &forwardingTarget{
+		url:            url,
+		healthy:        1,
+		successCounter: metrics.GetOrRegisterCounter(prefix+"success", nil),
+		failureCounter: metrics.GetOrRegisterCounter(prefix+"failure", nil),
+		latencyTimer:   metrics.GetOrRegisterTimer(prefix+"latency", nil),
+		healthyGauge:   metrics.GetOrRegisterGauge(prefix+"healthy", nil),
+	}
+	target.healthyGauge.Update(1)
+	return target
+}
+
+func (t *forwardingTarget) Healthy() bool {
+	return atomic.LoadInt32(&t.healthy) == 1
+}
+
+func (t *forwardingTarget) setHealthy(healthy bool) {
+	var value int32
+	if healthy {
+		value = 1
+	}
+	if atomic.SwapInt32(&t.healthy, value) != value {
+		if healthy {
+			log.Info("forwarding target is healthy again", "target", t.url)
+		} else {
+			log.Warn("forwarding target is unhealthy", "target", t.url)
+		}
+	}
+	t.healthyGauge.Update(int64(value))
+}
+
+func (t *forwardingTarget) setHealth(err error) {
+	t.healthMutex.Lock()
+	t.healthErr = err
+	t.healthMutex.Unlock()
+	t.setHealthy(err == nil)
+}
+
+func (t *forwardingTarget) lastHealthErr() error {
+	t.healthMutex.Lock()
+	defer t.healthMutex.Unlock()
+	return t.healthErr
+}
+
+// shouldFailover returns whether a forwarding error means the transaction should be tried with the next target.
+// Errors returned by the target itself are final, unless it doesn't currently have a sequencer.
+func shouldFailover(err error) bool {
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) {
+		return strings.Contains(err.Error(), ErrNoSequencer.Error())
+	}
+	return true
+}
+
+// TxForwarder forwards transactions to an ordered list of targets.
+// Healthy targets are tried first, in order, moving on to the next one if a target can't be reached.
+type TxForwarder struct {
+	stopwaiter.StopWaiter
+
+	enabled             int32
+	targets             []*forwardingTarget
+	timeout             time.Duration
+	healthCheckInterval time.Duration
+	raceTargets         bool
+	transport           *http.Transport
+	healthLoop          int32 // atomic, whether the health loop is checking the targets
 
 	healthMutex   sync.Mutex
 	healthErr     error
 	healthChecked time.Time
 }
 
-func NewForwarder(target string, config *ForwarderConfig) *TxForwarder {
+func NewForwarder(targets []string, config *ForwarderConfig) *TxForwarder {
 	transport := &http.Transport{
 		DialContext: (&net.Dialer{
 			Timeout:   5 * time.Second,
@@ -82,11 +179,25 @@ func NewForwarder(target string, config *ForwarderConfig) *TxForwarder {
 		TLSHandshakeTimeout:   5 * time.Second,
 		ExpectContinueTimeout: 1 * time.Second,
 	}
+	forwardingTargets := make([]*forwardingTarget, 0, len(targets))
+	for i, target := range targets {
+		forwardingTargets = append(forwardingTargets, newForwardingTarget(i, target))
+	}
 	return &TxForwarder{
-		target:    target,
-		timeout:   config.ConnectionTimeout,
-		transport: transport,
+		targets:             forwardingTargets,
+		timeout:             config.ConnectionTimeout,
+		healthCheckInterval: config.HealthCheckInterval,
+		raceTargets:         config.RaceTargets,
+		transport:           transport,
+	}
+}
+
+// PrimaryTarget returns the first configured target, or an empty string if there's none
+func (f *TxForwarder) PrimaryTarget() string {
+	if len(f.targets) == 0 {
+		return ""
 	}
+	return f.targets[0].url
 }
 
 func (f *TxForwarder) ctxWithTimeout(inctx context.Context) (context.Context, context.CancelFunc) {
@@ -96,52 +207,215 @@ func (f *TxForwarder) ctxWithTimeout(inctx context.Context) (context.Context, co
 	return context.WithTimeout(inctx, f.timeout)
 }
 
-func (f *TxForwarder) PublishTransaction(inctx context.Context, tx *types.Transaction) error {
-	if atomic.LoadInt32(&f.enabled) == 0 {
-		return ErrNoSequencer
+// orderedTargets returns the healthy targets in order, followed by the unhealthy ones as a last resort
+func (f *TxForwarder) orderedTargets() []*forwardingTarget {
+	ordered := make([]*forwardingTarget, 0, len(f.targets))
+	for _, target := range f.targets {
+		if target.Healthy() {
+			ordered = append(ordered, target)
+		}
+	}
+	for _, target := range f.targets {
+		if !target.Healthy() {
+			ordered = append(ordered, target)
+		}
 	}
+	return ordered
+}
+
+func (f *TxForwarder) sendTo(inctx context.Context, target *forwardingTarget, send func(context.Context, *forwardingTarget) error) error {
 	ctx, cancelFunc := f.ctxWithTimeout(inctx)
 	defer cancelFunc()
-	return f.ethClient.SendTransaction(ctx, tx)
+	start := time.Now()
+	err := send(ctx, target)
+	if err == nil {
+		target.successCounter.Inc(1)
+		target.latencyTimer.Update(time.Since(start))
+	} else if inctx.Err() == nil {
+		target.failureCounter.Inc(1)
+		if shouldFailover(err) {
+			target.setHealthy(false)
+		}
+	}
+	return err
+}
+
+func (f *TxForwarder) race(ctx context.Context, targets []*forwardingTarget, send func(context.Context, *forwardingTarget) error) error {
+	ctx, cancelFunc := context.WithCancel(ctx)
+	defer cancelFunc()
+	results := make(chan error, len(targets))
+	for _, target := range targets {
+		target := target
+		go func() {
+			results <- f.sendTo(ctx, target, send)
+		}()
+	}
+	// Prefer any success, then an error from a target that was reached, over failover errors
+	var finalErr error
+	for range targets {
+		err := <-results
+		if err == nil {
+			return nil
+		}
+		if finalErr == nil || (shouldFailover(finalErr) && !shouldFailover(err)) {
+			finalErr = err
+		}
+	}
+	return finalErr
+}
+
+func (f *TxForwarder) forward(ctx context.Context, send func(context.Context, *forwardingTarget) error) error {
+	if atomic.LoadInt32(&f.enabled) == 0 {
+		return ErrNoSequencer
+	}
+	targets := f.orderedTargets()
+	if len(targets) == 0 {
+		return ErrNoSequencer
+	}
+	var err error
+	if f.raceTargets && len(targets) > 1 {
+		err = f.race(ctx, targets[:2], send)
+		if err == nil || !shouldFailover(err) {
+			return err
+		}
+		targets = targets[2:]
+	}
+	for _, target := range targets {
+		err = f.sendTo(ctx, target, send)
+		if err == nil || !shouldFailover(err) {
+			return err
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		log.Warn("failed to forward to target, trying the next one", "target", target.url, "err", err)
+	}
+	return err
+}
+
+func (f *TxForwarder) PublishTransaction(ctx context.Context, tx *types.Transaction, options *arbutil.ConditionalOptions) error {
+	var txBytes []byte
+	if options != nil {
+		var err error
+		txBytes, err = tx.MarshalBinary()
+		if err != nil {
+			return err
+		}
+	}
+	return f.forward(ctx, func(ctx context.Context, target *forwardingTarget) error {
+		if options == nil {
+			return target.ethClient.SendTransaction(ctx, tx)
+		}
+		return target.rpcClient.CallContext(ctx, nil, "eth_sendRawTransactionConditional", hexutil.Bytes(txBytes), options)
+	})
+}
+
+func (f *TxForwarder) PublishBundle(ctx context.Context, txs types.Transactions, targetBlock *uint64) error {
+	args, err := NewBundleArgs(txs, targetBlock)
+	if err != nil {
+		return err
+	}
+	return f.forward(ctx, func(ctx context.Context, target *forwardingTarget) error {
+		return target.rpcClient.CallContext(ctx, nil, "arb_sendBundle", args)
+	})
 }
 
 const cacheUpstreamHealth = 2 * time.Second
 const maxHealthTimeout = 10 * time.Second
 
+func (f *TxForwarder) healthTimeout() time.Duration {
+	timeout := f.timeout
+	if timeout == time.Duration(0) || timeout >= maxHealthTimeout {
+		timeout = maxHealthTimeout
+	}
+	return timeout
+}
+
+func (f *TxForwarder) checkTargetHealth(ctx context.Context, target *forwardingTarget) error {
+	timeoutCtx, cancelFunc := context.WithTimeout(ctx, f.healthTimeout())
+	defer cancelFunc()
+	err := target.rpcClient.CallContext(timeoutCtx, nil, "arb_checkPublisherHealth")
+	if ctx.Err() != nil {
+		// The caller gave up, which says nothing about the target
+		return ctx.Err()
+	}
+	target.setHealth(err)
+	return err
+}
+
+// CheckHealth succeeds if any of the targets is healthy.
+// While the health loop is running, this answers from its last checks of the targets.
+// Otherwise the targets are checked with the caller's context, and the result is cached briefly.
 func (f *TxForwarder) CheckHealth(inctx context.Context) error {
 	if atomic.LoadInt32(&f.enabled) == 0 {
 		return ErrNoSequencer
 	}
+	if atomic.LoadInt32(&f.healthLoop) == 1 {
+		return f.lastHealth()
+	}
 	f.healthMutex.Lock()
 	defer f.healthMutex.Unlock()
 	if time.Since(f.healthChecked) > cacheUpstreamHealth {
-		timeout := f.timeout
-		if timeout == time.Duration(0) || timeout >= maxHealthTimeout {
-			timeout = maxHealthTimeout
+		err := ErrNoSequencer
+		for _, target := range f.orderedTargets() {
+			err = f.checkTargetHealth(inctx, target)
+			if err == nil || inctx.Err() != nil {
+				break
+			}
 		}
-		ctx, cancelFunc := context.WithTimeout(context.Background(), timeout)
-		defer cancelFunc()
-		f.healthErr = f.rpcClient.CallContext(ctx, nil, "arb_checkPublisherHealth")
+		if inctx.Err() != nil {
+			// Don't cache the caller giving up for other callers
+			return err
+		}
+		f.healthErr = err
 		f.healthChecked = time.Now()
 	}
 	return f.healthErr
 }
 
+// lastHealth succeeds if any target is healthy, and otherwise returns the first target's last health check error
+func (f *TxForwarder) lastHealth() error {
+	var err error
+	for _, target := range f.targets {
+		if target.Healthy() {
+			return nil
+		}
+		if err == nil {
+			err = target.lastHealthErr()
+		}
+	}
+	if err == nil {
+		return ErrNoSequencer
+	}
+	return err
+}
+
+func (f *TxForwarder) checkTargetsHealth(ctx context.Context) time.Duration {
+	if atomic.LoadInt32(&f.enabled) == 1 {
+		for _, target := range f.targets {
+			if err := f.checkTargetHealth(ctx, target); err != nil && ctx.Err() == nil {
+				log.Debug("forwarding target health check failed", "target", target.url, "err", err)
+			}
+		}
+	}
+	return f.healthCheckInterval
+}
+
 func (f *TxForwarder) Initialize(inctx context.Context) error {
-	if f.target == "" {
-		f.rpcClient = nil
-		f.ethClient = nil
+	if len(f.targets) == 0 {
 		f.enabled = 0
 		return nil
 	}
 	ctx, cancelFunc := f.ctxWithTimeout(inctx)
 	defer cancelFunc()
-	rpcClient, err := rpc.DialTransport(ctx, f.target, f.transport)
-	if err != nil {
-		return err
+	for _, target := range f.targets {
+		rpcClient, err := rpc.DialTransport(ctx, target.url, f.transport)
+		if err != nil {
+			return fmt.Errorf("error connecting to forwarding target %v: %w", target.url, err)
+		}
+		target.rpcClient = rpcClient
+		target.ethClient = ethclient.NewClient(rpcClient)
 	}
-	f.rpcClient = rpcClient
-	f.ethClient = ethclient.NewClient(rpcClient)
 	f.enabled = 1
 	return nil
 }
@@ -152,15 +426,14 @@ func (f *TxForwarder) Disable() {
 }
 
 func (f *TxForwarder) Start(ctx context.Context) error {
+	f.StopWaiter.Start(ctx, f)
+	if f.healthCheckInterval > 0 && len(f.targets) > 0 {
+		atomic.StoreInt32(&f.healthLoop, 1)
+		f.CallIteratively(f.checkTargetsHealth)
+	}
 	return nil
 }
 
-func (f *TxForwarder) StopAndWait() {}
-
-func (f *TxForwarder) Started() bool {
-	return true
-}
-
 type TxDropper struct{}
 
 func NewTxDropper() *TxDropper {
@@ -169,7 +442,7 @@ func NewTxDropper() *TxDropper {
 
 var txDropperErr = errors.New("publishing transactions not supported by this endpoint")
 
-func (f *TxDropper) PublishTransaction(ctx context.Context, tx *types.Transaction) error {
+func (f *TxDropper) PublishTransaction(ctx context.Context, tx *types.Transaction, options *arbutil.ConditionalOptions) error {
 	return txDropperErr
 }
 
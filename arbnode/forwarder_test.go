@@ -0,0 +1,120 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+type testForwardingTargetAPI struct {
+	received int32
+	err      error
+}
+
+func (a *testForwardingTargetAPI) SendRawTransaction(ctx context.Context, input hexutil.Bytes) (common.Hash, error) {
+	atomic.AddInt32(&a.received, 1)
+	if a.err != nil {
+		return common.Hash{}, a.err
+	}
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(input); err != nil {
+		return common.Hash{}, err
+	}
+	return tx.Hash(), nil
+}
+
+func newTestForwardingTarget(t *testing.T, api *testForwardingTargetAPI) string {
+	t.Helper()
+	server := rpc.NewServer()
+	Require(t, server.RegisterName("eth", api))
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+	t.Cleanup(server.Stop)
+	return httpServer.URL
+}
+
+func TestTxForwarderFailover(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	backup := &testForwardingTargetAPI{}
+	rejecting := &testForwardingTargetAPI{err: ErrSenderRateLimited}
+
+	forwarder := NewForwarder([]string{down.URL, newTestForwardingTarget(t, backup), newTestForwardingTarget(t, rejecting)}, &DefaultTestForwarderConfig)
+	Require(t, forwarder.Initialize(ctx))
+
+	tx := types.NewTx(&types.LegacyTx{Nonce: 1})
+	Require(t, forwarder.PublishTransaction(ctx, tx, nil))
+	if atomic.LoadInt32(&backup.received) != 1 {
+		Fail(t, "transaction wasn't failed over to the backup target")
+	}
+	if forwarder.targets[0].Healthy() {
+		Fail(t, "unreachable target still considered healthy")
+	}
+	if forwarder.orderedTargets()[0] != forwarder.targets[1] {
+		Fail(t, "healthy backup target isn't preferred over the unhealthy primary")
+	}
+
+	// Errors returned by a reachable target are final
+	forwarder.targets[1].setHealthy(false)
+	err := forwarder.PublishTransaction(ctx, tx, nil)
+	if err == nil || err.Error() != ErrSenderRateLimited.Error() {
+		Fail(t, "expected the rejection from the last target, but got", err)
+	}
+	if atomic.LoadInt32(&rejecting.received) != 1 {
+		Fail(t, "transaction wasn't sent to the only healthy target")
+	}
+	if atomic.LoadInt32(&backup.received) != 1 {
+		Fail(t, "transaction was failed over despite being rejected")
+	}
+}
+
+func TestTxForwarderCheckHealth(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	forwarder := NewForwarder([]string{down.URL}, &DefaultTestForwarderConfig)
+	Require(t, forwarder.Initialize(ctx))
+
+	// A caller giving up says nothing about the targets
+	cancelled, cancelCaller := context.WithCancel(ctx)
+	cancelCaller()
+	if err := forwarder.CheckHealth(cancelled); !errors.Is(err, context.Canceled) {
+		Fail(t, "expected the caller's cancellation, but got", err)
+	}
+	if !forwarder.targets[0].Healthy() {
+		Fail(t, "target marked unhealthy by a cancelled health check")
+	}
+	if forwarder.CheckHealth(ctx) == nil || forwarder.targets[0].Healthy() {
+		Fail(t, "unreachable target considered healthy")
+	}
+
+	// With the health loop running, its last checks are answered from without checking the targets again
+	atomic.StoreInt32(&forwarder.healthLoop, 1)
+	forwarder.targets[0].setHealth(nil)
+	Require(t, forwarder.CheckHealth(cancelled))
+	forwarder.checkTargetsHealth(ctx)
+	err := forwarder.CheckHealth(cancelled)
+	if err == nil || errors.Is(err, context.Canceled) {
+		Fail(t, "expected the unreachable target's last health check error, but got", err)
+	}
+}
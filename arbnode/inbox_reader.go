@@ -14,6 +14,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/log"
 	flag "github.com/spf13/pflag"
 
@@ -167,6 +168,20 @@ func (r *InboxReader) DelayedBridge() *DelayedBridge {
 	return r.delayedBridge
 }
 
+func (r *InboxReader) Client() arbutil.L1Interface {
+	return r.client
+}
+
+// LookupBatchesInRange returns the sequencer batches posted to L1 in the given block range
+func (r *InboxReader) LookupBatchesInRange(ctx context.Context, from, to *big.Int) ([]*SequencerInboxBatch, error) {
+	return r.sequencerInbox.LookupBatchesInRange(ctx, from, to)
+}
+
+// GetDelayedAccumulator returns the accumulator after the given delayed message according to L1
+func (r *InboxReader) GetDelayedAccumulator(ctx context.Context, seqNum uint64) (common.Hash, error) {
+	return r.delayedBridge.GetAccumulator(ctx, seqNum, nil)
+}
+
 func (ir *InboxReader) run(ctx context.Context, hadError bool) error {
 	from, err := ir.getNextBlockToRead()
 	if err != nil {
@@ -519,6 +534,15 @@ func (r *InboxReader) getNextBlockToRead() (*big.Int, error) {
 }
 
 func (r *InboxReader) GetSequencerMessageBytes(ctx context.Context, seqNum uint64) ([]byte, error) {
+	batch, err := r.GetSequencerBatch(ctx, seqNum)
+	if err != nil {
+		return nil, err
+	}
+	return batch.Serialize(ctx, r.client)
+}
+
+// GetSequencerBatch looks up the L1 event that delivered a sequencer batch
+func (r *InboxReader) GetSequencerBatch(ctx context.Context, seqNum uint64) (*SequencerInboxBatch, error) {
 	metadata, err := r.tracker.GetBatchMetadata(seqNum)
 	if err != nil {
 		return nil, err
@@ -530,7 +554,7 @@ func (r *InboxReader) GetSequencerMessageBytes(ctx context.Context, seqNum uint6
 	}
 	for _, batch := range seqBatches {
 		if batch.SequenceNumber == seqNum {
-			return batch.Serialize(ctx, r.client)
+			return batch, nil
 		}
 	}
 	return nil, errors.New("sequencer batch not found")
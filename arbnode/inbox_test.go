@@ -29,6 +29,15 @@ import (
 )
 
 func NewTransactionStreamerForTest(t *testing.T, ownerAddress common.Address) (*TransactionStreamer, ethdb.Database, *core.BlockChain) {
+	inbox, arbDb, bc, err := newTransactionStreamerForTest(ownerAddress)
+	if err != nil {
+		Fail(t, err)
+	}
+	return inbox, arbDb, bc
+}
+
+// newTransactionStreamerForTest is NewTransactionStreamerForTest for benchmarks, which don't have a *testing.T
+func newTransactionStreamerForTest(ownerAddress common.Address) (*TransactionStreamer, ethdb.Database, *core.BlockChain, error) {
 	chainConfig := params.ArbitrumDevTestChainConfig()
 
 	initData := statetransfer.ArbosInitializationInfo{
@@ -47,22 +56,22 @@ func NewTransactionStreamerForTest(t *testing.T, ownerAddress common.Address) (*
 	bc, err := WriteOrTestBlockChain(chainDb, nil, initReader, chainConfig, ConfigDefaultL2Test(), 0)
 
 	if err != nil {
-		Fail(t, err)
+		return nil, nil, nil, err
 	}
 
 	transactionStreamerConfigFetcher := func() *TransactionStreamerConfig { return &DefaultTransactionStreamerConfig }
 	inbox, err := NewTransactionStreamer(arbDb, bc, nil, make(chan error, 1), transactionStreamerConfigFetcher)
 	if err != nil {
-		Fail(t, err)
+		return nil, nil, nil, err
 	}
 
 	// Add the init message
 	err = inbox.AddFakeInitMessage()
 	if err != nil {
-		Fail(t, err)
+		return nil, nil, nil, err
 	}
 
-	return inbox, arbDb, bc
+	return inbox, arbDb, bc, nil
 }
 
 type blockTestState struct {
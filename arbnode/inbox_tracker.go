@@ -27,9 +27,10 @@ type InboxTracker struct {
 	mutex      sync.Mutex
 	validator  *validator.BlockValidator
 	das        arbstate.DataAvailabilityReader
+	externalDA arbstate.ExternalDAReaders
 }
 
-func NewInboxTracker(db ethdb.Database, txStreamer *TransactionStreamer, das arbstate.DataAvailabilityReader) (*InboxTracker, error) {
+func NewInboxTracker(db ethdb.Database, txStreamer *TransactionStreamer, das arbstate.DataAvailabilityReader, externalDA arbstate.ExternalDAReaders) (*InboxTracker, error) {
 	if txStreamer.bc.Config().ArbitrumChainParams.DataAvailabilityCommittee && das == nil {
 		return nil, errors.New("data availability service required but unconfigured")
 	}
@@ -37,6 +38,7 @@ func NewInboxTracker(db ethdb.Database, txStreamer *TransactionStreamer, das arb
 		db:         db,
 		txStreamer: txStreamer,
 		das:        das,
+		externalDA: externalDA,
 	}
 	return tracker, nil
 }
@@ -97,6 +99,13 @@ func (t *InboxTracker) GetDelayedAcc(seqNum uint64) (common.Hash, error) {
 			return common.Hash{}, err
 		}
 		if !hasKey {
+			pruned, err := readInboxPruneState(t.db)
+			if err != nil {
+				return common.Hash{}, err
+			}
+			if wasPruned(seqNum, pruned.DelayedCount) {
+				return common.Hash{}, fmt.Errorf("delayed message %v was %w", seqNum, ErrPruned)
+			}
 			return common.Hash{}, AccumulatorNotFoundErr
 		}
 	}
@@ -139,6 +148,13 @@ func (t *InboxTracker) GetBatchMetadata(seqNum uint64) (BatchMetadata, error) {
 		return BatchMetadata{}, err
 	}
 	if !hasKey {
+		pruned, err := readInboxPruneState(t.db)
+		if err != nil {
+			return BatchMetadata{}, err
+		}
+		if wasPruned(seqNum, pruned.BatchCount) {
+			return BatchMetadata{}, fmt.Errorf("batch %v metadata was %w", seqNum, ErrPruned)
+		}
 		return BatchMetadata{}, AccumulatorNotFoundErr
 	}
 	data, err := t.db.Get(key)
@@ -174,6 +190,18 @@ func (t *InboxTracker) GetBatchCount() (uint64, error) {
 	return count, nil
 }
 
+// GetPruneState returns how far the inbox has been pruned
+func (t *InboxTracker) GetPruneState() (InboxPruneState, error) {
+	return readInboxPruneState(t.db)
+}
+
+// GetPrunedBatchCount returns the number of batches that have been pruned.
+// The metadata of the last of them is kept.
+func (t *InboxTracker) GetPrunedBatchCount() (uint64, error) {
+	pruned, err := readInboxPruneState(t.db)
+	return pruned.BatchCount, err
+}
+
 func (t *InboxTracker) legacyGetDelayedMessageAndAccumulator(seqNum uint64) (*arbos.L1IncomingMessage, common.Hash, error) {
 	key := dbKey(legacyDelayedMessagePrefix, seqNum)
 	data, err := t.db.Get(key)
@@ -196,6 +224,13 @@ func (t *InboxTracker) GetDelayedMessageAndAccumulator(seqNum uint64) (*arbos.L1
 		return nil, common.Hash{}, err
 	}
 	if !exists {
+		pruned, err := readInboxPruneState(t.db)
+		if err != nil {
+			return nil, common.Hash{}, err
+		}
+		if wasPruned(seqNum, pruned.DelayedCount) {
+			return nil, common.Hash{}, fmt.Errorf("delayed message %v was %w", seqNum, ErrPruned)
+		}
 		return t.legacyGetDelayedMessageAndAccumulator(seqNum)
 	}
 	data, err := t.db.Get(key)
@@ -377,7 +412,7 @@ func (t *InboxTracker) setDelayedCountReorgAndWriteBatch(batch ethdb.Batch, newD
 			}
 		}
 		// Writes batch
-		return t.txStreamer.ReorgToAndEndBatch(batch, prevMesssageCount)
+		return t.txStreamer.ReorgToAndEndBatch(batch, prevMesssageCount, ReorgCauseL1Reorg)
 	} else {
 		return batch.Write()
 	}
@@ -490,7 +525,7 @@ func (t *InboxTracker) AddSequencerBatches(ctx context.Context, client arbutil.L
 		ctx:    ctx,
 		client: client,
 	}
-	multiplexer := arbstate.NewInboxMultiplexer(backend, prevbatchmeta.DelayedMessageCount, t.das, arbstate.KeysetValidate)
+	multiplexer := arbstate.NewInboxMultiplexer(backend, prevbatchmeta.DelayedMessageCount, t.das, t.externalDA, arbstate.KeysetValidate)
 	batchMessageCounts := make(map[uint64]arbutil.MessageIndex)
 	currentpos := prevbatchmeta.MessageCount + 1
 	for {
@@ -608,6 +643,86 @@ func (t *InboxTracker) AddSequencerBatches(ctx context.Context, client arbutil.L
 	return nil
 }
 
+// AddBatchesWithMessages adds batches whose messages are already known, such as from a message archive,
+// without reading the batches from L1. The inbox reader checks their accumulators when it reaches them on L1.
+func (t *InboxTracker) AddBatchesWithMessages(firstSeqNum uint64, metas []BatchMetadata, messages []arbstate.MessageWithMetadata) error {
+	if len(metas) == 0 {
+		return nil
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	batchCount, err := t.GetBatchCount()
+	if err != nil {
+		return err
+	}
+	if batchCount != firstSeqNum {
+		return fmt.Errorf("adding batches starting at %v but have %v batches", firstSeqNum, batchCount)
+	}
+	var prevbatchmeta BatchMetadata
+	if firstSeqNum > 0 {
+		prevbatchmeta, err = t.GetBatchMetadata(firstSeqNum - 1)
+		if err != nil {
+			return err
+		}
+	}
+	delayedCount, err := t.GetDelayedCount()
+	if err != nil {
+		return err
+	}
+
+	dbBatch := t.db.NewBatch()
+	lastBatchMeta := prevbatchmeta
+	for i, meta := range metas {
+		seqNum := firstSeqNum + uint64(i)
+		if meta.MessageCount < lastBatchMeta.MessageCount {
+			return errors.New("batch message count went backwards")
+		}
+		if meta.DelayedMessageCount < lastBatchMeta.DelayedMessageCount {
+			return errors.New("batch delayed message count went backwards")
+		}
+		if meta.DelayedMessageCount > delayedCount {
+			return delayedMessagesMismatch
+		}
+		metaBytes, err := rlp.EncodeToBytes(meta)
+		if err != nil {
+			return err
+		}
+		err = dbBatch.Put(dbKey(sequencerBatchMetaPrefix, seqNum), metaBytes)
+		if err != nil {
+			return err
+		}
+		if meta.DelayedMessageCount > lastBatchMeta.DelayedMessageCount {
+			seqNumData, err := rlp.EncodeToBytes(seqNum)
+			if err != nil {
+				return err
+			}
+			err = dbBatch.Put(dbKey(delayedSequencedPrefix, meta.DelayedMessageCount), seqNumData)
+			if err != nil {
+				return err
+			}
+		}
+		lastBatchMeta = meta
+	}
+	if lastBatchMeta.MessageCount-prevbatchmeta.MessageCount != arbutil.MessageIndex(len(messages)) {
+		return fmt.Errorf("batches have %v messages but got %v", lastBatchMeta.MessageCount-prevbatchmeta.MessageCount, len(messages))
+	}
+
+	pos := firstSeqNum + uint64(len(metas))
+	countData, err := rlp.EncodeToBytes(pos)
+	if err != nil {
+		return err
+	}
+	err = dbBatch.Put(sequencerBatchCountKey, countData)
+	if err != nil {
+		return err
+	}
+	log.Info("InboxTracker", "sequencerBatchCount", pos, "messageCount", lastBatchMeta.MessageCount, "l1Block", lastBatchMeta.L1Block)
+
+	// This also writes the batch
+	return t.txStreamer.AddMessagesAndEndBatch(prevbatchmeta.MessageCount, true, messages, dbBatch)
+}
+
 func (t *InboxTracker) ReorgDelayedTo(count uint64, canReorgBatches bool) error {
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
@@ -663,5 +778,5 @@ func (t *InboxTracker) ReorgBatchesTo(count uint64) error {
 		return err
 	}
 	log.Info("InboxTracker", "SequencerBatchCount", count)
-	return t.txStreamer.ReorgToAndEndBatch(dbBatch, prevBatchMeta.MessageCount)
+	return t.txStreamer.ReorgToAndEndBatch(dbBatch, prevBatchMeta.MessageCount, ReorgCauseL1Reorg)
 }
@@ -0,0 +1,603 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"hash"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/pkg/errors"
+	flag "github.com/spf13/pflag"
+
+	"github.com/offchainlabs/nitro/arbos"
+	"github.com/offchainlabs/nitro/arbstate"
+	"github.com/offchainlabs/nitro/arbutil"
+	"github.com/offchainlabs/nitro/util/arbmath"
+)
+
+// A message archive is the magic bytes, followed by a gzipped stream of RLP items:
+// the header, the delayed messages, the batches with their messages, and the keccak256 of the preceding items.
+var messageArchiveMagic = []byte("NITROMSGARCHIVE\n")
+
+const messageArchiveVersion uint64 = 1
+
+// how many delayed messages and batches to import per database batch
+const messageArchiveImportDelayedChunk = 1024
+const messageArchiveImportBatchChunk = 64
+
+type MessageArchiveConfig struct {
+	ImportFile string `koanf:"import-file"`
+	ExportDir  string `koanf:"export-dir"`
+}
+
+var DefaultMessageArchiveConfig = MessageArchiveConfig{
+	ImportFile: "",
+	ExportDir:  "",
+}
+
+func MessageArchiveConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.String(prefix+".import-file", DefaultMessageArchiveConfig.ImportFile, "message archive to import on startup, before reading the rest of the inbox from L1 (the archive must start where the database ends, its batches are derived again from L1 to verify it, and it's skipped if the database already has it)")
+	f.String(prefix+".export-dir", DefaultMessageArchiveConfig.ExportDir, "directory arbarchive_exportBatches writes message archives to (empty = exports disabled)")
+}
+
+// MessageArchiveExportPath resolves a path relative to the export directory, rejecting any path outside it
+func MessageArchiveExportPath(exportDir string, name string) (string, error) {
+	if exportDir == "" {
+		return "", errors.New("message archive exports are disabled, as no export directory is configured")
+	}
+	if filepath.IsAbs(name) {
+		return "", fmt.Errorf("message archive path %v must be relative to the export directory", name)
+	}
+	path := filepath.Join(exportDir, name)
+	rel, err := filepath.Rel(exportDir, path)
+	if err != nil {
+		return "", err
+	}
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("message archive path %v isn't a file in the export directory", name)
+	}
+	return path, nil
+}
+
+type MessageArchiveHeader struct {
+	Version      uint64               `json:"version"`
+	ChainId      *big.Int             `json:"chainId"`
+	FirstBatch   uint64               `json:"firstBatch"`
+	BatchCount   uint64               `json:"batchCount"`
+	FirstMessage arbutil.MessageIndex `json:"firstMessage"`
+	MessageCount uint64               `json:"messageCount"`
+	FirstDelayed uint64               `json:"firstDelayed"`
+	DelayedCount uint64               `json:"delayedCount"`
+}
+
+type messageArchiveBatch struct {
+	Metadata BatchMetadata
+	Messages []arbstate.MessageWithMetadata
+}
+
+type messageArchiveWriter struct {
+	out    *gzip.Writer
+	hasher hash.Hash
+}
+
+func (w *messageArchiveWriter) writeItem(val interface{}) error {
+	data, err := rlp.EncodeToBytes(val)
+	if err != nil {
+		return err
+	}
+	w.hasher.Write(data)
+	_, err = w.out.Write(data)
+	return err
+}
+
+func (w *messageArchiveWriter) close() error {
+	checksum, err := rlp.EncodeToBytes(common.BytesToHash(w.hasher.Sum(nil)))
+	if err != nil {
+		return err
+	}
+	if _, err := w.out.Write(checksum); err != nil {
+		return err
+	}
+	return w.out.Close()
+}
+
+// ExportMessageArchive writes the batches fromBatch to toBatch inclusive, with their messages and the delayed messages they read
+func ExportMessageArchive(w io.Writer, streamer *TransactionStreamer, tracker *InboxTracker, fromBatch, toBatch uint64) (*MessageArchiveHeader, error) {
+	if toBatch < fromBatch {
+		return nil, fmt.Errorf("batch range end %v is before its start %v", toBatch, fromBatch)
+	}
+	var prevMeta BatchMetadata
+	var err error
+	if fromBatch > 0 {
+		prevMeta, err = tracker.GetBatchMetadata(fromBatch - 1)
+		if err != nil {
+			return nil, err
+		}
+	}
+	lastMeta, err := tracker.GetBatchMetadata(toBatch)
+	if err != nil {
+		return nil, err
+	}
+	header := &MessageArchiveHeader{
+		Version:      messageArchiveVersion,
+		ChainId:      streamer.bc.Config().ChainID,
+		FirstBatch:   fromBatch,
+		BatchCount:   toBatch - fromBatch + 1,
+		FirstMessage: prevMeta.MessageCount,
+		MessageCount: uint64(lastMeta.MessageCount - prevMeta.MessageCount),
+		FirstDelayed: prevMeta.DelayedMessageCount,
+		DelayedCount: lastMeta.DelayedMessageCount - prevMeta.DelayedMessageCount,
+	}
+
+	if _, err := w.Write(messageArchiveMagic); err != nil {
+		return nil, err
+	}
+	archive := &messageArchiveWriter{
+		out:    gzip.NewWriter(w),
+		hasher: crypto.NewKeccakState(),
+	}
+	if err := archive.writeItem(header); err != nil {
+		return nil, err
+	}
+	for seqNum := prevMeta.DelayedMessageCount; seqNum < lastMeta.DelayedMessageCount; seqNum++ {
+		msg, err := tracker.GetDelayedMessage(seqNum)
+		if err != nil {
+			return nil, err
+		}
+		if err := archive.writeItem(msg); err != nil {
+			return nil, err
+		}
+	}
+	batchStart := prevMeta.MessageCount
+	for seqNum := fromBatch; seqNum <= toBatch; seqNum++ {
+		meta, err := tracker.GetBatchMetadata(seqNum)
+		if err != nil {
+			return nil, err
+		}
+		batch := messageArchiveBatch{Metadata: meta}
+		for index := batchStart; index < meta.MessageCount; index++ {
+			msg, err := streamer.GetMessage(index)
+			if err != nil {
+				return nil, err
+			}
+			batch.Messages = append(batch.Messages, *msg)
+		}
+		if err := archive.writeItem(batch); err != nil {
+			return nil, err
+		}
+		batchStart = meta.MessageCount
+	}
+
+	// make sure the batches weren't reorged while we read them
+	finalMeta, err := tracker.GetBatchMetadata(toBatch)
+	if err != nil {
+		return nil, err
+	}
+	if finalMeta.Accumulator != lastMeta.Accumulator {
+		return nil, errors.New("batches reorged during export")
+	}
+	return header, archive.close()
+}
+
+// ExportMessageArchiveFile writes a message archive to the given path, replacing it only once the archive is complete
+func ExportMessageArchiveFile(path string, streamer *TransactionStreamer, tracker *InboxTracker, fromBatch, toBatch uint64) (*MessageArchiveHeader, error) {
+	tmpPath := path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return nil, err
+	}
+	header, err := ExportMessageArchive(file, streamer, tracker, fromBatch, toBatch)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return nil, err
+	}
+	return header, os.Rename(tmpPath, path)
+}
+
+type messageArchiveReader struct {
+	stream *rlp.Stream
+	hasher hash.Hash
+}
+
+func (r *messageArchiveReader) readItem(val interface{}) error {
+	raw, err := r.stream.Raw()
+	if err != nil {
+		return err
+	}
+	r.hasher.Write(raw)
+	return rlp.DecodeBytes(raw, val)
+}
+
+// readMessageArchive reads an archive, checking its consistency and checksum, and calls the callbacks, if any, with its contents in order.
+// The contents have only been checked against the checksum once it returns without error.
+func readMessageArchive(
+	r io.Reader,
+	onDelayed func(seqNum uint64, msg *arbos.L1IncomingMessage) error,
+	onBatch func(seqNum uint64, batch *messageArchiveBatch) error,
+) (*MessageArchiveHeader, error) {
+	magic := make([]byte, len(messageArchiveMagic))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(magic, messageArchiveMagic) {
+		return nil, errors.New("not a message archive")
+	}
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	archive := &messageArchiveReader{
+		stream: rlp.NewStream(gz, 0),
+		hasher: crypto.NewKeccakState(),
+	}
+
+	var header MessageArchiveHeader
+	if err := archive.readItem(&header); err != nil {
+		return nil, err
+	}
+	if header.Version != messageArchiveVersion {
+		return nil, fmt.Errorf("unsupported message archive version %v", header.Version)
+	}
+	for i := uint64(0); i < header.DelayedCount; i++ {
+		var msg *arbos.L1IncomingMessage
+		if err := archive.readItem(&msg); err != nil {
+			return nil, err
+		}
+		seqNum, err := msg.Header.SeqNum()
+		if err != nil {
+			return nil, err
+		}
+		if seqNum != header.FirstDelayed+i {
+			return nil, fmt.Errorf("expected delayed message %v but got %v", header.FirstDelayed+i, seqNum)
+		}
+		if onDelayed != nil {
+			if err := onDelayed(seqNum, msg); err != nil {
+				return nil, err
+			}
+		}
+	}
+	messageCount := header.FirstMessage
+	delayedCount := header.FirstDelayed
+	for i := uint64(0); i < header.BatchCount; i++ {
+		var batch messageArchiveBatch
+		if err := archive.readItem(&batch); err != nil {
+			return nil, err
+		}
+		messageCount += arbutil.MessageIndex(len(batch.Messages))
+		if batch.Metadata.MessageCount != messageCount {
+			return nil, fmt.Errorf("batch %v has message count %v but the archive has %v", header.FirstBatch+i, batch.Metadata.MessageCount, messageCount)
+		}
+		if batch.Metadata.DelayedMessageCount < delayedCount || batch.Metadata.DelayedMessageCount > header.FirstDelayed+header.DelayedCount {
+			return nil, fmt.Errorf("batch %v has unexpected delayed message count %v", header.FirstBatch+i, batch.Metadata.DelayedMessageCount)
+		}
+		delayedCount = batch.Metadata.DelayedMessageCount
+		if onBatch != nil {
+			if err := onBatch(header.FirstBatch+i, &batch); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if uint64(messageCount-header.FirstMessage) != header.MessageCount {
+		return nil, fmt.Errorf("archive header claims %v messages but has %v", header.MessageCount, messageCount-header.FirstMessage)
+	}
+
+	expectedChecksum := common.BytesToHash(archive.hasher.Sum(nil))
+	var checksum common.Hash
+	if err := archive.stream.Decode(&checksum); err != nil {
+		return nil, err
+	}
+	if checksum != expectedChecksum {
+		return nil, fmt.Errorf("message archive checksum %v doesn't match its contents' %v", checksum, expectedChecksum)
+	}
+	if _, err := archive.stream.Raw(); !errors.Is(err, io.EOF) {
+		return nil, errors.New("unexpected data after message archive checksum")
+	}
+	return &header, nil
+}
+
+// VerifyMessageArchive checks an archive's consistency and checksum, returning its header
+func VerifyMessageArchive(r io.Reader) (*MessageArchiveHeader, error) {
+	return readMessageArchive(r, nil, nil)
+}
+
+// MessageArchiveL1Reader reads the inbox from L1, to verify the contents of an imported message archive
+type MessageArchiveL1Reader interface {
+	Client() arbutil.L1Interface
+	LookupBatchesInRange(ctx context.Context, from, to *big.Int) ([]*SequencerInboxBatch, error)
+	GetDelayedAccumulator(ctx context.Context, seqNum uint64) (common.Hash, error)
+}
+
+// ImportMessageArchiveFile checks an archive, then adds its contents to the database, which must end where the archive starts.
+// The imported batches are derived again from their data on L1, and removed again if any message differs from the archive's.
+func ImportMessageArchiveFile(ctx context.Context, path string, streamer *TransactionStreamer, tracker *InboxTracker, l1Reader MessageArchiveL1Reader) (*MessageArchiveHeader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var lastMeta BatchMetadata
+	header, err := readMessageArchive(file, nil, func(seqNum uint64, batch *messageArchiveBatch) error {
+		lastMeta = batch.Metadata
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if chainId := streamer.bc.Config().ChainID; header.ChainId.Cmp(chainId) != 0 {
+		return nil, fmt.Errorf("message archive is for chain %v but this is chain %v", header.ChainId, chainId)
+	}
+	batchCount, err := tracker.GetBatchCount()
+	if err != nil {
+		return nil, err
+	}
+	messageCount, err := streamer.GetMessageCount()
+	if err != nil {
+		return nil, err
+	}
+	delayedCount, err := tracker.GetDelayedCount()
+	if err != nil {
+		return nil, err
+	}
+	if header.BatchCount > 0 && batchCount >= header.FirstBatch+header.BatchCount {
+		// the archive was imported before, or its batches have since been read from L1
+		lastSeqNum := header.FirstBatch + header.BatchCount - 1
+		meta, err := tracker.GetBatchMetadata(lastSeqNum)
+		if err != nil {
+			return nil, err
+		}
+		if meta != lastMeta {
+			return nil, fmt.Errorf("database already has batch %v, but with different metadata than the message archive", lastSeqNum)
+		}
+		log.Info("database already has message archive's batches, skipping import", "path", path, "lastBatch", lastSeqNum)
+		return header, nil
+	}
+	if batchCount != header.FirstBatch || messageCount != header.FirstMessage || delayedCount != header.FirstDelayed {
+		return nil, fmt.Errorf(
+			"message archive starts at batch %v, message %v, and delayed message %v, but the database has %v, %v, and %v",
+			header.FirstBatch, header.FirstMessage, header.FirstDelayed, batchCount, messageCount, delayedCount,
+		)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	var delayed []*DelayedInboxMessage
+	var nextDelayedAcc common.Hash
+	if header.FirstDelayed > 0 {
+		nextDelayedAcc, err = tracker.GetDelayedAcc(header.FirstDelayed - 1)
+		if err != nil {
+			return nil, err
+		}
+	}
+	flushDelayed := func() error {
+		err := tracker.AddDelayedMessages(delayed, false)
+		delayed = delayed[:0]
+		return err
+	}
+	onDelayed := func(seqNum uint64, msg *arbos.L1IncomingMessage) error {
+		message := &DelayedInboxMessage{BeforeInboxAcc: nextDelayedAcc, Message: msg}
+		nextDelayedAcc = message.AfterInboxAcc()
+		delayed = append(delayed, message)
+		if len(delayed) >= messageArchiveImportDelayedChunk {
+			return flushDelayed()
+		}
+		return nil
+	}
+
+	var firstSeqNum uint64
+	var metas []BatchMetadata
+	var messages []arbstate.MessageWithMetadata
+	flushBatches := func() error {
+		err := tracker.AddBatchesWithMessages(firstSeqNum, metas, messages)
+		metas = metas[:0]
+		messages = messages[:0]
+		return err
+	}
+	onBatch := func(seqNum uint64, batch *messageArchiveBatch) error {
+		if len(delayed) > 0 {
+			if err := flushDelayed(); err != nil {
+				return err
+			}
+		}
+		if len(metas) == 0 {
+			firstSeqNum = seqNum
+		}
+		metas = append(metas, batch.Metadata)
+		messages = append(messages, batch.Messages...)
+		if len(metas) >= messageArchiveImportBatchChunk {
+			return flushBatches()
+		}
+		return nil
+	}
+	// the archive's contents are only trusted once they're verified, so anything added is removed on failure
+	removeImported := func(err error) error {
+		if reorgErr := tracker.ReorgBatchesTo(header.FirstBatch); reorgErr != nil {
+			log.Error("failed to remove batches imported from message archive", "err", reorgErr)
+		}
+		if reorgErr := tracker.ReorgDelayedTo(header.FirstDelayed, true); reorgErr != nil {
+			log.Error("failed to remove delayed messages imported from message archive", "err", reorgErr)
+		}
+		return fmt.Errorf("removed message archive contents after failing to import it: %w", err)
+	}
+	// if the file changed since it was checked, this fails partway
+	if _, err := readMessageArchive(file, onDelayed, onBatch); err != nil {
+		return nil, removeImported(err)
+	}
+	if err := flushDelayed(); err != nil {
+		return nil, removeImported(err)
+	}
+	if err := flushBatches(); err != nil {
+		return nil, removeImported(err)
+	}
+	if err := verifyImportedMessageArchive(ctx, header, streamer, tracker, l1Reader); err != nil {
+		return nil, removeImported(err)
+	}
+	log.Info("imported message archive", "path", path, "batches", header.BatchCount, "messages", header.MessageCount, "delayedMessages", header.DelayedCount)
+	return header, nil
+}
+
+// verifyImportedMessageArchive checks an imported archive's delayed messages against the L1 accumulator,
+// and derives its batches again from their data on L1, comparing each message with the imported one
+func verifyImportedMessageArchive(ctx context.Context, header *MessageArchiveHeader, streamer *TransactionStreamer, tracker *InboxTracker, l1Reader MessageArchiveL1Reader) error {
+	if header.DelayedCount > 0 {
+		lastDelayed := header.FirstDelayed + header.DelayedCount - 1
+		acc, err := tracker.GetDelayedAcc(lastDelayed)
+		if err != nil {
+			return err
+		}
+		l1Acc, err := l1Reader.GetDelayedAccumulator(ctx, lastDelayed)
+		if err != nil {
+			return err
+		}
+		if acc != l1Acc {
+			return fmt.Errorf("delayed message %v has accumulator %v but L1 has %v", lastDelayed, acc, l1Acc)
+		}
+	}
+
+	var prevMeta BatchMetadata
+	if header.FirstBatch > 0 {
+		var err error
+		prevMeta, err = tracker.GetBatchMetadata(header.FirstBatch - 1)
+		if err != nil {
+			return err
+		}
+	}
+	endBatch := header.FirstBatch + header.BatchCount
+	for fromBatch := header.FirstBatch; fromBatch < endBatch; fromBatch += messageArchiveImportBatchChunk {
+		toBatch := arbmath.MinUint(fromBatch+messageArchiveImportBatchChunk, endBatch)
+		var metas []BatchMetadata
+		for seqNum := fromBatch; seqNum < toBatch; seqNum++ {
+			meta, err := tracker.GetBatchMetadata(seqNum)
+			if err != nil {
+				return err
+			}
+			metas = append(metas, meta)
+		}
+		fromBlock := new(big.Int).SetUint64(metas[0].L1Block)
+		toBlock := new(big.Int).SetUint64(metas[len(metas)-1].L1Block)
+		l1Batches, err := l1Reader.LookupBatchesInRange(ctx, fromBlock, toBlock)
+		if err != nil {
+			return err
+		}
+		var batches []*SequencerInboxBatch
+		for _, batch := range l1Batches {
+			if batch.SequenceNumber >= fromBatch && batch.SequenceNumber < toBatch {
+				batches = append(batches, batch)
+			}
+		}
+		if uint64(len(batches)) != toBatch-fromBatch {
+			return fmt.Errorf("found %v of batches %v to %v on L1 in the blocks the message archive has them in", len(batches), fromBatch, toBatch-1)
+		}
+		for i, batch := range batches {
+			meta := metas[i]
+			if batch.SequenceNumber != fromBatch+uint64(i) || batch.BlockNumber != meta.L1Block || batch.AfterInboxAcc != meta.Accumulator || batch.AfterDelayedCount != meta.DelayedMessageCount {
+				return fmt.Errorf("batch %v on L1 doesn't match the message archive's metadata %v", batch.SequenceNumber, meta)
+			}
+			if batch.AfterDelayedCount > 0 {
+				delayedAcc, err := tracker.GetDelayedAcc(batch.AfterDelayedCount - 1)
+				if err != nil {
+					return err
+				}
+				if delayedAcc != batch.AfterDelayedAcc {
+					return fmt.Errorf("batch %v on L1 read delayed messages with accumulator %v but the message archive's have %v", batch.SequenceNumber, batch.AfterDelayedAcc, delayedAcc)
+				}
+			}
+		}
+
+		backend := &multiplexerBackend{
+			batchSeqNum: fromBatch,
+			batches:     batches,
+
+			inbox:  tracker,
+			ctx:    ctx,
+			client: l1Reader.Client(),
+		}
+		multiplexer := arbstate.NewInboxMultiplexer(backend, prevMeta.DelayedMessageCount, tracker.das, tracker.externalDA, arbstate.KeysetValidate)
+		pos := prevMeta.MessageCount
+		for i := range batches {
+			for backend.GetSequencerInboxPosition() == fromBatch+uint64(i) {
+				derived, err := multiplexer.Pop(ctx)
+				if err != nil {
+					return err
+				}
+				derivedBytes, err := rlp.EncodeToBytes(derived)
+				if err != nil {
+					return err
+				}
+				imported, err := streamer.GetMessage(pos)
+				if err != nil {
+					return err
+				}
+				importedBytes, err := rlp.EncodeToBytes(imported)
+				if err != nil {
+					return err
+				}
+				if !bytes.Equal(derivedBytes, importedBytes) {
+					return fmt.Errorf("message %v from the message archive differs from the one derived from batch %v on L1", pos, fromBatch+uint64(i))
+				}
+				pos++
+			}
+			if pos != metas[i].MessageCount {
+				return fmt.Errorf("batch %v on L1 has %v messages but the message archive has %v", fromBatch+uint64(i), pos-prevMeta.MessageCount, metas[i].MessageCount-prevMeta.MessageCount)
+			}
+			prevMeta = metas[i]
+		}
+	}
+	return nil
+}
+
+// VerifyInboxAgainstL1 checks the latest batch and delayed message accumulators in the database match the L1 contracts'
+func VerifyInboxAgainstL1(ctx context.Context, tracker *InboxTracker, seqInbox *SequencerInbox, delayedBridge *DelayedBridge) error {
+	batchCount, err := tracker.GetBatchCount()
+	if err != nil {
+		return err
+	}
+	if batchCount > 0 {
+		acc, err := tracker.GetBatchAcc(batchCount - 1)
+		if err != nil {
+			return err
+		}
+		l1Acc, err := seqInbox.GetAccumulator(ctx, batchCount-1, nil)
+		if err != nil {
+			return err
+		}
+		if acc != l1Acc {
+			return fmt.Errorf("batch %v has accumulator %v but L1 has %v", batchCount-1, acc, l1Acc)
+		}
+	}
+	delayedCount, err := tracker.GetDelayedCount()
+	if err != nil {
+		return err
+	}
+	if delayedCount > 0 {
+		acc, err := tracker.GetDelayedAcc(delayedCount - 1)
+		if err != nil {
+			return err
+		}
+		l1Acc, err := delayedBridge.GetAccumulator(ctx, delayedCount-1, nil)
+		if err != nil {
+			return err
+		}
+		if acc != l1Acc {
+			return fmt.Errorf("delayed message %v has accumulator %v but L1 has %v", delayedCount-1, acc, l1Acc)
+		}
+	}
+	return nil
+}
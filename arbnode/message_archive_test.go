@@ -0,0 +1,314 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/offchainlabs/nitro/arbos"
+	"github.com/offchainlabs/nitro/arbutil"
+)
+
+func newInboxForArchiveTest(t *testing.T, withUserBatches bool) (*TransactionStreamer, *InboxTracker) {
+	ctx := context.Background()
+	streamer, db, _ := NewTransactionStreamerForTest(t, common.Address{})
+	tracker, err := NewInboxTracker(db, streamer, nil, nil)
+	Require(t, err)
+	Require(t, tracker.Initialize())
+
+	init, err := streamer.GetMessage(0)
+	Require(t, err)
+	delayed := []*DelayedInboxMessage{{Message: init.Message}}
+	serializedInitMsgBatch := make([]byte, 40)
+	binary.BigEndian.PutUint64(serializedInitMsgBatch[32:], 1)
+	batches := []*SequencerInboxBatch{{
+		SequenceNumber:    0,
+		AfterInboxAcc:     common.Hash{1},
+		AfterDelayedAcc:   delayed[0].AfterInboxAcc(),
+		AfterDelayedCount: 1,
+		serialized:        serializedInitMsgBatch,
+	}}
+
+	if withUserBatches {
+		delayedRequestId := common.BigToHash(common.Big1)
+		delayed = append(delayed, &DelayedInboxMessage{
+			BeforeInboxAcc: delayed[0].AfterInboxAcc(),
+			Message: &arbos.L1IncomingMessage{
+				Header: &arbos.L1IncomingMessageHeader{
+					Kind:      arbos.L1MessageType_EndOfBlock,
+					RequestId: &delayedRequestId,
+					L1BaseFee: common.Big0,
+				},
+			},
+		})
+		serializedUserMsgBatch := make([]byte, 40)
+		binary.BigEndian.PutUint64(serializedUserMsgBatch[32:], 2)
+		for i := uint64(1); i <= 2; i++ {
+			batches = append(batches, &SequencerInboxBatch{
+				BlockNumber:       i,
+				SequenceNumber:    i,
+				BeforeInboxAcc:    common.Hash{byte(i)},
+				AfterInboxAcc:     common.Hash{byte(i + 1)},
+				AfterDelayedAcc:   delayed[1].AfterInboxAcc(),
+				AfterDelayedCount: 2,
+				serialized:        serializedUserMsgBatch,
+			})
+		}
+	}
+
+	Require(t, tracker.AddDelayedMessages(delayed, false))
+	Require(t, tracker.AddSequencerBatches(ctx, nil, batches))
+	return streamer, tracker
+}
+
+// archiveTestL1 serves an inbox's batches and delayed accumulators, as if they were read from L1
+type archiveTestL1 struct {
+	batches     []*SequencerInboxBatch
+	delayedAccs []common.Hash
+}
+
+func newArchiveTestL1(t *testing.T, tracker *InboxTracker) *archiveTestL1 {
+	l1 := &archiveTestL1{}
+	batchCount, err := tracker.GetBatchCount()
+	Require(t, err)
+	var prevAcc common.Hash
+	for seqNum := uint64(0); seqNum < batchCount; seqNum++ {
+		meta, err := tracker.GetBatchMetadata(seqNum)
+		Require(t, err)
+		delayedAcc, err := tracker.GetDelayedAcc(meta.DelayedMessageCount - 1)
+		Require(t, err)
+		serialized := make([]byte, 40)
+		binary.BigEndian.PutUint64(serialized[32:], meta.DelayedMessageCount)
+		l1.batches = append(l1.batches, &SequencerInboxBatch{
+			BlockNumber:       meta.L1Block,
+			SequenceNumber:    seqNum,
+			BeforeInboxAcc:    prevAcc,
+			AfterInboxAcc:     meta.Accumulator,
+			AfterDelayedAcc:   delayedAcc,
+			AfterDelayedCount: meta.DelayedMessageCount,
+			serialized:        serialized,
+		})
+		prevAcc = meta.Accumulator
+	}
+	delayedCount, err := tracker.GetDelayedCount()
+	Require(t, err)
+	for seqNum := uint64(0); seqNum < delayedCount; seqNum++ {
+		acc, err := tracker.GetDelayedAcc(seqNum)
+		Require(t, err)
+		l1.delayedAccs = append(l1.delayedAccs, acc)
+	}
+	return l1
+}
+
+func (l *archiveTestL1) Client() arbutil.L1Interface {
+	return nil
+}
+
+func (l *archiveTestL1) LookupBatchesInRange(ctx context.Context, from, to *big.Int) ([]*SequencerInboxBatch, error) {
+	var batches []*SequencerInboxBatch
+	for _, batch := range l.batches {
+		if batch.BlockNumber >= from.Uint64() && batch.BlockNumber <= to.Uint64() {
+			batches = append(batches, batch)
+		}
+	}
+	return batches, nil
+}
+
+func (l *archiveTestL1) GetDelayedAccumulator(ctx context.Context, seqNum uint64) (common.Hash, error) {
+	if seqNum >= uint64(len(l.delayedAccs)) {
+		return common.Hash{}, errors.New("delayed message not found")
+	}
+	return l.delayedAccs[seqNum], nil
+}
+
+// rewriteMessageArchive writes a copy of an archive with edited batches, and a checksum matching the edits
+func rewriteMessageArchive(t *testing.T, path string, rewrittenPath string, edit func(seqNum uint64, batch *messageArchiveBatch)) {
+	file, err := os.Open(path)
+	Require(t, err)
+	defer file.Close()
+	var delayed []*arbos.L1IncomingMessage
+	var batches []*messageArchiveBatch
+	header, err := readMessageArchive(file, func(seqNum uint64, msg *arbos.L1IncomingMessage) error {
+		delayed = append(delayed, msg)
+		return nil
+	}, func(seqNum uint64, batch *messageArchiveBatch) error {
+		edit(seqNum, batch)
+		batches = append(batches, batch)
+		return nil
+	})
+	Require(t, err)
+
+	out, err := os.Create(rewrittenPath)
+	Require(t, err)
+	defer out.Close()
+	_, err = out.Write(messageArchiveMagic)
+	Require(t, err)
+	archive := &messageArchiveWriter{
+		out:    gzip.NewWriter(out),
+		hasher: crypto.NewKeccakState(),
+	}
+	Require(t, archive.writeItem(header))
+	for _, msg := range delayed {
+		Require(t, archive.writeItem(msg))
+	}
+	for _, batch := range batches {
+		Require(t, archive.writeItem(batch))
+	}
+	Require(t, archive.close())
+}
+
+func TestMessageArchiveRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	srcStreamer, srcTracker := newInboxForArchiveTest(t, true)
+	dstStreamer, dstTracker := newInboxForArchiveTest(t, false)
+	l1 := newArchiveTestL1(t, srcTracker)
+
+	path := filepath.Join(t.TempDir(), "archive")
+	header, err := ExportMessageArchiveFile(path, srcStreamer, srcTracker, 1, 2)
+	Require(t, err)
+	if header.FirstBatch != 1 || header.BatchCount != 2 || header.FirstMessage != 1 || header.MessageCount != 1 || header.FirstDelayed != 1 || header.DelayedCount != 1 {
+		Fail(t, "unexpected archive header", header)
+	}
+
+	// an archive must start where the database ends
+	gapPath := filepath.Join(t.TempDir(), "gap-archive")
+	_, err = ExportMessageArchiveFile(gapPath, srcStreamer, srcTracker, 2, 2)
+	Require(t, err)
+	_, err = ImportMessageArchiveFile(ctx, gapPath, dstStreamer, dstTracker, l1)
+	if err == nil {
+		Fail(t, "imported archive starting after the database ends")
+	}
+
+	_, err = ImportMessageArchiveFile(ctx, path, dstStreamer, dstTracker, l1)
+	Require(t, err)
+	for batch := uint64(0); batch < 3; batch++ {
+		srcMeta, err := srcTracker.GetBatchMetadata(batch)
+		Require(t, err)
+		dstMeta, err := dstTracker.GetBatchMetadata(batch)
+		Require(t, err)
+		if srcMeta != dstMeta {
+			Fail(t, "batch", batch, "metadata", dstMeta, "expected", srcMeta)
+		}
+	}
+	srcDelayedAcc, err := srcTracker.GetDelayedAcc(1)
+	Require(t, err)
+	dstDelayedAcc, err := dstTracker.GetDelayedAcc(1)
+	Require(t, err)
+	if srcDelayedAcc != dstDelayedAcc {
+		Fail(t, "delayed accumulator", dstDelayedAcc, "expected", srcDelayedAcc)
+	}
+
+	// importing the same archive again, as on a restart, is skipped
+	_, err = ImportMessageArchiveFile(ctx, path, dstStreamer, dstTracker, l1)
+	Require(t, err)
+	batchCount, err := dstTracker.GetBatchCount()
+	Require(t, err)
+	if batchCount != 3 {
+		Fail(t, "reimporting archive changed batch count to", batchCount)
+	}
+
+	srcMsg, err := srcStreamer.GetMessage(1)
+	Require(t, err)
+	dstMsg, err := dstStreamer.GetMessage(1)
+	Require(t, err)
+	if !reflect.DeepEqual(srcMsg, dstMsg) {
+		Fail(t, "imported message", dstMsg, "expected", srcMsg)
+	}
+}
+
+func TestMessageArchiveImportVerifiedAgainstL1(t *testing.T) {
+	ctx := context.Background()
+	srcStreamer, srcTracker := newInboxForArchiveTest(t, true)
+	dstStreamer, dstTracker := newInboxForArchiveTest(t, false)
+	l1 := newArchiveTestL1(t, srcTracker)
+
+	path := filepath.Join(t.TempDir(), "archive")
+	_, err := ExportMessageArchiveFile(path, srcStreamer, srcTracker, 1, 2)
+	Require(t, err)
+	forgedPath := filepath.Join(t.TempDir(), "forged-archive")
+	rewriteMessageArchive(t, path, forgedPath, func(seqNum uint64, batch *messageArchiveBatch) {
+		if seqNum == 1 {
+			batch.Messages[0].Message.Header.Timestamp++
+		}
+	})
+	// the forged archive's checksum matches, so only deriving its batches from L1 catches it
+	file, err := os.Open(forgedPath)
+	Require(t, err)
+	_, err = VerifyMessageArchive(file)
+	file.Close()
+	Require(t, err)
+	_, err = ImportMessageArchiveFile(ctx, forgedPath, dstStreamer, dstTracker, l1)
+	if err == nil {
+		Fail(t, "imported a forged message archive")
+	}
+	batchCount, err := dstTracker.GetBatchCount()
+	Require(t, err)
+	messageCount, err := dstStreamer.GetMessageCount()
+	Require(t, err)
+	delayedCount, err := dstTracker.GetDelayedCount()
+	Require(t, err)
+	if batchCount != 1 || messageCount != 1 || delayedCount != 1 {
+		Fail(t, "forged message archive wasn't removed, leaving", batchCount, "batches,", messageCount, "messages, and", delayedCount, "delayed messages")
+	}
+
+	// with the forged contents removed, the genuine archive can be imported
+	_, err = ImportMessageArchiveFile(ctx, path, dstStreamer, dstTracker, l1)
+	Require(t, err)
+	srcMsg, err := srcStreamer.GetMessage(1)
+	Require(t, err)
+	dstMsg, err := dstStreamer.GetMessage(1)
+	Require(t, err)
+	if !reflect.DeepEqual(srcMsg, dstMsg) {
+		Fail(t, "imported message", dstMsg, "expected", srcMsg)
+	}
+}
+
+func TestMessageArchiveChecksum(t *testing.T) {
+	streamer, tracker := newInboxForArchiveTest(t, true)
+	path := filepath.Join(t.TempDir(), "archive")
+	_, err := ExportMessageArchiveFile(path, streamer, tracker, 0, 2)
+	Require(t, err)
+
+	data, err := os.ReadFile(path)
+	Require(t, err)
+	for _, corruptAt := range []int{len(messageArchiveMagic) - 1, len(data) / 2, len(data) - 1} {
+		corrupted := append([]byte{}, data...)
+		corrupted[corruptAt] ^= 1
+		corruptedPath := path + "-corrupted"
+		Require(t, os.WriteFile(corruptedPath, corrupted, 0o600))
+		file, err := os.Open(corruptedPath)
+		Require(t, err)
+		_, err = VerifyMessageArchive(file)
+		file.Close()
+		if err == nil {
+			Fail(t, "accepted archive corrupted at byte", corruptAt)
+		}
+	}
+}
+
+func TestMessageArchiveExportPath(t *testing.T) {
+	dir := t.TempDir()
+	if path, err := MessageArchiveExportPath(dir, "archives/batches-1-2"); err != nil || path != filepath.Join(dir, "archives", "batches-1-2") {
+		Fail(t, "unexpected export path", path, err)
+	}
+	if _, err := MessageArchiveExportPath("", "archive"); err == nil {
+		Fail(t, "exported without an export directory")
+	}
+	for _, name := range []string{"", ".", "..", "../archive", "archives/../../archive", filepath.Join(dir, "archive")} {
+		if path, err := MessageArchiveExportPath(dir, name); err == nil {
+			Fail(t, "export path", name, "resolved to", path, "instead of being rejected")
+		}
+	}
+}
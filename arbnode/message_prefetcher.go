@@ -0,0 +1,284 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"math/big"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/pkg/errors"
+
+	"github.com/offchainlabs/nitro/arbos"
+	"github.com/offchainlabs/nitro/arbstate"
+	"github.com/offchainlabs/nitro/arbutil"
+)
+
+// prefetchedMessage is a message ready for block creation, with its transactions parsed and their senders recovered
+type prefetchedMessage struct {
+	pos    arbutil.MessageIndex
+	msg    *arbstate.MessageWithMetadata
+	txes   types.Transactions
+	signer types.Signer // the senders are cached for this signer
+	err    error
+
+	pending int32 // atomic, the number of unfinished tasks: parsing, and recovering each sender
+	done    chan struct{}
+}
+
+func (m *prefetchedMessage) finishTask() {
+	if atomic.AddInt32(&m.pending, -1) == 0 {
+		close(m.done)
+	}
+}
+
+type senderRecoveryJob struct {
+	message *prefetchedMessage
+	signer  types.Signer
+	tx      *types.Transaction
+}
+
+// messagePrefetcher parses the messages after the one being made into a block, and recovers their senders, using pools of workers.
+// The messages must not be reorged while it's in use.
+type messagePrefetcher struct {
+	streamer     *TransactionStreamer
+	chainConfig  *params.ChainConfig
+	batchFetcher arbos.FallibleBatchFetcher
+	maxQueued    int
+	next         arbutil.MessageIndex
+	end          arbutil.MessageIndex
+	queue        []*prefetchedMessage
+
+	parseJobs  chan *prefetchedMessage
+	senderJobs chan senderRecoveryJob
+	ctx        context.Context
+	cancel     context.CancelFunc
+	workers    sync.WaitGroup
+}
+
+func workerCount(configured int) int {
+	if configured <= 0 {
+		return runtime.NumCPU()
+	}
+	return configured
+}
+
+// newMessagePrefetcher prefetches the messages from start up to end, which must stay in the database until it's stopped
+func newMessagePrefetcher(ctx context.Context, streamer *TransactionStreamer, batchFetcher arbos.FallibleBatchFetcher, start arbutil.MessageIndex, end arbutil.MessageIndex) *messagePrefetcher {
+	config := streamer.configFetcher()
+	maxQueued := config.PrefetchMessages
+	if maxQueued < 1 {
+		maxQueued = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	p := &messagePrefetcher{
+		streamer:     streamer,
+		chainConfig:  streamer.bc.Config(),
+		batchFetcher: batchFetcher,
+		maxQueued:    maxQueued,
+		next:         start,
+		end:          end,
+		parseJobs:    make(chan *prefetchedMessage, maxQueued),
+		senderJobs:   make(chan senderRecoveryJob, maxQueued),
+		ctx:          ctx,
+		cancel:       cancel,
+	}
+	for i := 0; i < workerCount(config.ParseWorkers); i++ {
+		p.launchWorker(func() {
+			for {
+				select {
+				case message := <-p.parseJobs:
+					p.parse(message)
+				case <-p.ctx.Done():
+					return
+				}
+			}
+		})
+	}
+	for i := 0; i < workerCount(config.SenderRecoveryWorkers); i++ {
+		p.launchWorker(func() {
+			for {
+				select {
+				case job := <-p.senderJobs:
+					// An invalid signature is reported when the tx is executed
+					_, _ = types.Sender(job.signer, job.tx)
+					job.message.finishTask()
+				case <-p.ctx.Done():
+					return
+				}
+			}
+		})
+	}
+	p.fill()
+	return p
+}
+
+func (p *messagePrefetcher) launchWorker(work func()) {
+	p.workers.Add(1)
+	go func() {
+		defer p.workers.Done()
+		work()
+	}()
+}
+
+func (p *messagePrefetcher) parse(message *prefetchedMessage) {
+	defer message.finishTask()
+	msg, err := p.streamer.GetMessage(message.pos)
+	if err != nil {
+		message.err = err
+		return
+	}
+	message.msg = msg
+	message.txes, err = arbos.ParseBlockTransactions(msg.Message, p.chainConfig, p.batchFetcher)
+	if err != nil {
+		message.err = err
+		return
+	}
+	blockNum, err := p.streamer.MessageCountToBlockNumber(message.pos + 1)
+	if err != nil {
+		message.err = err
+		return
+	}
+	signer := types.MakeSigner(p.chainConfig, big.NewInt(blockNum))
+	message.signer = signer
+	atomic.AddInt32(&message.pending, int32(len(message.txes)))
+	for _, tx := range message.txes {
+		select {
+		case p.senderJobs <- senderRecoveryJob{message, signer, tx}:
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
+// fill queues messages to be prefetched, up to the configured amount
+func (p *messagePrefetcher) fill() {
+	for len(p.queue) < p.maxQueued && p.next < p.end {
+		message := &prefetchedMessage{
+			pos:     p.next,
+			pending: 1,
+			done:    make(chan struct{}),
+		}
+		p.queue = append(p.queue, message)
+		// The channel has room for every queued message, and finished messages are out of it, so this doesn't block
+		p.parseJobs <- message
+		p.next++
+	}
+}
+
+// Next waits for the next message to be prefetched, and returns it
+func (p *messagePrefetcher) Next() (*prefetchedMessage, error) {
+	if len(p.queue) == 0 {
+		return nil, errors.New("no messages left to prefetch")
+	}
+	message := p.queue[0]
+	p.queue = p.queue[1:]
+	select {
+	case <-message.done:
+	case <-p.ctx.Done():
+		return nil, p.ctx.Err()
+	}
+	p.fill()
+	return message, message.err
+}
+
+// Upcoming returns up to count of the messages queued after the last one returned by Next, which may still be being prefetched
+func (p *messagePrefetcher) Upcoming(count int) []*prefetchedMessage {
+	if count > len(p.queue) {
+		count = len(p.queue)
+	}
+	return p.queue[:count]
+}
+
+func (p *messagePrefetcher) StopAndWait() {
+	p.cancel()
+	p.workers.Wait()
+}
+
+// stateWarmer loads the accounts, code and storage that upcoming messages' transactions are known to read,
+// so they're cached by the time their blocks are really created. Nothing is executed, so the reads don't depend
+// on the blocks before them, and the warmed cache stays useful after those blocks are created.
+type stateWarmer struct {
+	interrupted uint32 // atomic
+	interrupt   chan struct{}
+	done        chan struct{}
+	warmedTxs   int // only read once done
+}
+
+// warmState starts loading the read-sets of the messages' transactions from a copy of statedb.
+// The copy must be taken before statedb is modified.
+func warmState(statedb *state.StateDB, messages []*prefetchedMessage) *stateWarmer {
+	w := &stateWarmer{
+		interrupt: make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	statedb = statedb.Copy()
+	go func() {
+		defer close(w.done)
+		for _, message := range messages {
+			select {
+			case <-message.done:
+			case <-w.interrupt:
+				return
+			}
+			if message.err != nil {
+				return
+			}
+			for _, tx := range message.txes {
+				if w.isInterrupted() {
+					return
+				}
+				warmTxReadSet(statedb, message.signer, tx)
+				w.warmedTxs++
+			}
+		}
+	}()
+	return w
+}
+
+// warmTxReadSet loads what the tx reads before executing: its sender, its recipient's code, and its access list
+func warmTxReadSet(statedb *state.StateDB, signer types.Signer, tx *types.Transaction) {
+	if sender, err := types.Sender(signer, tx); err == nil {
+		statedb.GetBalance(sender)
+	}
+	if to := tx.To(); to != nil {
+		statedb.GetCode(*to)
+	}
+	for _, access := range tx.AccessList() {
+		statedb.GetCodeHash(access.Address)
+		for _, key := range access.StorageKeys {
+			statedb.GetState(access.Address, key)
+		}
+	}
+}
+
+func (w *stateWarmer) isInterrupted() bool {
+	return atomic.LoadUint32(&w.interrupted) != 0
+}
+
+func (w *stateWarmer) Interrupt() {
+	if atomic.CompareAndSwapUint32(&w.interrupted, 0, 1) {
+		close(w.interrupt)
+	}
+}
+
+// Finished returns whether the warmer has stopped executing messages
+func (w *stateWarmer) Finished() bool {
+	select {
+	case <-w.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (w *stateWarmer) InterruptAndWait() {
+	w.Interrupt()
+	<-w.done
+}
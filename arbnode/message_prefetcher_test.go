@@ -0,0 +1,189 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+
+	"github.com/offchainlabs/nitro/arbos"
+	"github.com/offchainlabs/nitro/arbos/l2pricing"
+	"github.com/offchainlabs/nitro/arbos/util"
+	"github.com/offchainlabs/nitro/arbstate"
+	"github.com/offchainlabs/nitro/arbutil"
+)
+
+var sequentialBlockCreationConfig = TransactionStreamerConfig{
+	MaxBroadcastQueueSize: DefaultTransactionStreamerConfig.MaxBroadcastQueueSize,
+	PrefetchMessages:      1,
+	ParseWorkers:          1,
+	SenderRecoveryWorkers: 1,
+	WarmStateMessages:     0,
+}
+
+var warmStateBlockCreationConfig = TransactionStreamerConfig{
+	MaxBroadcastQueueSize: DefaultTransactionStreamerConfig.MaxBroadcastQueueSize,
+	PrefetchMessages:      DefaultTransactionStreamerConfig.PrefetchMessages,
+	ParseWorkers:          0,
+	SenderRecoveryWorkers: 0,
+	WarmStateMessages:     16,
+}
+
+// requireNoError is Require for the helpers shared with the benchmarks
+func requireNoError(tb testing.TB, err error) {
+	tb.Helper()
+	if err != nil {
+		tb.Fatal(err)
+	}
+}
+
+// newStreamerWithSignedTxs creates a streamer with messageCount messages after the init message,
+// each a batch of txsPerMessage transfers signed by the owner, without creating their blocks
+func newStreamerWithSignedTxs(tb testing.TB, messageCount int, txsPerMessage int) *TransactionStreamer {
+	ownerKey, err := crypto.GenerateKey()
+	requireNoError(tb, err)
+	streamer, _, _, err := newTransactionStreamerForTest(crypto.PubkeyToAddress(ownerKey.PublicKey))
+	requireNoError(tb, err)
+	requireNoError(tb, streamer.AddMessages(1, false, signedTxMessages(tb, ownerKey, messageCount, txsPerMessage)))
+	return streamer
+}
+
+func signedTxMessages(tb testing.TB, key *ecdsa.PrivateKey, messageCount int, txsPerMessage int) []arbstate.MessageWithMetadata {
+	chainConfig := params.ArbitrumDevTestChainConfig()
+	signer := types.LatestSignerForChainID(chainConfig.ChainID)
+	var messages []arbstate.MessageWithMetadata
+	var nonce uint64
+	for i := 0; i < messageCount; i++ {
+		l2Message := []byte{arbos.L2MessageKind_Batch}
+		buf := bytes.NewBuffer(nil)
+		for j := 0; j < txsPerMessage; j++ {
+			dest := common.BigToAddress(big.NewInt(int64(nonce + 1)))
+			tx, err := types.SignNewTx(key, signer, &types.DynamicFeeTx{
+				ChainID:   chainConfig.ChainID,
+				Nonce:     nonce,
+				GasTipCap: common.Big0,
+				GasFeeCap: big.NewInt(l2pricing.InitialBaseFeeWei * 2),
+				Gas:       1_000_000,
+				To:        &dest,
+				Value:     common.Big1,
+			})
+			requireNoError(tb, err)
+			nonce++
+			txBytes, err := tx.MarshalBinary()
+			requireNoError(tb, err)
+			requireNoError(tb, util.BytestringToWriter(append([]byte{arbos.L2MessageKind_SignedTx}, txBytes...), buf))
+		}
+		l2Message = append(l2Message, buf.Bytes()...)
+		messages = append(messages, arbstate.MessageWithMetadata{
+			Message: &arbos.L1IncomingMessage{
+				Header: &arbos.L1IncomingMessageHeader{
+					Kind:   arbos.L1MessageType_L2Message,
+					Poster: common.Address{},
+				},
+				L2msg: l2Message,
+			},
+			DelayedMessagesRead: 1,
+		})
+	}
+	return messages
+}
+
+func createBlocksWithConfig(tb testing.TB, streamer *TransactionStreamer, config TransactionStreamerConfig) {
+	streamer.configFetcher = func() *TransactionStreamerConfig { return &config }
+	requireNoError(tb, streamer.createBlocks(context.Background()))
+}
+
+func TestCreateBlocksPipelined(t *testing.T) {
+	messageCount := 20
+	txsPerMessage := 5
+	ownerKey, err := crypto.GenerateKey()
+	Require(t, err)
+	messages := signedTxMessages(t, ownerKey, messageCount, txsPerMessage)
+
+	configs := []TransactionStreamerConfig{sequentialBlockCreationConfig, DefaultTransactionStreamerConfig, warmStateBlockCreationConfig}
+	var expected []common.Hash
+	for _, config := range configs {
+		streamer, _, bc := NewTransactionStreamerForTest(t, crypto.PubkeyToAddress(ownerKey.PublicKey))
+		Require(t, streamer.AddMessages(1, false, messages))
+		createBlocksWithConfig(t, streamer, config)
+
+		if bc.CurrentBlock().NumberU64() != uint64(messageCount) {
+			Fail(t, "unexpected block number", bc.CurrentBlock().NumberU64(), "with config", config)
+		}
+		var hashes []common.Hash
+		for i := 1; i <= messageCount; i++ {
+			block := bc.GetBlockByNumber(uint64(i))
+			if len(block.Transactions()) != txsPerMessage+1 {
+				Fail(t, "block", i, "has unexpected transaction count", len(block.Transactions()), "with config", config)
+			}
+			hashes = append(hashes, block.Hash())
+		}
+		if expected == nil {
+			expected = hashes
+			continue
+		}
+		for i := range hashes {
+			if hashes[i] != expected[i] {
+				Fail(t, "block", i+1, "hash", hashes[i], "differs from the sequentially created", expected[i], "with config", config)
+			}
+		}
+	}
+}
+
+// Every message's transactions are from the same sender, so speculatively executing them before the message
+// ahead of them is created would fail on their nonces. Loading their read-sets doesn't depend on those messages.
+func TestWarmStateNonceChain(t *testing.T) {
+	messageCount := 10
+	txsPerMessage := 5
+	streamer := newStreamerWithSignedTxs(t, messageCount, txsPerMessage)
+	streamer.configFetcher = func() *TransactionStreamerConfig { return &warmStateBlockCreationConfig }
+	prefetcher := newMessagePrefetcher(context.Background(), streamer, nil, 1, arbutil.MessageIndex(messageCount+1))
+	defer prefetcher.StopAndWait()
+
+	_, err := prefetcher.Next()
+	Require(t, err)
+	statedb, err := streamer.bc.StateAt(streamer.bc.CurrentBlock().Root())
+	Require(t, err)
+	upcoming := prefetcher.Upcoming(messageCount)
+	warmer := warmState(statedb, upcoming)
+	<-warmer.done
+	if warmer.warmedTxs != len(upcoming)*txsPerMessage {
+		Fail(t, "warmed", warmer.warmedTxs, "transactions of", len(upcoming), "messages with", txsPerMessage, "each")
+	}
+}
+
+func benchmarkCreateBlocks(b *testing.B, config TransactionStreamerConfig) {
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		streamer := newStreamerWithSignedTxs(b, 50, 50)
+		b.StartTimer()
+		createBlocksWithConfig(b, streamer, config)
+	}
+}
+
+func BenchmarkCreateBlocksSequential(b *testing.B) {
+	benchmarkCreateBlocks(b, sequentialBlockCreationConfig)
+}
+
+func BenchmarkCreateBlocksPipelined(b *testing.B) {
+	benchmarkCreateBlocks(b, DefaultTransactionStreamerConfig)
+}
+
+// BenchmarkCreateBlocksWarmState compares pipelined block creation with and without warming the state
+func BenchmarkCreateBlocksWarmState(b *testing.B) {
+	b.Run("cold", func(b *testing.B) {
+		benchmarkCreateBlocks(b, DefaultTransactionStreamerConfig)
+	})
+	b.Run("warm", func(b *testing.B) {
+		benchmarkCreateBlocks(b, warmStateBlockCreationConfig)
+	})
+}
@@ -0,0 +1,357 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/pkg/errors"
+	flag "github.com/spf13/pflag"
+
+	"github.com/offchainlabs/nitro/arbutil"
+	"github.com/offchainlabs/nitro/util/arbmath"
+	"github.com/offchainlabs/nitro/util/stopwaiter"
+	"github.com/offchainlabs/nitro/validator"
+)
+
+var messagePrunerBatchCountGauge = metrics.NewRegisteredGauge("arb/messagepruner/batchcount", nil)
+
+// ErrPruned is wrapped by the errors returned when reading entries the message pruner deleted
+var ErrPruned = errors.New("pruned from the database")
+
+type MessagePrunerConfig struct {
+	Enable           bool          `koanf:"enable"`
+	PruneInterval    time.Duration `koanf:"prune-interval"`
+	RetentionBatches uint64        `koanf:"retention-batches"`
+}
+
+type MessagePrunerConfigFetcher func() *MessagePrunerConfig
+
+var DefaultMessagePrunerConfig = MessagePrunerConfig{
+	Enable:           false,
+	PruneInterval:    time.Hour,
+	RetentionBatches: 10000,
+}
+
+var TestMessagePrunerConfig = MessagePrunerConfig{
+	Enable:           true,
+	PruneInterval:    10 * time.Millisecond,
+	RetentionBatches: 10,
+}
+
+func MessagePrunerConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", DefaultMessagePrunerConfig.Enable, "delete the batch metadata, messages, and delayed messages of batches that are confirmed on L1, validated, and older than the retention window")
+	f.Duration(prefix+".prune-interval", DefaultMessagePrunerConfig.PruneInterval, "how often to prune")
+	f.Uint64(prefix+".retention-batches", DefaultMessagePrunerConfig.RetentionBatches, "number of the latest batches to keep, along with the messages and delayed messages they read")
+}
+
+// InboxPruneState records how far the inbox has been pruned.
+// Everything read by the batches before BatchCount was deleted, except the init message and delayed message,
+// and the last batch metadata, message, and delayed message, whose accumulators and positions the inbox continues from.
+type InboxPruneState struct {
+	BatchCount   uint64
+	MessageCount arbutil.MessageIndex
+	DelayedCount uint64
+}
+
+func readInboxPruneState(db ethdb.KeyValueReader) (InboxPruneState, error) {
+	var state InboxPruneState
+	hasKey, err := db.Has(inboxPruneStateKey)
+	if err != nil || !hasKey {
+		return state, err
+	}
+	data, err := db.Get(inboxPruneStateKey)
+	if err != nil {
+		return state, err
+	}
+	err = rlp.DecodeBytes(data, &state)
+	return state, err
+}
+
+// wasPruned returns whether the entry at index was deleted when pruning the entries before count
+func wasPruned(index uint64, count uint64) bool {
+	return index > 0 && index+1 < count
+}
+
+// prunedRange returns the range of entries deleted when pruning up to count, after previously pruning up to prevCount.
+// The init entry, and the entry before count, are kept.
+func prunedRange(prevCount uint64, count uint64) (uint64, uint64) {
+	from := uint64(1)
+	if prevCount > 2 {
+		from = prevCount - 1
+	}
+	if count < 1 {
+		return from, from
+	}
+	return from, count - 1
+}
+
+func deleteRange(db ethdb.Database, prefix []byte, from uint64, to uint64) error {
+	if from >= to {
+		return nil
+	}
+	endKey := dbKey(prefix, to)
+	batch := db.NewBatch()
+	iter := db.NewIterator(prefix, uint64ToKey(from))
+	defer iter.Release()
+	for iter.Next() {
+		if bytes.Compare(iter.Key(), endKey) >= 0 {
+			break
+		}
+		err := batch.Delete(iter.Key())
+		if err != nil {
+			return err
+		}
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			err = batch.Write()
+			if err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	err := iter.Error()
+	if err != nil {
+		return err
+	}
+	return batch.Write()
+}
+
+// PruneInbox deletes what the batches before batchCount read from the inbox, keeping the entries the inbox continues from.
+// The caller must make sure those batches can't be reorged.
+func PruneInbox(db ethdb.Database, batchCount uint64) (InboxPruneState, error) {
+	prev, err := readInboxPruneState(db)
+	if err != nil {
+		return InboxPruneState{}, err
+	}
+	if batchCount <= prev.BatchCount {
+		return prev, nil
+	}
+	inbox := &InboxTracker{db: db} // only used to read the database
+	currentBatchCount, err := inbox.GetBatchCount()
+	if err != nil {
+		return prev, err
+	}
+	if batchCount > currentBatchCount {
+		return prev, fmt.Errorf("cannot prune to batch count %v past the inbox's batch count %v", batchCount, currentBatchCount)
+	}
+	meta, err := inbox.GetBatchMetadata(batchCount - 1)
+	if err != nil {
+		return prev, err
+	}
+	state := InboxPruneState{
+		BatchCount:   batchCount,
+		MessageCount: meta.MessageCount,
+		DelayedCount: meta.DelayedMessageCount,
+	}
+	// Record the new state first, so reading an entry while it's deleted reports it as pruned
+	data, err := rlp.EncodeToBytes(state)
+	if err != nil {
+		return prev, err
+	}
+	err = db.Put(inboxPruneStateKey, data)
+	if err != nil {
+		return prev, err
+	}
+
+	ranges := []struct {
+		prefix    []byte
+		prevCount uint64
+		count     uint64
+	}{
+		{sequencerBatchMetaPrefix, prev.BatchCount, state.BatchCount},
+		{messagePrefix, uint64(prev.MessageCount), uint64(state.MessageCount)},
+		{rlpDelayedMessagePrefix, prev.DelayedCount, state.DelayedCount},
+		{legacyDelayedMessagePrefix, prev.DelayedCount, state.DelayedCount},
+	}
+	for _, r := range ranges {
+		from, to := prunedRange(r.prevCount, r.count)
+		err = deleteRange(db, r.prefix, from, to)
+		if err != nil {
+			return state, err
+		}
+	}
+	// these are only needed to reorg the delayed messages read by unpruned batches
+	err = deleteRange(db, delayedSequencedPrefix, prev.DelayedCount, state.DelayedCount)
+	if err != nil {
+		return state, err
+	}
+	log.Info("pruned inbox", "batchCount", state.BatchCount, "messageCount", state.MessageCount, "delayedCount", state.DelayedCount)
+	return state, nil
+}
+
+// HeadMessageCountFetcher returns the number of messages the chain's head block was built from
+type HeadMessageCountFetcher func() (arbutil.MessageIndex, error)
+
+// ReadHeadMessageCount reads the number of messages the head block of a stopped node's chain database was built from
+func ReadHeadMessageCount(chainDb ethdb.Database) (arbutil.MessageIndex, error) {
+	chainConfig := TryReadStoredChainConfig(chainDb)
+	if chainConfig == nil {
+		return 0, errors.New("no chain config found in the chain database")
+	}
+	headNumber := rawdb.ReadHeaderNumber(chainDb, rawdb.ReadHeadHeaderHash(chainDb))
+	if headNumber == nil {
+		return 0, errors.New("no head block found in the chain database")
+	}
+	return arbutil.BlockNumberToMessageCount(*headNumber, chainConfig.ArbitrumChainParams.GenesisBlockNum), nil
+}
+
+// MessagePruner periodically prunes the inbox up to the latest batch that's safe to prune
+type MessagePruner struct {
+	stopwaiter.StopWaiter
+	db               ethdb.Database
+	validatorDb      ethdb.KeyValueReader
+	rollup           *validator.RollupWatcher
+	batchCosts       *BatchCostTracker
+	headMessageCount HeadMessageCountFetcher
+	config           MessagePrunerConfigFetcher
+}
+
+// NewMessagePruner creates a pruner which respects the messages the chain's head block has been built from,
+// the last block validated according to validatorDb, if it isn't nil,
+// and the batch costs not yet recorded by batchCosts, if it isn't nil.
+func NewMessagePruner(db ethdb.Database, validatorDb ethdb.KeyValueReader, rollup *validator.RollupWatcher, batchCosts *BatchCostTracker, headMessageCount HeadMessageCountFetcher, config MessagePrunerConfigFetcher) *MessagePruner {
+	return &MessagePruner{
+		db:               db,
+		validatorDb:      validatorDb,
+		rollup:           rollup,
+		batchCosts:       batchCosts,
+		headMessageCount: headMessageCount,
+		config:           config,
+	}
+}
+
+// NewOfflineMessagePruner creates a pruner for a stopped node's database, and the chain database it built blocks into.
+// It respects the last block validated recorded in the database, if there is one.
+func NewOfflineMessagePruner(db ethdb.Database, chainDb ethdb.Database, rollup *validator.RollupWatcher, config MessagePrunerConfigFetcher) (*MessagePruner, error) {
+	var validatorDb ethdb.KeyValueReader = rawdb.NewTable(db, blockValidatorPrefix)
+	_, found, err := validator.ReadLastValidatedPosition(validatorDb)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		validatorDb = nil
+	}
+	headMessageCount := func() (arbutil.MessageIndex, error) {
+		return ReadHeadMessageCount(chainDb)
+	}
+	return NewMessagePruner(db, validatorDb, rollup, nil, headMessageCount, config), nil
+}
+
+// builtBatchCount is the number of batches, up to batchCount, whose messages the chain's head block has been built from
+func (p *MessagePruner) builtBatchCount(inbox *InboxTracker, batchCount uint64) (uint64, error) {
+	headMessageCount, err := p.headMessageCount()
+	if err != nil {
+		return 0, err
+	}
+	pruned, err := readInboxPruneState(p.db)
+	if err != nil {
+		return 0, err
+	}
+	// search for the first batch with a message the head hasn't been built from, skipping the pruned batches
+	low, high := pruned.BatchCount, batchCount
+	for low < high {
+		mid := low + (high-low)/2
+		meta, err := inbox.GetBatchMetadata(mid)
+		if err != nil {
+			return 0, err
+		}
+		if meta.MessageCount > headMessageCount {
+			high = mid
+		} else {
+			low = mid + 1
+		}
+	}
+	return low, nil
+}
+
+// locallySafeBatchCount is the number of batches that can be pruned according to this node's databases.
+// They must be before the retention window, built into blocks, validated, and have their costs recorded.
+func (p *MessagePruner) locallySafeBatchCount() (uint64, error) {
+	inbox := &InboxTracker{db: p.db} // only used to read the database
+	batchCount, err := inbox.GetBatchCount()
+	if err != nil {
+		return 0, err
+	}
+	retention := p.config().RetentionBatches
+	if batchCount <= retention {
+		return 0, nil
+	}
+	safe := batchCount - retention
+
+	// a node that's behind would otherwise delete messages it still has to build blocks from
+	built, err := p.builtBatchCount(inbox, safe)
+	if err != nil {
+		return 0, err
+	}
+	safe = arbmath.MinUint(safe, built)
+
+	if p.validatorDb != nil {
+		validated, found, err := validator.ReadLastValidatedPosition(p.validatorDb)
+		if err != nil || !found {
+			return 0, err
+		}
+		safe = arbmath.MinUint(safe, validated.BatchNumber)
+	}
+
+	if p.batchCosts != nil {
+		recorded, err := p.batchCosts.RecordedBatchCount()
+		if err != nil {
+			return 0, err
+		}
+		safe = arbmath.MinUint(safe, recorded)
+	}
+	return safe, nil
+}
+
+// SafeBatchCount is the number of batches that can be pruned.
+// They must be locally safe to prune, and read by the latest confirmed rollup node.
+func (p *MessagePruner) SafeBatchCount(ctx context.Context) (uint64, error) {
+	safe, err := p.locallySafeBatchCount()
+	if err != nil || safe == 0 {
+		return 0, err
+	}
+
+	confirmedNum, err := p.rollup.LatestConfirmed(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		return 0, err
+	}
+	confirmed, err := p.rollup.LookupNode(ctx, confirmedNum)
+	if err != nil {
+		return 0, err
+	}
+	return arbmath.MinUint(safe, confirmed.AfterState().GlobalState.Batch), nil
+}
+
+func (p *MessagePruner) Prune(ctx context.Context) (InboxPruneState, error) {
+	safe, err := p.SafeBatchCount(ctx)
+	if err != nil {
+		return InboxPruneState{}, err
+	}
+	return PruneInbox(p.db, safe)
+}
+
+func (p *MessagePruner) Start(ctxIn context.Context) {
+	p.StopWaiter.Start(ctxIn, p)
+	p.CallIteratively(func(ctx context.Context) time.Duration {
+		state, err := p.Prune(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Warn("error pruning inbox", "err", err)
+			}
+		} else {
+			messagePrunerBatchCountGauge.Update(int64(state.BatchCount))
+		}
+		return p.config().PruneInterval
+	})
+}
@@ -0,0 +1,164 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/offchainlabs/nitro/arbos"
+	"github.com/offchainlabs/nitro/arbutil"
+	"github.com/offchainlabs/nitro/validator"
+)
+
+// addDelayedBatches adds batches up to batchCount, each reading one more delayed message, which becomes one message
+func addDelayedBatches(t *testing.T, tracker *InboxTracker, prevDelayed *DelayedInboxMessage, batchCount uint64) *DelayedInboxMessage {
+	ctx := context.Background()
+	firstBatch, err := tracker.GetBatchCount()
+	Require(t, err)
+	var delayed []*DelayedInboxMessage
+	var batches []*SequencerInboxBatch
+	for i := firstBatch; i < batchCount; i++ {
+		requestId := common.BigToHash(new(big.Int).SetUint64(i))
+		prevDelayed = &DelayedInboxMessage{
+			BeforeInboxAcc: prevDelayed.AfterInboxAcc(),
+			Message: &arbos.L1IncomingMessage{
+				Header: &arbos.L1IncomingMessageHeader{
+					Kind:      arbos.L1MessageType_EndOfBlock,
+					RequestId: &requestId,
+					L1BaseFee: common.Big0,
+				},
+			},
+		}
+		delayed = append(delayed, prevDelayed)
+		serialized := make([]byte, 40)
+		binary.BigEndian.PutUint64(serialized[32:], i+1)
+		batches = append(batches, &SequencerInboxBatch{
+			BlockNumber:       i,
+			SequenceNumber:    i,
+			BeforeInboxAcc:    common.Hash{byte(i)},
+			AfterInboxAcc:     common.Hash{byte(i + 1)},
+			AfterDelayedAcc:   prevDelayed.AfterInboxAcc(),
+			AfterDelayedCount: i + 1,
+			serialized:        serialized,
+		})
+	}
+	Require(t, tracker.AddDelayedMessages(delayed, false))
+	Require(t, tracker.AddSequencerBatches(ctx, nil, batches))
+	return prevDelayed
+}
+
+func TestPruneInbox(t *testing.T) {
+	streamer, tracker := newInboxForArchiveTest(t, false)
+	initDelayed, err := tracker.GetDelayedMessage(0)
+	Require(t, err)
+	lastDelayed := addDelayedBatches(t, tracker, &DelayedInboxMessage{Message: initDelayed}, 6)
+
+	_, err = PruneInbox(tracker.db, 7)
+	if err == nil {
+		Fail(t, "pruned past the batch count")
+	}
+	state, err := PruneInbox(tracker.db, 4)
+	Require(t, err)
+	if state.BatchCount != 4 || state.MessageCount != 4 || state.DelayedCount != 4 {
+		Fail(t, "unexpected prune state", state)
+	}
+
+	_, err = tracker.GetBatchMetadata(2)
+	if !errors.Is(err, ErrPruned) {
+		Fail(t, "unexpected error reading pruned batch metadata", err)
+	}
+	_, err = streamer.GetMessage(1)
+	if !errors.Is(err, ErrPruned) {
+		Fail(t, "unexpected error reading pruned message", err)
+	}
+	_, err = tracker.GetDelayedMessage(2)
+	if !errors.Is(err, ErrPruned) {
+		Fail(t, "unexpected error reading pruned delayed message", err)
+	}
+	// the init entries, and the entries the inbox continues from, are kept
+	for _, index := range []uint64{0, 3} {
+		_, err = tracker.GetBatchMetadata(index)
+		Require(t, err)
+		_, err = tracker.GetDelayedAcc(index)
+		Require(t, err)
+	}
+	_, err = streamer.GetMessage(0)
+	Require(t, err)
+	_, err = streamer.GetMessage(3)
+	Require(t, err)
+
+	_, err = validator.FindBatchContainingMessageIndex(tracker, 2, 6)
+	if err == nil {
+		Fail(t, "found the batch containing a pruned message")
+	}
+	batch, err := validator.FindBatchContainingMessageIndex(tracker, 4, 6)
+	Require(t, err)
+	if batch != 4 {
+		Fail(t, "found message 4 in batch", batch)
+	}
+
+	// pruning backwards does nothing
+	state, err = PruneInbox(tracker.db, 2)
+	Require(t, err)
+	if state.BatchCount != 4 {
+		Fail(t, "unexpected prune state", state)
+	}
+
+	// the inbox keeps growing, and pruning continues from where it left off
+	addDelayedBatches(t, tracker, lastDelayed, 8)
+	_, err = PruneInbox(tracker.db, 7)
+	Require(t, err)
+	_, err = tracker.GetBatchMetadata(3)
+	if !errors.Is(err, ErrPruned) {
+		Fail(t, "unexpected error reading pruned batch metadata", err)
+	}
+	_, err = tracker.GetBatchMetadata(6)
+	Require(t, err)
+	msg, err := streamer.GetMessage(7)
+	Require(t, err)
+	if msg.DelayedMessagesRead != 8 {
+		Fail(t, "unexpected delayed messages read", msg.DelayedMessagesRead)
+	}
+}
+
+func TestPruneOnlyBuiltBatches(t *testing.T) {
+	_, tracker := newInboxForArchiveTest(t, false)
+	initDelayed, err := tracker.GetDelayedMessage(0)
+	Require(t, err)
+	addDelayedBatches(t, tracker, &DelayedInboxMessage{Message: initDelayed}, 6)
+
+	config := TestMessagePrunerConfig
+	config.RetentionBatches = 0
+	var headMessageCount arbutil.MessageIndex
+	pruner := NewMessagePruner(tracker.db, nil, nil, nil, func() (arbutil.MessageIndex, error) {
+		return headMessageCount, nil
+	}, func() *MessagePrunerConfig { return &config })
+
+	expectSafe := func(head arbutil.MessageIndex, expected uint64) {
+		t.Helper()
+		headMessageCount = head
+		safe, err := pruner.locallySafeBatchCount()
+		Require(t, err)
+		if safe != expected {
+			Fail(t, "with head message count", head, "expected", expected, "batches safe to prune, but got", safe)
+		}
+	}
+	// batch i reads message i, so a head that's behind keeps the batch with its next message
+	expectSafe(1, 1)
+	expectSafe(3, 3)
+	expectSafe(4, 4)
+	expectSafe(6, 6)
+
+	_, err = PruneInbox(tracker.db, 3)
+	Require(t, err)
+	// the search skips the pruned batches
+	expectSafe(3, 3)
+	expectSafe(5, 5)
+}
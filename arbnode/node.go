@@ -13,6 +13,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"time"
 
 	flag "github.com/spf13/pflag"
@@ -32,6 +33,7 @@ import (
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rpc"
 
+	"github.com/offchainlabs/nitro/arbnode/dataposter"
 	"github.com/offchainlabs/nitro/arbos"
 	"github.com/offchainlabs/nitro/arbos/arbosState"
 	"github.com/offchainlabs/nitro/arbstate"
@@ -402,14 +404,17 @@ type Config struct {
 	InboxReader            InboxReaderConfig              `koanf:"inbox-reader" reload:"hot"`
 	DelayedSequencer       DelayedSequencerConfig         `koanf:"delayed-sequencer" reload:"hot"`
 	BatchPoster            BatchPosterConfig              `koanf:"batch-poster" reload:"hot"`
+	BatchCosts             BatchCostTrackerConfig         `koanf:"batch-costs"`
 	ForwardingTargetImpl   string                         `koanf:"forwarding-target"`
 	Forwarder              ForwarderConfig                `koanf:"forwarder"`
 	TxPreCheckerStrictness uint                           `koanf:"tx-pre-checker-strictness" reload:"hot"`
+	TxSync                 TxSyncConfig                   `koanf:"tx-sync"`
 	BlockValidator         validator.BlockValidatorConfig `koanf:"block-validator" reload:"hot"`
 	Feed                   broadcastclient.FeedConfig     `koanf:"feed" reload:"hot"`
 	Validator              validator.L1ValidatorConfig    `koanf:"validator"`
 	SeqCoordinator         SeqCoordinatorConfig           `koanf:"seq-coordinator"`
 	DataAvailability       das.DataAvailabilityConfig     `koanf:"data-availability"`
+	ExternalDA             das.ExternalDAConfig           `koanf:"external-da"`
 	Wasm                   WasmConfig                     `koanf:"wasm"`
 	SyncMonitor            SyncMonitorConfig              `koanf:"sync-monitor"`
 	Dangerous              DangerousConfig                `koanf:"dangerous"`
@@ -417,6 +422,8 @@ type Config struct {
 	Archive                bool                           `koanf:"archive"`
 	TxLookupLimit          uint64                         `koanf:"tx-lookup-limit"`
 	TransactionStreamer    TransactionStreamerConfig      `koanf:"transaction-streamer"`
+	MessageArchive         MessageArchiveConfig           `koanf:"message-archive"`
+	MessagePruner          MessagePrunerConfig            `koanf:"message-pruner" reload:"hot"`
 }
 
 func (c *Config) Validate() error {
@@ -432,6 +439,9 @@ func (c *Config) Validate() error {
 	if err := c.BatchPoster.Validate(); err != nil {
 		return err
 	}
+	if err := c.TxSync.Validate(); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -455,6 +465,18 @@ func (c *Config) ForwardingTarget() string {
 	return c.ForwardingTargetImpl
 }
 
+// ForwardingTargets returns the comma separated forwarding target URLs, in order of preference
+func (c *Config) ForwardingTargets() []string {
+	var targets []string
+	for _, target := range strings.Split(c.ForwardingTarget(), ",") {
+		target = strings.TrimSpace(target)
+		if target != "" {
+			targets = append(targets, target)
+		}
+	}
+	return targets
+}
+
 func ConfigAddOptions(prefix string, f *flag.FlagSet, feedInputEnable bool, feedOutputEnable bool) {
 	arbitrum.ConfigAddOptions(prefix+".rpc", f)
 	SequencerConfigAddOptions(prefix+".sequencer", f)
@@ -462,23 +484,28 @@ func ConfigAddOptions(prefix string, f *flag.FlagSet, feedInputEnable bool, feed
 	InboxReaderConfigAddOptions(prefix+".inbox-reader", f)
 	DelayedSequencerConfigAddOptions(prefix+".delayed-sequencer", f)
 	BatchPosterConfigAddOptions(prefix+".batch-poster", f)
-	f.String(prefix+".forwarding-target", ConfigDefault.ForwardingTargetImpl, "transaction forwarding target URL, or \"null\" to disable forwarding (iff not sequencer)")
+	BatchCostTrackerConfigAddOptions(prefix+".batch-costs", f)
+	f.String(prefix+".forwarding-target", ConfigDefault.ForwardingTargetImpl, "transaction forwarding target URL, or a comma separated list of URLs to fail over between in order, or \"null\" to disable forwarding (iff not sequencer)")
 	AddOptionsForNodeForwarderConfig(prefix+".forwarder", f)
 	txPreCheckerDescription := "how strict to be when checking txs before forwarding them. 0 = accept anything, " +
 		"10 = should never reject anything that'd succeed, 20 = likely won't reject anything that'd succeed, " +
 		"30 = full validation which may reject txs that would succeed"
 	f.Uint(prefix+".tx-pre-checker-strictness", ConfigDefault.TxPreCheckerStrictness, txPreCheckerDescription)
+	TxSyncConfigAddOptions(prefix+".tx-sync", f)
 	validator.BlockValidatorConfigAddOptions(prefix+".block-validator", f)
 	broadcastclient.FeedConfigAddOptions(prefix+".feed", f, feedInputEnable, feedOutputEnable)
 	validator.L1ValidatorConfigAddOptions(prefix+".validator", f)
 	SeqCoordinatorConfigAddOptions(prefix+".seq-coordinator", f)
 	das.DataAvailabilityConfigAddOptions(prefix+".data-availability", f)
+	das.ExternalDAConfigAddOptions(prefix+".external-da", f)
 	WasmConfigAddOptions(prefix+".wasm", f)
 	SyncMonitorConfigAddOptions(prefix+".sync-monitor", f)
 	DangerousConfigAddOptions(prefix+".dangerous", f)
 	CachingConfigAddOptions(prefix+".caching", f)
 	f.Uint64(prefix+".tx-lookup-limit", ConfigDefault.TxLookupLimit, "retain the ability to lookup transactions by hash for the past N blocks (0 = all blocks)")
 	TransactionStreamerConfigAddOptions(prefix+".transaction-streamer", f)
+	MessageArchiveConfigAddOptions(prefix+".message-archive", f)
+	MessagePrunerConfigAddOptions(prefix+".message-pruner", f)
 
 	archiveMsg := fmt.Sprintf("retain past block state (deprecated, please use %v.caching.archive)", prefix)
 	f.Bool(prefix+".archive", ConfigDefault.Archive, archiveMsg)
@@ -491,13 +518,16 @@ var ConfigDefault = Config{
 	InboxReader:            DefaultInboxReaderConfig,
 	DelayedSequencer:       DefaultDelayedSequencerConfig,
 	BatchPoster:            DefaultBatchPosterConfig,
+	BatchCosts:             DefaultBatchCostTrackerConfig,
 	ForwardingTargetImpl:   "",
 	TxPreCheckerStrictness: TxPreCheckerStrictnessNone,
+	TxSync:                 DefaultTxSyncConfig,
 	BlockValidator:         validator.DefaultBlockValidatorConfig,
 	Feed:                   broadcastclient.FeedConfigDefault,
 	Validator:              validator.DefaultL1ValidatorConfig,
 	SeqCoordinator:         DefaultSeqCoordinatorConfig,
 	DataAvailability:       das.DefaultDataAvailabilityConfig,
+	ExternalDA:             das.DefaultExternalDAConfig,
 	Wasm:                   DefaultWasmConfig,
 	SyncMonitor:            DefaultSyncMonitorConfig,
 	Dangerous:              DefaultDangerousConfig,
@@ -505,6 +535,8 @@ var ConfigDefault = Config{
 	TxLookupLimit:          40_000_000,
 	Caching:                DefaultCachingConfig,
 	TransactionStreamer:    DefaultTransactionStreamerConfig,
+	MessageArchive:         DefaultMessageArchiveConfig,
+	MessagePruner:          DefaultMessagePrunerConfig,
 }
 
 func ConfigDefaultL1Test() *Config {
@@ -662,6 +694,7 @@ type Node struct {
 	L1Reader                *headerreader.HeaderReader
 	TxStreamer              *TransactionStreamer
 	TxPublisher             TransactionPublisher
+	Sequencer               *Sequencer
 	DeployInfo              *RollupAddresses
 	InboxReader             *InboxReader
 	InboxTracker            *InboxTracker
@@ -676,6 +709,8 @@ type Node struct {
 	DASLifecycleManager     *das.LifecycleManager
 	ClassicOutboxRetriever  *ClassicOutboxRetriever
 	SyncMonitor             *SyncMonitor
+	BatchCostTracker        *BatchCostTracker
+	MessagePruner           *MessagePruner
 	configFetcher           ConfigFetcher
 	ctx                     context.Context
 }
@@ -841,7 +876,7 @@ func createNodeImpl(
 		if config.ForwardingTarget() == "" {
 			txPublisher = NewTxDropper()
 		} else {
-			txPublisher = NewForwarder(config.ForwardingTarget(), &config.Forwarder)
+			txPublisher = NewForwarder(config.ForwardingTargets(), &config.Forwarder)
 		}
 	}
 	if config.SeqCoordinator.Enable {
@@ -850,7 +885,17 @@ func createNodeImpl(
 			return nil, err
 		}
 	}
-	txPublisher = NewTxPreChecker(txPublisher, l2BlockChain, func() uint { return configFetcher.Get().TxPreCheckerStrictness })
+	txPublisher = NewTxPreChecker(
+		txPublisher,
+		l2BlockChain,
+		func() uint { return configFetcher.Get().TxPreCheckerStrictness },
+		func() bool { return configFetcher.Get().Sequencer.NonceHoldingPool.Enable },
+	)
+	if config.Sequencer.Enable && config.Sequencer.RateLimit.Enable {
+		txPublisher = NewTxRateLimiter(txPublisher, l2BlockChain, &config.Sequencer.RateLimit)
+	} else if !config.Sequencer.Enable && config.ForwardingTarget() != "" && config.Forwarder.RateLimit.Enable {
+		txPublisher = NewTxRateLimiter(txPublisher, l2BlockChain, &config.Forwarder.RateLimit)
+	}
 	arbInterface, err := NewArbInterface(txStreamer, txPublisher)
 	if err != nil {
 		return nil, err
@@ -890,6 +935,7 @@ func createNodeImpl(
 			nil,
 			txStreamer,
 			txPublisher,
+			sequencer,
 			nil,
 			nil,
 			nil,
@@ -904,6 +950,8 @@ func createNodeImpl(
 			nil,
 			classicOutbox,
 			syncMonitor,
+			nil,
+			nil,
 			configFetcher,
 			ctx,
 		}, nil
@@ -949,7 +997,18 @@ func createNodeImpl(
 		return nil, errors.New("a data availability service is required for this chain, but it was not configured")
 	}
 
-	inboxTracker, err := NewInboxTracker(arbDb, txStreamer, daReader)
+	var externalDA das.ExternalDA
+	var externalDAReaders arbstate.ExternalDAReaders
+	if config.ExternalDA.Mode != "" {
+		log.Warn("using an experimental external data availability layer, which can't be proven", "mode", config.ExternalDA.Mode, "headerByte", config.ExternalDA.HeaderByte)
+		externalDA, err = das.NewExternalDA(&config.ExternalDA)
+		if err != nil {
+			return nil, err
+		}
+		externalDAReaders = arbstate.ExternalDAReaders{config.ExternalDA.HeaderByte: externalDA}
+	}
+
+	inboxTracker, err := NewInboxTracker(arbDb, txStreamer, daReader, externalDAReaders)
 	if err != nil {
 		return nil, err
 	}
@@ -959,6 +1018,11 @@ func createNodeImpl(
 	}
 	txStreamer.SetInboxReader(inboxReader)
 
+	var batchCostTracker *BatchCostTracker
+	if config.BatchCosts.Enable {
+		batchCostTracker = NewBatchCostTracker(arbDb, l1client, sequencerInbox, inboxTracker, txStreamer, func() *BatchCostTrackerConfig { return &configFetcher.Get().BatchCosts })
+	}
+
 	blockValidatorConf := &config.BlockValidator
 	if blockValidatorConf.Enable && !(blockValidatorConf.ArbitratorValidator || blockValidatorConf.JitValidator) {
 		log.Warn("No block-by-block validator configured. Enabling the JIT block validator")
@@ -1011,8 +1075,28 @@ func createNodeImpl(
 		}
 	}
 
+	var messagePruner *MessagePruner
+	if config.MessagePruner.Enable {
+		rollup, err := validator.NewRollupWatcher(deployInfo.Rollup, l1client, bind.CallOpts{})
+		if err != nil {
+			return nil, err
+		}
+		var validatorDb ethdb.KeyValueReader
+		if blockValidator != nil {
+			validatorDb = rawdb.NewTable(arbDb, blockValidatorPrefix)
+		}
+		messagePruner = NewMessagePruner(arbDb, validatorDb, rollup, batchCostTracker, txStreamer.GetHeadMessageCount, func() *MessagePrunerConfig { return &configFetcher.Get().MessagePruner })
+	}
+
 	var staker *validator.Staker
 	if config.Validator.Enable {
+		var stakerDataPoster *validator.StakerDataPoster
+		if txOpts != nil {
+			stakerDataPoster, err = validator.NewStakerDataPoster(rawdb.NewTable(arbDb, stakerPrefix), l1Reader, txOpts, func() *dataposter.DataPosterConfig { return &configFetcher.Get().Validator.DataPoster })
+			if err != nil {
+				return nil, err
+			}
+		}
 		var wallet validator.ValidatorWalletInterface
 		if config.Validator.UseSmartContractWallet || txOpts == nil {
 			var existingWalletAddress *common.Address
@@ -1024,7 +1108,7 @@ func createNodeImpl(
 				tmpAddress := common.HexToAddress(config.Validator.ContractWalletAddress)
 				existingWalletAddress = &tmpAddress
 			}
-			wallet, err = validator.NewContractValidatorWallet(existingWalletAddress, deployInfo.ValidatorWalletCreator, deployInfo.Rollup, l1Reader, txOpts, int64(deployInfo.DeployedAt), func(common.Address) {})
+			wallet, err = validator.NewContractValidatorWallet(stakerDataPoster, existingWalletAddress, deployInfo.ValidatorWalletCreator, deployInfo.Rollup, l1Reader, txOpts, int64(deployInfo.DeployedAt), func(common.Address) {})
 			if err != nil {
 				return nil, err
 			}
@@ -1032,7 +1116,7 @@ func createNodeImpl(
 			if len(config.Validator.ContractWalletAddress) > 0 {
 				return nil, errors.New("validator contract wallet specified but flag to use a smart contract wallet was not specified")
 			}
-			wallet, err = validator.NewEoaValidatorWallet(deployInfo.Rollup, l1client, txOpts)
+			wallet, err = validator.NewEoaValidatorWallet(stakerDataPoster, deployInfo.Rollup, l1client, txOpts)
 			if err != nil {
 				return nil, err
 			}
@@ -1064,7 +1148,12 @@ func createNodeImpl(
 		if txOpts == nil {
 			return nil, errors.New("batchposter, but no TxOpts")
 		}
-		batchPoster, err = NewBatchPoster(l1Reader, inboxTracker, txStreamer, syncMonitor, func() *BatchPosterConfig { return &configFetcher.Get().BatchPoster }, deployInfo.SequencerInbox, txOpts, daWriter)
+		dasRetentionPeriod := func() time.Duration { return configFetcher.Get().BatchPoster.DASRetentionPeriod }
+		sink, err := newBatchSink(config.BatchPoster.Sink, daWriter, externalDA, config.ExternalDA.HeaderByte, dasRetentionPeriod)
+		if err != nil {
+			return nil, err
+		}
+		batchPoster, err = NewBatchPoster(rawdb.NewTable(arbDb, batchPosterPrefix), l1Reader, inboxTracker, txStreamer, syncMonitor, func() *BatchPosterConfig { return &configFetcher.Get().BatchPoster }, deployInfo.SequencerInbox, txOpts, sink)
 		if err != nil {
 			return nil, err
 		}
@@ -1084,6 +1173,7 @@ func createNodeImpl(
 		l1Reader,
 		txStreamer,
 		txPublisher,
+		sequencer,
 		deployInfo,
 		inboxReader,
 		inboxTracker,
@@ -1098,6 +1188,8 @@ func createNodeImpl(
 		dasLifecycleManager,
 		classicOutbox,
 		syncMonitor,
+		batchCostTracker,
+		messagePruner,
 		configFetcher,
 		ctx,
 	}, nil
@@ -1357,7 +1449,95 @@ func CreateNode(
 		Service:   &ArbAPI{currentNode.TxPublisher},
 		Public:    false,
 	})
+	apis = append(apis, rpc.API{
+		Namespace: "eth",
+		Version:   "1.0",
+		Service:   &ArbTransactionAPI{currentNode.TxPublisher},
+		Public:    true,
+	})
+	apis = append(apis, rpc.API{
+		Namespace: "eth",
+		Version:   "1.0",
+		Service:   &ReorgEventsAPI{currentNode.TxStreamer},
+		Public:    true,
+	})
 	config := configFetcher.Get()
+	txReceiptWaiter := newTxReceiptWaiter()
+	rpcClient, err := stack.Attach()
+	if err != nil {
+		return nil, err
+	}
+	currentNode.TxStreamer.AddNewBlockHook(txReceiptWaiter.newBlockHook)
+	apis = append(apis, rpc.API{
+		Namespace: "arb",
+		Version:   "1.0",
+		Service: &ArbSyncTransactionAPI{
+			txPublisher: currentNode.TxPublisher,
+			rpcClient:   rpcClient,
+			waiter:      txReceiptWaiter,
+			timeout:     config.TxSync.Timeout,
+		},
+		Public: true,
+	})
+	if bundlePublisher, ok := currentNode.TxPublisher.(BundlePublisher); ok {
+		// bundles go through the same pre-checks and rate limits as transactions, and are forwarded by non-sequencers
+		apis = append(apis, rpc.API{
+			Namespace: "arb",
+			Version:   "1.0",
+			Service:   &ArbBundleAPI{bundlePublisher},
+			Public:    true,
+		})
+	}
+	if currentNode.Sequencer != nil {
+		apis = append(apis, rpc.API{
+			Namespace: "eth",
+			Version:   "1.0",
+			Service:   &PendingTransactionsAPI{currentNode.Sequencer.PendingTxFeed()},
+			Public:    true,
+		})
+		apis = append(apis, rpc.API{
+			Namespace: "sequenceradmin",
+			Version:   "1.0",
+			Service:   &SequencerAdminAPI{currentNode.Sequencer},
+			Public:    false,
+		})
+	}
+	if currentNode.BatchPoster != nil {
+		apis = append(apis, rpc.API{
+			Namespace: "batchposter",
+			Version:   "1.0",
+			Service:   &BatchPosterAPI{currentNode.BatchPoster},
+			Public:    false,
+		})
+	}
+	if currentNode.InboxTracker != nil {
+		apis = append(apis, rpc.API{
+			Namespace: "arbarchive",
+			Version:   "1.0",
+			Service:   &MessageArchiveAPI{currentNode, func() *MessageArchiveConfig { return &configFetcher.Get().MessageArchive }},
+			Public:    false,
+		})
+	}
+	if currentNode.InboxReader != nil {
+		rollup, err := validator.NewRollupWatcher(currentNode.DeployInfo.Rollup, l1client, bind.CallOpts{})
+		if err != nil {
+			return nil, err
+		}
+		apis = append(apis, rpc.API{
+			Namespace: "arb",
+			Version:   "1.0",
+			Service:   NewBatchProvenanceAPI(currentNode.TxStreamer, currentNode.InboxTracker, currentNode.InboxReader, rollup),
+			Public:    true,
+		})
+	}
+	if currentNode.BatchCostTracker != nil {
+		apis = append(apis, rpc.API{
+			Namespace: "arb",
+			Version:   "1.0",
+			Service:   &BatchCostsAPI{currentNode.BatchCostTracker},
+			Public:    false,
+		})
+	}
 	apis = append(apis, rpc.API{
 		Namespace: "arbdebug",
 		Version:   "1.0",
@@ -1402,6 +1582,12 @@ func (n *Node) Start(ctx context.Context) error {
 		if err != nil {
 			return fmt.Errorf("error initializing inbox tracker: %w", err)
 		}
+		if n.configFetcher != nil && n.configFetcher.Get().MessageArchive.ImportFile != "" {
+			err = n.importMessageArchive(ctx, n.configFetcher.Get().MessageArchive.ImportFile)
+			if err != nil {
+				return fmt.Errorf("error importing message archive: %w", err)
+			}
+		}
 	}
 	if n.BroadcastServer != nil {
 		err = n.BroadcastServer.Initialize()
@@ -1429,6 +1615,12 @@ func (n *Node) Start(ctx context.Context) error {
 	if n.BatchPoster != nil {
 		n.BatchPoster.Start(ctx)
 	}
+	if n.BatchCostTracker != nil {
+		n.BatchCostTracker.Start(ctx)
+	}
+	if n.MessagePruner != nil {
+		n.MessagePruner.Start(ctx)
+	}
 	if n.Staker != nil {
 		err = n.Staker.Initialize(ctx)
 		if err != nil {
@@ -1466,6 +1658,14 @@ func (n *Node) Start(ctx context.Context) error {
 	return nil
 }
 
+func (n *Node) importMessageArchive(ctx context.Context, path string) error {
+	if n.InboxReader == nil {
+		return errors.New("message archives can only be imported by nodes reading the inbox from L1, which the archive is verified against")
+	}
+	_, err := ImportMessageArchiveFile(ctx, path, n.TxStreamer, n.InboxTracker, n.InboxReader)
+	return err
+}
+
 func (n *Node) StopAndWait() {
 	if n.configFetcher != nil && n.configFetcher.Started() {
 		n.configFetcher.StopAndWait()
@@ -1485,6 +1685,15 @@ func (n *Node) StopAndWait() {
 	if n.StatelessBlockValidator != nil {
 		n.StatelessBlockValidator.Stop()
 	}
+	if n.Staker != nil && n.Staker.Started() {
+		n.Staker.StopAndWait()
+	}
+	if n.MessagePruner != nil && n.MessagePruner.Started() {
+		n.MessagePruner.StopAndWait()
+	}
+	if n.BatchCostTracker != nil && n.BatchCostTracker.Started() {
+		n.BatchCostTracker.StopAndWait()
+	}
 	if n.BatchPoster != nil && n.BatchPoster.Started() {
 		n.BatchPoster.StopAndWait()
 	}
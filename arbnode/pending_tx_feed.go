@@ -0,0 +1,128 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+var pendingTxFeedDroppedCounter = metrics.NewRegisteredCounter("arb/sequencer/pendingtxfeed/dropped", nil)
+
+// Statuses of pending transaction events
+const (
+	PendingTxStatusPending  = "pending"
+	PendingTxStatusIncluded = "included"
+	PendingTxStatusRejected = "rejected"
+	PendingTxStatusRequeued = "requeued"
+	PendingTxStatusHeld     = "held"
+)
+
+// PendingTxEvent is published when the sequencer starts sequencing a transaction,
+// and again with the result once it's been included, rejected, requeued, or held for a future nonce.
+type PendingTxEvent struct {
+	Hash        common.Hash     `json:"hash"`
+	Status      string          `json:"status"`
+	Tx          hexutil.Bytes   `json:"tx,omitempty"`
+	Error       string          `json:"error,omitempty"`
+	BlockNumber *hexutil.Uint64 `json:"blockNumber,omitempty"`
+	BlockHash   *common.Hash    `json:"blockHash,omitempty"`
+}
+
+// PendingTxFeed fans out pending transaction events to subscribers through an event.Feed.
+// Sending never blocks on a slow subscriber: events are dropped for subscribers that fall behind.
+type PendingTxFeed struct {
+	feed  event.Feed
+	scope event.SubscriptionScope
+}
+
+func NewPendingTxFeed() *PendingTxFeed {
+	return &PendingTxFeed{}
+}
+
+// Subscribe returns a channel of events with the given buffer size, and its subscription.
+func (f *PendingTxFeed) Subscribe(bufferSize int) (<-chan *PendingTxEvent, event.Subscription) {
+	events, sub := subscribeDropping[*PendingTxEvent](&f.feed, bufferSize, pendingTxFeedDroppedCounter)
+	return events, f.scope.Track(sub)
+}
+
+func (f *PendingTxFeed) HasSubscribers() bool {
+	return f.scope.Count() > 0
+}
+
+func (f *PendingTxFeed) send(event *PendingTxEvent) {
+	f.feed.Send(event)
+}
+
+// subscribeDropping subscribes to feed through a relay that always keeps up with the feed,
+// so that sending isn't held up by the subscriber. Events that don't fit in the returned
+// channel's buffer are dropped and counted instead.
+func subscribeDropping[T any](feed *event.Feed, bufferSize int, droppedCounter metrics.Counter) (<-chan T, event.Subscription) {
+	relay := make(chan T)
+	events := make(chan T, bufferSize)
+	sub := feed.Subscribe(relay)
+	go func() {
+		for {
+			select {
+			case ev := <-relay:
+				select {
+				case events <- ev:
+				default:
+					droppedCounter.Inc(1)
+				}
+			case <-sub.Err():
+				return
+			}
+		}
+	}()
+	return events, sub
+}
+
+func (f *PendingTxFeed) SendPending(tx *types.Transaction) {
+	if !f.HasSubscribers() {
+		return
+	}
+	txBytes, err := tx.MarshalBinary()
+	if err != nil {
+		log.Warn("failed to encode transaction for pending tx feed", "tx", tx.Hash(), "err", err)
+	}
+	f.send(&PendingTxEvent{
+		Hash:   tx.Hash(),
+		Status: PendingTxStatusPending,
+		Tx:     txBytes,
+	})
+}
+
+func (f *PendingTxFeed) SendIncluded(tx *types.Transaction, block *types.Block) {
+	if !f.HasSubscribers() {
+		return
+	}
+	blockNumber := hexutil.Uint64(block.NumberU64())
+	blockHash := block.Hash()
+	f.send(&PendingTxEvent{
+		Hash:        tx.Hash(),
+		Status:      PendingTxStatusIncluded,
+		BlockNumber: &blockNumber,
+		BlockHash:   &blockHash,
+	})
+}
+
+// SendResult publishes a status other than included, with the error that caused it, if any
+func (f *PendingTxFeed) SendResult(tx *types.Transaction, status string, err error) {
+	if !f.HasSubscribers() {
+		return
+	}
+	event := &PendingTxEvent{
+		Hash:   tx.Hash(),
+		Status: status,
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	f.send(event)
+}
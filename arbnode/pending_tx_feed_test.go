@@ -0,0 +1,61 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestPendingTxFeed(t *testing.T) {
+	feed := NewPendingTxFeed()
+	tx := types.NewTx(&types.LegacyTx{Nonce: 1})
+
+	// Without subscribers, nothing is sent
+	feed.SendPending(tx)
+
+	fast, fastSub := feed.Subscribe(4)
+	defer fastSub.Unsubscribe()
+	slow, slowSub := feed.Subscribe(1)
+
+	feed.SendPending(tx)
+	feed.SendResult(tx, PendingTxStatusRejected, errors.New("nonce too low"))
+	// Once this is sent, the subscribers' relays have handled the result
+	feed.SendResult(tx, PendingTxStatusRequeued, nil)
+
+	event := <-fast
+	if event.Hash != tx.Hash() || event.Status != PendingTxStatusPending || len(event.Tx) == 0 {
+		Fail(t, "unexpected pending event", event)
+	}
+	event = <-fast
+	if event.Status != PendingTxStatusRejected || event.Error != "nonce too low" {
+		Fail(t, "unexpected result event", event)
+	}
+	event = <-fast
+	if event.Status != PendingTxStatusRequeued {
+		Fail(t, "unexpected second result event", event)
+	}
+
+	// The slow subscriber's buffer was full, so it missed the result instead of blocking the feed
+	event = <-slow
+	if event.Status != PendingTxStatusPending {
+		Fail(t, "unexpected event for slow subscriber", event)
+	}
+	select {
+	case event = <-slow:
+		if event.Status == PendingTxStatusRejected {
+			Fail(t, "slow subscriber got an event past its buffer", event)
+		}
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	slowSub.Unsubscribe()
+	fastSub.Unsubscribe()
+	if feed.HasSubscribers() {
+		Fail(t, "feed still has subscribers after unsubscribing")
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+
+	"github.com/offchainlabs/nitro/arbutil"
+)
+
+var reorgEventsDroppedCounter = metrics.NewRegisteredCounter("arb/txstreamer/reorgevents/dropped", nil)
+
+type ReorgCause string
+
+const (
+	// L1 reorged out batches or delayed messages the node had read
+	ReorgCauseL1Reorg ReorgCause = "l1-reorg"
+	// messages confirmed on L1 conflicted with messages the node got earlier from the feed or the sequencer
+	ReorgCauseFeedConflict ReorgCause = "feed-conflict"
+	// messages confirmed on L1 replaced a message the sequencer coordinator had invalidated
+	ReorgCauseCoordinatorInvalidation ReorgCause = "coordinator-invalidation"
+	// TransactionStreamer.ReorgTo was called directly
+	ReorgCauseManual ReorgCause = "manual"
+)
+
+// ReorgEvent describes a reorg of the node's messages, and the L2 blocks built from them.
+// Blocks from FirstAffectedBlock to LastAffectedBlock inclusive were removed, and will be rebuilt from the new messages.
+// The range is empty, with the first block after the last, if the reorged messages hadn't been built into blocks yet.
+type ReorgEvent struct {
+	Cause              ReorgCause           `json:"cause"`
+	OldMessageCount    arbutil.MessageIndex `json:"oldMessageCount"`
+	NewMessageCount    arbutil.MessageIndex `json:"newMessageCount"`
+	FirstAffectedBlock uint64               `json:"firstAffectedBlock"`
+	LastAffectedBlock  uint64               `json:"lastAffectedBlock"`
+	OldHeadHash        common.Hash          `json:"oldHeadHash"`
+	NewHeadHash        common.Hash          `json:"newHeadHash"`
+}
+
+// SubscribeReorgEvents returns a channel of the reorgs written to the database, with the given buffer size, and its subscription.
+// Events are sent after the streamer releases its insertion lock, and are dropped for subscribers whose buffer is full.
+func (s *TransactionStreamer) SubscribeReorgEvents(bufferSize int) (<-chan ReorgEvent, event.Subscription) {
+	return subscribeDropping[ReorgEvent](&s.reorgFeed, bufferSize, reorgEventsDroppedCounter)
+}
+
+// queueReorgEvent queues a reorg to be sent once the insertionMutex, which must be held, is released
+func (s *TransactionStreamer) queueReorgEvent(reorg *ReorgEvent) {
+	if reorg == nil {
+		return
+	}
+	log.Info(
+		"TransactionStreamer: reorged",
+		"cause", reorg.Cause,
+		"oldMessageCount", reorg.OldMessageCount,
+		"newMessageCount", reorg.NewMessageCount,
+		"firstAffectedBlock", reorg.FirstAffectedBlock,
+		"lastAffectedBlock", reorg.LastAffectedBlock,
+	)
+	s.unsentReorgEvents = append(s.unsentReorgEvents, *reorg)
+}
+
+// unlockInsertion releases the insertionMutex, then sends the reorg events queued while it was held
+func (s *TransactionStreamer) unlockInsertion() {
+	reorgs := s.unsentReorgEvents
+	if len(reorgs) == 0 {
+		s.insertionMutex.Unlock()
+		return
+	}
+	s.unsentReorgEvents = nil
+	// taken before releasing the insertionMutex, so the next reorg's events can't be sent before these
+	s.reorgSendMutex.Lock()
+	defer s.reorgSendMutex.Unlock()
+	s.insertionMutex.Unlock()
+	for _, reorg := range reorgs {
+		s.reorgFeed.Send(reorg)
+	}
+}
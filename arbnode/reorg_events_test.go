@@ -0,0 +1,65 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/offchainlabs/nitro/arbos"
+	"github.com/offchainlabs/nitro/arbstate"
+)
+
+func TestReorgEvents(t *testing.T) {
+	streamer, _, _ := NewTransactionStreamerForTest(t, common.Address{})
+	var messages []arbstate.MessageWithMetadata
+	for i := 0; i < 3; i++ {
+		messages = append(messages, arbstate.MessageWithMetadata{
+			Message: &arbos.L1IncomingMessage{
+				Header: &arbos.L1IncomingMessageHeader{
+					Kind:      arbos.L1MessageType_L2Message,
+					L1BaseFee: common.Big0,
+				},
+				L2msg: []byte{byte(i)},
+			},
+			DelayedMessagesRead: 1,
+		})
+	}
+	Require(t, streamer.AddMessages(1, false, messages))
+
+	events, sub := streamer.SubscribeReorgEvents(1)
+	defer sub.Unsubscribe()
+	// a subscriber that never reads its events mustn't hold up reorgs
+	_, stalledSub := streamer.SubscribeReorgEvents(0)
+	defer stalledSub.Unsubscribe()
+
+	Require(t, streamer.ReorgTo(3))
+	// this reorg's event doesn't fit in the subscriber's buffer, so it's dropped
+	Require(t, streamer.ReorgTo(2))
+	msgCount, err := streamer.GetMessageCount()
+	Require(t, err)
+	if msgCount != 2 {
+		Fail(t, "Unexpected message count after reorgs", msgCount)
+	}
+
+	select {
+	case reorg := <-events:
+		if reorg.Cause != ReorgCauseManual || reorg.OldMessageCount != 4 || reorg.NewMessageCount != 3 {
+			Fail(t, "Unexpected reorg event", reorg)
+		}
+		// the streamer isn't running, so no blocks were built from the reorged message
+		if reorg.FirstAffectedBlock <= reorg.LastAffectedBlock {
+			Fail(t, "Unexpected reorged blocks", reorg.FirstAffectedBlock, "to", reorg.LastAffectedBlock)
+		}
+	case <-time.After(time.Second):
+		Fail(t, "No reorg event sent")
+	}
+	select {
+	case reorg := <-events:
+		Fail(t, "Reorg event wasn't dropped", reorg)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
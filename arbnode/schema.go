@@ -5,15 +5,20 @@ package arbnode
 
 var (
 	blockValidatorPrefix       string = "v"         // the prefix for all block validator keys
+	batchPosterPrefix          string = "b"         // the prefix for all batch poster keys
+	stakerPrefix               string = "t"         // the prefix for all staker keys
 	messagePrefix              []byte = []byte("m") // maps a message sequence number to a message
 	legacyDelayedMessagePrefix []byte = []byte("d") // maps a delayed sequence number to an accumulator and a message as serialized on L1
 	rlpDelayedMessagePrefix    []byte = []byte("e") // maps a delayed sequence number to an accumulator and an RLP encoded message
 	sequencerBatchMetaPrefix   []byte = []byte("s") // maps a batch sequence number to BatchMetadata
 	delayedSequencedPrefix     []byte = []byte("a") // maps a delayed message count to the first sequencer batch sequence number with this delayed count
+	batchCostPrefix            []byte = []byte("c") // maps a batch sequence number to its BatchPostingCost
 
 	messageCountKey        []byte = []byte("_messageCount")        // contains the current message count
 	delayedMessageCountKey []byte = []byte("_delayedMessageCount") // contains the current delayed message count
 	sequencerBatchCountKey []byte = []byte("_sequencerBatchCount") // contains the current sequencer message count
+	batchCostCountKey      []byte = []byte("_batchCostCount")      // contains the number of batches with recorded costs
+	inboxPruneStateKey     []byte = []byte("_inboxPruneState")     // contains a rlp encoded InboxPruneState
 	dbSchemaVersion        []byte = []byte("_schemaVersion")       // contains a uint64 representing the database schema version
 )
 
@@ -400,8 +400,32 @@ func (c *SeqCoordinator) noRedisError() time.Duration {
 	return c.config.UpdateInterval
 }
 
+func (c *SeqCoordinator) sequencerPaused() bool {
+	return c.sequencer != nil && c.sequencer.Paused()
+}
+
+// releaseForPause gives up the chosen-one lock and liveliness, so another sequencer can take over while ours is paused
+func (c *SeqCoordinator) releaseForPause(ctx context.Context) time.Duration {
+	if err := c.chosenOneRelease(ctx); err != nil {
+		log.Warn("coordinator failed chosen one release for paused sequencer", "err", err)
+		return c.retryAfterRedisError()
+	}
+	if err := c.livelinessRelease(ctx); err != nil {
+		log.Warn("coordinator failed liveliness release for paused sequencer", "err", err)
+		return c.retryAfterRedisError()
+	}
+	c.reportedAlive = false
+	// Setting prevChosenSequencer to an empty string will cause the next update to forward to the new chosen sequencer
+	c.prevChosenSequencer = ""
+	log.Info("released chosen-coordinator lock as sequencer is paused")
+	return c.noRedisError()
+}
+
 // update for the prev known-chosen sequencer (no need to load new messages)
 func (c *SeqCoordinator) updatePrevKnownChosen(ctx context.Context, nextChosen string) time.Duration {
+	if nextChosen == c.config.MyUrl() && c.sequencerPaused() {
+		return c.releaseForPause(ctx)
+	}
 	if nextChosen != c.config.MyUrl() {
 		// was the active sequencer, but no longer
 		setPrevChosenTo := nextChosen
@@ -554,7 +578,7 @@ func (c *SeqCoordinator) update(ctx context.Context) time.Duration {
 	}
 
 	// can take over as main sequencer?
-	if localMsgCount >= remoteMsgCount && chosenSeq == c.config.MyUrl() {
+	if localMsgCount >= remoteMsgCount && chosenSeq == c.config.MyUrl() && !c.sequencerPaused() {
 		if c.sequencer == nil {
 			log.Error("myurl main sequencer, but no sequencer exists")
 			return c.noRedisError()
@@ -577,7 +601,7 @@ func (c *SeqCoordinator) update(ctx context.Context) time.Duration {
 
 	// update liveliness
 	var livelinessErr error
-	if c.sync.Synced() {
+	if c.sync.Synced() && !c.sequencerPaused() {
 		livelinessErr = c.livelinessUpdate(ctx)
 		if livelinessErr == nil {
 			c.reportedAlive = true
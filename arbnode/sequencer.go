@@ -28,6 +28,7 @@ import (
 	"github.com/offchainlabs/nitro/arbos"
 	"github.com/offchainlabs/nitro/arbos/arbosState"
 	"github.com/offchainlabs/nitro/arbos/l1pricing"
+	"github.com/offchainlabs/nitro/arbutil"
 	"github.com/offchainlabs/nitro/util/stopwaiter"
 	"github.com/pkg/errors"
 )
@@ -42,6 +43,11 @@ var (
 	successfulBlocksCounter   = metrics.NewRegisteredCounter("arb/sequencer/block/successful", nil)
 )
 
+var (
+	conditionalTxRejectedCounter = metrics.NewRegisteredCounter("arb/sequencer/conditionaltx/rejected", nil)
+	conditionalTxAcceptedCounter = metrics.NewRegisteredCounter("arb/sequencer/conditionaltx/accepted", nil)
+)
+
 type SequencerConfig struct {
 	Enable                      bool                     `koanf:"enable"`
 	MaxBlockSpeed               time.Duration            `koanf:"max-block-speed" reload:"hot"`
@@ -53,6 +59,11 @@ type SequencerConfig struct {
 	QueueTimeout                time.Duration            `koanf:"queue-timeout" reload:"hot"`
 	NonceCacheSize              int                      `koanf:"nonce-cache-size" reload:"hot"`
 	MaxTxDataSize               int                      `koanf:"max-tx-data-size" reload:"hot"`
+	TxOrdering                  string                   `koanf:"tx-ordering" reload:"hot"`
+	NonceHoldingPool            NonceHoldingPoolConfig   `koanf:"nonce-holding-pool" reload:"hot"`
+	TxFilter                    TxFilterConfig           `koanf:"tx-filter" reload:"hot"`
+	RateLimit                   TxRateLimitConfig        `koanf:"rate-limit"`
+	Bundle                      BundleConfig             `koanf:"bundle" reload:"hot"`
 	Dangerous                   DangerousSequencerConfig `koanf:"dangerous"`
 }
 
@@ -66,7 +77,10 @@ func (c *SequencerConfig) Validate() error {
 			return fmt.Errorf("sequencer sender whitelist entry \"%v\" is not a valid address", address)
 		}
 	}
-	return nil
+	if err := c.TxFilter.Validate(); err != nil {
+		return err
+	}
+	return validateTxOrdering(c.TxOrdering)
 }
 
 type SequencerConfigFetcher func() *SequencerConfig
@@ -82,7 +96,12 @@ var DefaultSequencerConfig = SequencerConfig{
 	NonceCacheSize:              1024,
 	Dangerous:                   DefaultDangerousSequencerConfig,
 	// 95% of the default batch poster limit, leaving 5KB for headers and such
-	MaxTxDataSize: 95000,
+	MaxTxDataSize:    95000,
+	TxOrdering:       TxOrderingFifo,
+	NonceHoldingPool: DefaultNonceHoldingPoolConfig,
+	TxFilter:         DefaultTxFilterConfig,
+	RateLimit:        DefaultTxRateLimitConfig,
+	Bundle:           DefaultBundleConfig,
 }
 
 var TestSequencerConfig = SequencerConfig{
@@ -97,6 +116,11 @@ var TestSequencerConfig = SequencerConfig{
 	NonceCacheSize:              4,
 	Dangerous:                   TestDangerousSequencerConfig,
 	MaxTxDataSize:               95000,
+	TxOrdering:                  TxOrderingFifo,
+	NonceHoldingPool:            TestNonceHoldingPoolConfig,
+	TxFilter:                    DefaultTxFilterConfig,
+	RateLimit:                   DefaultTxRateLimitConfig,
+	Bundle:                      TestBundleConfig,
 }
 
 func SequencerConfigAddOptions(prefix string, f *flag.FlagSet) {
@@ -110,14 +134,22 @@ func SequencerConfigAddOptions(prefix string, f *flag.FlagSet) {
 	f.Duration(prefix+".queue-timeout", DefaultSequencerConfig.QueueTimeout, "maximum amount of time transaction can wait in queue")
 	f.Int(prefix+".nonce-cache-size", DefaultSequencerConfig.NonceCacheSize, "size of the tx sender nonce cache")
 	f.Int(prefix+".max-tx-data-size", DefaultSequencerConfig.MaxTxDataSize, "maximum transaction size the sequencer will accept")
+	f.String(prefix+".tx-ordering", DefaultSequencerConfig.TxOrdering, "order of queued transactions within a block (\"fifo\" or \"fee-priority\", which groups by sender in nonce order and ranks senders by effective tip)")
+	NonceHoldingPoolConfigAddOptions(prefix+".nonce-holding-pool", f)
+	TxFilterConfigAddOptions(prefix+".tx-filter", f)
+	TxRateLimitConfigAddOptions(prefix+".rate-limit", f)
+	BundleConfigAddOptions(prefix+".bundle", f)
 	DangerousSequencerConfigAddOptions(prefix+".dangerous", f)
 }
 
 type txQueueItem struct {
 	tx             *types.Transaction
+	options        *arbutil.ConditionalOptions
 	resultChan     chan<- error
 	returnedResult bool
 	ctx            context.Context
+	tracker        *txQueueTracker
+	trackingId     uint64
 }
 
 func (i *txQueueItem) returnResult(err error) {
@@ -126,6 +158,9 @@ func (i *txQueueItem) returnResult(err error) {
 		return
 	}
 	i.returnedResult = true
+	if i.tracker != nil {
+		i.tracker.remove(i.trackingId)
+	}
 	i.resultChan <- err
 	close(i.resultChan)
 }
@@ -216,10 +251,18 @@ type Sequencer struct {
 	txStreamer      *TransactionStreamer
 	txQueue         chan txQueueItem
 	txRetryQueue    containers.Queue[txQueueItem]
+	retryQueueDepth int64 // atomic, as only the sequencer thread may touch txRetryQueue
+	bundleQueue     chan bundleQueueItem
 	l1Reader        *headerreader.HeaderReader
 	config          SequencerConfigFetcher
 	senderWhitelist map[common.Address]struct{}
 	nonceCache      *nonceCache
+	noncePool       *nonceHoldingPool
+	txFilter        *TxFilter
+	pendingTxFeed   *PendingTxFeed
+	queueTracker    *txQueueTracker
+	adminState      int32
+	resumeNotifier  chan struct{}
 
 	L1BlockAndTimeMutex sync.Mutex
 	l1BlockNumber       uint64
@@ -242,13 +285,23 @@ func NewSequencer(txStreamer *TransactionStreamer, l1Reader *headerreader.Header
 		}
 		senderWhitelist[common.HexToAddress(address)] = struct{}{}
 	}
+	txFilter := NewTxFilter()
+	if err := txFilter.Reload(config.TxFilter.RulesFile); err != nil {
+		return nil, fmt.Errorf("error loading sequencer tx filter rules: %w", err)
+	}
 	return &Sequencer{
 		txStreamer:      txStreamer,
 		txQueue:         make(chan txQueueItem, config.QueueSize),
+		bundleQueue:     make(chan bundleQueueItem, config.Bundle.QueueSize),
 		l1Reader:        l1Reader,
 		config:          configFetcher,
 		senderWhitelist: senderWhitelist,
 		nonceCache:      newNonceCache(config.NonceCacheSize),
+		noncePool:       newNonceHoldingPool(),
+		txFilter:        txFilter,
+		pendingTxFeed:   NewPendingTxFeed(),
+		queueTracker:    newTxQueueTracker(),
+		resumeNotifier:  make(chan struct{}, 1),
 		l1BlockNumber:   0,
 		l1Timestamp:     0,
 	}, nil
@@ -264,47 +317,49 @@ func (s *Sequencer) ctxWithQueueTimeout(inctx context.Context) (context.Context,
 	return context.WithTimeout(inctx, timeout)
 }
 
-func (s *Sequencer) PublishTransaction(parentCtx context.Context, tx *types.Transaction) error {
+func (s *Sequencer) PublishTransaction(parentCtx context.Context, tx *types.Transaction, options *arbutil.ConditionalOptions) error {
 	sequencerBacklogGauge.Inc(1)
 	defer sequencerBacklogGauge.Dec(1)
 
 	forwarder := s.GetForwarder()
 	if forwarder != nil {
-		err := forwarder.PublishTransaction(parentCtx, tx)
+		err := forwarder.PublishTransaction(parentCtx, tx, options)
 		if !errors.Is(err, ErrNoSequencer) {
 			return err
 		}
 	}
 
-	if len(s.senderWhitelist) > 0 {
-		signer := types.LatestSigner(s.txStreamer.bc.Config())
-		sender, err := types.Sender(signer, tx)
-		if err != nil {
+	if s.state() == SequencerStateDraining {
+		return ErrSequencerDraining
+	}
+
+	if err := s.checkSubmittedTx(tx); err != nil {
+		return err
+	}
+	if options != nil {
+		if err := options.Validate(); err != nil {
 			return err
 		}
-		_, authorized := s.senderWhitelist[sender]
-		if !authorized {
-			return errors.New("transaction sender is not on the whitelist")
-		}
-	}
-	if tx.Type() >= types.ArbitrumDepositTxType {
-		// Should be unreachable due to UnmarshalBinary not accepting Arbitrum internal txs
-		return types.ErrTxTypeNotSupported
 	}
 
 	ctx, cancelFunc := s.ctxWithQueueTimeout(parentCtx)
 	defer cancelFunc()
 
+	// The sender is only used for queue introspection, so an invalid signature is left for the block processor to reject
+	sender, _ := types.Sender(types.LatestSigner(s.txStreamer.bc.Config()), tx)
 	resultChan := make(chan error, 1)
 	queueItem := txQueueItem{
-		tx,
-		resultChan,
-		false,
-		ctx,
+		tx:         tx,
+		options:    options,
+		resultChan: resultChan,
+		ctx:        ctx,
+		tracker:    s.queueTracker,
+		trackingId: s.queueTracker.add(sender, time.Now()),
 	}
 	select {
 	case s.txQueue <- queueItem:
 	case <-ctx.Done():
+		s.queueTracker.remove(queueItem.trackingId)
 		return ctx.Err()
 	}
 
@@ -316,7 +371,36 @@ func (s *Sequencer) PublishTransaction(parentCtx context.Context, tx *types.Tran
 	}
 }
 
-func (s *Sequencer) preTxFilter(_ *params.ChainConfig, header *types.Header, statedb *state.StateDB, _ *arbosState.ArbosState, tx *types.Transaction, sender common.Address) error {
+func (s *Sequencer) checkSubmittedTx(tx *types.Transaction) error {
+	if len(s.senderWhitelist) > 0 {
+		signer := types.LatestSigner(s.txStreamer.bc.Config())
+		sender, err := types.Sender(signer, tx)
+		if err != nil {
+			return err
+		}
+		_, authorized := s.senderWhitelist[sender]
+		if !authorized {
+			return errors.New("transaction sender is not on the whitelist")
+		}
+	}
+	if tx.Type() >= types.ArbitrumDepositTxType {
+		// Should be unreachable due to UnmarshalBinary not accepting Arbitrum internal txs
+		return types.ErrTxTypeNotSupported
+	}
+	return nil
+}
+
+func (s *Sequencer) preTxFilter(_ *params.ChainConfig, header *types.Header, statedb *state.StateDB, _ *arbosState.ArbosState, tx *types.Transaction, options *arbutil.ConditionalOptions, sender common.Address) error {
+	if err := s.txFilter.PreCheck(header, tx, sender); err != nil {
+		return err
+	}
+	if options != nil {
+		if err := options.Check(header.Number.Uint64(), header.Time, statedb); err != nil {
+			conditionalTxRejectedCounter.Inc(1)
+			return err
+		}
+		conditionalTxAcceptedCounter.Inc(1)
+	}
 	if s.nonceCache.GetSize() > 0 {
 		stateNonce := s.nonceCache.Get(header, statedb, sender)
 		err := MakeNonceError(sender, tx.Nonce(), stateNonce)
@@ -332,10 +416,17 @@ func (s *Sequencer) postTxFilter(header *types.Header, _ *arbosState.ArbosState,
 	if result.Err != nil && result.UsedGas > dataGas && result.UsedGas-dataGas <= s.config().MaxRevertGasReject {
 		return arbitrum.NewRevertReason(result)
 	}
+	if err := s.txFilter.PostCheck(header, tx, sender, result); err != nil {
+		return err
+	}
 	s.nonceCache.Update(header, sender, tx.Nonce()+1)
 	return nil
 }
 
+func (s *Sequencer) PendingTxFeed() *PendingTxFeed {
+	return s.pendingTxFeed
+}
+
 func (s *Sequencer) CheckHealth(ctx context.Context) error {
 	s.forwarderMutex.Lock()
 	forwarder := s.forwarder
@@ -356,20 +447,20 @@ func (s *Sequencer) ForwardTarget() string {
 	if s.forwarder == nil {
 		return ""
 	}
-	return s.forwarder.target
+	return s.forwarder.PrimaryTarget()
 }
 
 func (s *Sequencer) ForwardTo(url string) error {
 	s.forwarderMutex.Lock()
 	defer s.forwarderMutex.Unlock()
 	if s.forwarder != nil {
-		if s.forwarder.target == url {
+		if s.forwarder.PrimaryTarget() == url {
 			log.Warn("attempted to update sequencer forward target with existing target", "url", url)
 			return nil
 		}
 		s.forwarder.Disable()
 	}
-	s.forwarder = NewForwarder(url, &s.config().Forwarder)
+	s.forwarder = NewForwarder([]string{url}, &s.config().Forwarder)
 	err := s.forwarder.Initialize(s.GetContext())
 	if err != nil {
 		log.Error("failed to set forward agent", "err", err)
@@ -409,7 +500,7 @@ func (s *Sequencer) forwardIfSet(queueItems []txQueueItem) bool {
 		return false
 	}
 	for _, item := range queueItems {
-		res := forwarder.PublishTransaction(item.ctx, item.tx)
+		res := forwarder.PublishTransaction(item.ctx, item.tx, item.options)
 		if errors.Is(res, ErrNoSequencer) {
 			s.requeueOrFail(item, ErrNoSequencer)
 		} else {
@@ -421,11 +512,17 @@ func (s *Sequencer) forwardIfSet(queueItems []txQueueItem) bool {
 
 var sequencerInternalError = errors.New("sequencer internal error")
 
+// publishRetryQueueDepth makes the retry queue's depth readable outside the sequencer thread
+func (s *Sequencer) publishRetryQueueDepth() {
+	atomic.StoreInt64(&s.retryQueueDepth, int64(s.txRetryQueue.Len()))
+}
+
 func (s *Sequencer) createBlock(ctx context.Context) (returnValue bool) {
 	var txes types.Transactions
 	var queueItems []txQueueItem
 	var totalBatchSize int
 
+	defer s.publishRetryQueueDepth()
 	defer func() {
 		panicErr := recover()
 		if panicErr != nil {
@@ -441,7 +538,17 @@ func (s *Sequencer) createBlock(ctx context.Context) (returnValue bool) {
 		}
 	}()
 
+	if s.waitWhilePaused(ctx) {
+		return false
+	}
+
 	config := s.config()
+	select {
+	case bundle := <-s.bundleQueue:
+		// Bundles get a block of their own, ahead of the transaction queue
+		return s.sequenceBundle(bundle, config)
+	default:
+	}
 	for {
 		var queueItem txQueueItem
 		if s.txRetryQueue.Len() > 0 {
@@ -449,6 +556,8 @@ func (s *Sequencer) createBlock(ctx context.Context) (returnValue bool) {
 		} else if len(txes) == 0 {
 			select {
 			case queueItem = <-s.txQueue:
+			case bundle := <-s.bundleQueue:
+				return s.sequenceBundle(bundle, config)
 			case <-ctx.Done():
 				return false
 			}
@@ -493,38 +602,36 @@ func (s *Sequencer) createBlock(ctx context.Context) (returnValue bool) {
 		return false
 	}
 
-	timestamp := time.Now().Unix()
-	s.L1BlockAndTimeMutex.Lock()
-	l1Block := s.l1BlockNumber
-	l1Timestamp := s.l1Timestamp
-	s.L1BlockAndTimeMutex.Unlock()
+	if config.TxOrdering == TxOrderingFeePriority && len(queueItems) > 1 {
+		signer := types.LatestSigner(s.txStreamer.bc.Config())
+		baseFee := s.txStreamer.bc.CurrentBlock().BaseFee()
+		queueItems = orderByFeePriority(queueItems, signer, baseFee)
+		for i, item := range queueItems {
+			txes[i] = item.tx
+		}
+	}
 
-	if s.l1Reader != nil && (l1Block == 0 || math.Abs(float64(l1Timestamp)-float64(timestamp)) > config.MaxAcceptableTimestampDelta.Seconds()) {
-		log.Error(
-			"cannot sequence: unknown L1 block or L1 timestamp too far from local clock time",
-			"l1Block", l1Block,
-			"l1Timestamp", l1Timestamp,
-			"localTimestamp", timestamp,
-		)
+	header := s.nextMessageHeader(config)
+	if header == nil {
 		return false
 	}
 
-	header := &arbos.L1IncomingMessageHeader{
-		Kind:        arbos.L1MessageType_L2Message,
-		Poster:      l1pricing.BatchPosterAddress,
-		BlockNumber: l1Block,
-		Timestamp:   uint64(timestamp),
-		RequestId:   nil,
-		L1BaseFee:   nil,
+	for _, item := range queueItems {
+		s.pendingTxFeed.SendPending(item.tx)
 	}
 
 	s.nonceCache.Resize(config.NonceCacheSize) // Would probably be better in a config hook but this is basically free
 	s.nonceCache.BeginNewBlock()
+	conditionalOptions := make([]*arbutil.ConditionalOptions, 0, len(queueItems))
+	for _, item := range queueItems {
+		conditionalOptions = append(conditionalOptions, item.options)
+	}
 	hooks := &arbos.SequencingHooks{
-		PreTxFilter:            s.preTxFilter,
-		PostTxFilter:           s.postTxFilter,
-		DiscardInvalidTxsEarly: true,
-		TxErrors:               []error{},
+		PreTxFilter:             s.preTxFilter,
+		PostTxFilter:            s.postTxFilter,
+		DiscardInvalidTxsEarly:  true,
+		TxErrors:                []error{},
+		ConditionalOptionsForTx: conditionalOptions,
 	}
 	start := time.Now()
 	block, err := s.txStreamer.SequenceTransactions(header, txes, hooks)
@@ -540,6 +647,7 @@ func (s *Sequencer) createBlock(ctx context.Context) (returnValue bool) {
 		}
 		// try to add back to queue otherwise
 		for _, item := range queueItems {
+			s.pendingTxFeed.SendResult(item.tx, PendingTxStatusRequeued, err)
 			s.requeueOrFail(item, ErrNoSequencer)
 		}
 		return false
@@ -548,12 +656,14 @@ func (s *Sequencer) createBlock(ctx context.Context) (returnValue bool) {
 		if errors.Is(err, context.Canceled) {
 			// thread closed. We'll later try to forward these messages.
 			for _, item := range queueItems {
+				s.pendingTxFeed.SendResult(item.tx, PendingTxStatusRequeued, err)
 				s.requeueOrFail(item, err)
 			}
 			return true // don't return failure to avoid retrying immediately
 		}
 		log.Warn("error sequencing transactions", "err", err)
 		for _, queueItem := range queueItems {
+			s.pendingTxFeed.SendResult(queueItem.tx, PendingTxStatusRejected, err)
 			queueItem.returnResult(err)
 		}
 		return false
@@ -574,19 +684,99 @@ func (s *Sequencer) createBlock(ctx context.Context) (returnValue bool) {
 			// There's not enough gas left in the block for this tx.
 			if madeBlock && !errors.Is(err, arbos.ErrMaxGasLimitReached) {
 				// There was already an earlier tx in the block; retry in a fresh block.
+				s.pendingTxFeed.SendResult(queueItem.tx, PendingTxStatusRequeued, err)
 				s.txRetryQueue.Push(queueItem)
 				continue
 			}
 		}
+		// Conditional transactions aren't held, as their conditions are only meaningful for the current state.
+		if errors.Is(err, core.ErrNonceTooHigh) && queueItem.options == nil && config.NonceHoldingPool.Enable && s.holdFutureNonceTx(queueItem.tx, &config.NonceHoldingPool) {
+			// The transaction was accepted into the holding pool, and will be sequenced once its nonce is reached,
+			// so it's submitted successfully, and only the pending tx feed reports it as held.
+			s.pendingTxFeed.SendResult(queueItem.tx, PendingTxStatusHeld, err)
+			queueItem.returnResult(nil)
+			continue
+		}
 		if errors.Is(err, core.ErrIntrinsicGas) {
 			// Strip additional information, as it's incorrect due to L1 data gas.
 			err = core.ErrIntrinsicGas
 		}
+		if err == nil && block != nil {
+			s.pendingTxFeed.SendIncluded(queueItem.tx, block)
+		} else {
+			s.pendingTxFeed.SendResult(queueItem.tx, PendingTxStatusRejected, err)
+		}
 		queueItem.returnResult(err)
 	}
+	if block != nil {
+		s.promoteHeldTxs(block)
+	}
 	return madeBlock
 }
 
+func (s *Sequencer) holdFutureNonceTx(tx *types.Transaction, config *NonceHoldingPoolConfig) bool {
+	signer := types.LatestSigner(s.txStreamer.bc.Config())
+	sender, err := types.Sender(signer, tx)
+	if err != nil {
+		return false
+	}
+	return s.noncePool.Hold(sender, tx, config)
+}
+
+// promoteHeldTxs moves held transactions whose nonce gap has been filled by block into the retry queue,
+// so that they're sequenced in the next block.
+func (s *Sequencer) promoteHeldTxs(block *types.Block) {
+	senders := s.noncePool.Senders()
+	if len(senders) == 0 {
+		return
+	}
+	statedb, err := s.txStreamer.bc.StateAt(block.Root())
+	if err != nil {
+		log.Warn("failed to get state to promote held transactions", "block", block.Hash(), "err", err)
+		return
+	}
+	for _, sender := range senders {
+		for _, tx := range s.noncePool.Promote(sender, statedb.GetNonce(sender)) {
+			// Nobody is waiting on the result, as the submitter was told the transaction was held.
+			// The context must outlive the sequencer, as the retry queue is forwarded while shutting down.
+			s.txRetryQueue.Push(txQueueItem{
+				tx:         tx,
+				resultChan: make(chan error, 1),
+				ctx:        context.Background(),
+			})
+		}
+	}
+}
+
+// nextMessageHeader returns the header for the next sequenced message,
+// or nil if the L1 block or timestamp can't currently be trusted.
+func (s *Sequencer) nextMessageHeader(config *SequencerConfig) *arbos.L1IncomingMessageHeader {
+	timestamp := time.Now().Unix()
+	s.L1BlockAndTimeMutex.Lock()
+	l1Block := s.l1BlockNumber
+	l1Timestamp := s.l1Timestamp
+	s.L1BlockAndTimeMutex.Unlock()
+
+	if s.l1Reader != nil && (l1Block == 0 || math.Abs(float64(l1Timestamp)-float64(timestamp)) > config.MaxAcceptableTimestampDelta.Seconds()) {
+		log.Error(
+			"cannot sequence: unknown L1 block or L1 timestamp too far from local clock time",
+			"l1Block", l1Block,
+			"l1Timestamp", l1Timestamp,
+			"localTimestamp", timestamp,
+		)
+		return nil
+	}
+
+	return &arbos.L1IncomingMessageHeader{
+		Kind:        arbos.L1MessageType_L2Message,
+		Poster:      l1pricing.BatchPosterAddress,
+		BlockNumber: l1Block,
+		Timestamp:   uint64(timestamp),
+		RequestId:   nil,
+		L1BaseFee:   nil,
+	}
+}
+
 func (s *Sequencer) updateLatestL1Block(header *types.Header) {
 	s.L1BlockAndTimeMutex.Lock()
 	defer s.L1BlockAndTimeMutex.Unlock()
@@ -636,6 +826,29 @@ func (s *Sequencer) Start(ctxIn context.Context) error {
 
 	}
 
+	s.CallIteratively(func(ctx context.Context) time.Duration {
+		config := &s.config().TxFilter
+		if err := s.txFilter.Reload(config.RulesFile); err != nil {
+			log.Error("failed to reload sequencer tx filter rules, keeping previous rules", "path", config.RulesFile, "err", err)
+		}
+		if config.ReloadInterval <= 0 {
+			return DefaultTxFilterConfig.ReloadInterval
+		}
+		return config.ReloadInterval
+	})
+
+	// The nonce holding pool can be enabled by a hot reload, and its transactions still expire after it's disabled
+	s.CallIteratively(func(ctx context.Context) time.Duration {
+		if !s.config().NonceHoldingPool.Enable && s.noncePool.Len() == 0 {
+			return time.Second
+		}
+		evicted := s.noncePool.Evict(time.Now())
+		if evicted > 0 {
+			log.Info("dropped expired transactions from nonce holding pool", "count", evicted)
+		}
+		return time.Second
+	})
+
 	s.CallIteratively(func(ctx context.Context) time.Duration {
 		nextBlock := time.Now().Add(s.config().MaxBlockSpeed)
 		madeBlock := s.createBlock(ctx)
@@ -653,11 +866,11 @@ func (s *Sequencer) Start(ctxIn context.Context) error {
 
 func (s *Sequencer) StopAndWait() {
 	s.StopWaiter.StopAndWait()
-	if s.txRetryQueue.Len() == 0 && len(s.txQueue) == 0 {
+	if s.txRetryQueue.Len() == 0 && len(s.txQueue) == 0 && s.noncePool.Len() == 0 {
 		return
 	}
 	// this usually means that coordinator's safe-shutdown-delay is too low
-	log.Warn("sequencer has queued items while shutting down", "txQueue", len(s.txQueue), "retryQueue", s.txRetryQueue.Len())
+	log.Warn("sequencer has queued items while shutting down", "txQueue", len(s.txQueue), "retryQueue", s.txRetryQueue.Len(), "noncePool", s.noncePool.Len())
 	forwarder := s.GetForwarder()
 	if forwarder != nil {
 	emptyqueues:
@@ -675,11 +888,17 @@ func (s *Sequencer) StopAndWait() {
 					break emptyqueues
 				}
 			}
-			err := forwarder.PublishTransaction(item.ctx, item.tx)
+			err := forwarder.PublishTransaction(item.ctx, item.tx, item.options)
 			if err != nil {
 				log.Warn("failed to forward transaction while shutting down", "source", source, "err", err)
 			}
 
 		}
+		for _, tx := range s.noncePool.Drain() {
+			err := forwarder.PublishTransaction(context.Background(), tx, nil)
+			if err != nil {
+				log.Warn("failed to forward held transaction while shutting down", "err", err)
+			}
+		}
 	}
 }
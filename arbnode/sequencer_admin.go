@@ -0,0 +1,203 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+var sequencerStateGauge = metrics.NewRegisteredGauge("arb/sequencer/state", nil)
+
+// States of the sequencer, as controlled through the admin API
+const (
+	SequencerStateRunning int32 = iota
+	// Block production is paused, but transactions are still accepted into the queue
+	SequencerStatePaused
+	// The queue is still sequenced, but new transactions and bundles are rejected
+	SequencerStateDraining
+)
+
+func sequencerStateName(state int32) string {
+	switch state {
+	case SequencerStateRunning:
+		return "running"
+	case SequencerStatePaused:
+		return "paused"
+	case SequencerStateDraining:
+		return "draining"
+	default:
+		return fmt.Sprintf("unknown(%v)", state)
+	}
+}
+
+var ErrSequencerDraining = errors.New("sequencer is draining its queue and not accepting transactions")
+
+type trackedQueueItem struct {
+	sender   common.Address
+	enqueued time.Time
+}
+
+// txQueueTracker keeps track of the transactions waiting in the sequencer's queues, for introspection.
+// Transactions, including those in bundles, are tracked from when they're queued until their result is returned.
+type txQueueTracker struct {
+	mutex  sync.Mutex
+	nextId uint64
+	items  map[uint64]trackedQueueItem
+}
+
+func newTxQueueTracker() *txQueueTracker {
+	return &txQueueTracker{
+		items: make(map[uint64]trackedQueueItem),
+	}
+}
+
+func (t *txQueueTracker) add(sender common.Address, now time.Time) uint64 {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.nextId++
+	t.items[t.nextId] = trackedQueueItem{
+		sender:   sender,
+		enqueued: now,
+	}
+	return t.nextId
+}
+
+func (t *txQueueTracker) remove(id uint64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	delete(t.items, id)
+}
+
+func (t *txQueueTracker) snapshot(now time.Time) (int, time.Duration, map[common.Address]int) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	var oldest time.Duration
+	perSender := make(map[common.Address]int)
+	for _, item := range t.items {
+		if age := now.Sub(item.enqueued); age > oldest {
+			oldest = age
+		}
+		perSender[item.sender]++
+	}
+	return len(t.items), oldest, perSender
+}
+
+func (s *Sequencer) state() int32 {
+	return atomic.LoadInt32(&s.adminState)
+}
+
+func (s *Sequencer) setState(state int32) {
+	previous := atomic.SwapInt32(&s.adminState, state)
+	sequencerStateGauge.Update(int64(state))
+	if previous != state {
+		log.Info("sequencer state changed", "from", sequencerStateName(previous), "to", sequencerStateName(state))
+	}
+	if state != SequencerStatePaused {
+		select {
+		case s.resumeNotifier <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Paused returns whether block production is paused.
+// A paused sequencer shouldn't be the chosen sequencer, so the coordinator releases its lockout.
+func (s *Sequencer) Paused() bool {
+	return s.state() == SequencerStatePaused
+}
+
+// Pause stops block production, while still accepting transactions into the queue
+func (s *Sequencer) Pause() {
+	s.setState(SequencerStatePaused)
+}
+
+// Drain keeps sequencing the queued transactions, but rejects new ones
+func (s *Sequencer) Drain() {
+	s.setState(SequencerStateDraining)
+}
+
+func (s *Sequencer) Resume() {
+	s.setState(SequencerStateRunning)
+}
+
+// waitWhilePaused waits until the sequencer may be resumed, returning whether it's still paused.
+// Paused sequencers still forward their queue if they have a forwarder, as they're no longer the chosen sequencer.
+func (s *Sequencer) waitWhilePaused(ctx context.Context) bool {
+	if !s.Paused() || s.GetForwarder() != nil {
+		return false
+	}
+	select {
+	case <-s.resumeNotifier:
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+	}
+	return s.Paused()
+}
+
+type SequencerQueueStatus struct {
+	State            string                 `json:"state"`
+	QueueDepth       int                    `json:"queueDepth"`
+	RetryQueueDepth  int                    `json:"retryQueueDepth"`
+	BundleQueueDepth int                    `json:"bundleQueueDepth"`
+	HeldTxs          int                    `json:"heldTxs"`
+	Tracked          int                    `json:"tracked"`
+	OldestItemAge    string                 `json:"oldestItemAge"`
+	PerSender        map[common.Address]int `json:"perSender"`
+	ForwardTarget    string                 `json:"forwardTarget,omitempty"`
+	Chosen           *bool                  `json:"chosen,omitempty"`
+}
+
+func (s *Sequencer) QueueStatus() *SequencerQueueStatus {
+	tracked, oldest, perSender := s.queueTracker.snapshot(time.Now())
+	status := &SequencerQueueStatus{
+		State:            sequencerStateName(s.state()),
+		QueueDepth:       len(s.txQueue),
+		RetryQueueDepth:  int(atomic.LoadInt64(&s.retryQueueDepth)),
+		BundleQueueDepth: len(s.bundleQueue),
+		HeldTxs:          s.noncePool.Len(),
+		Tracked:          tracked,
+		OldestItemAge:    oldest.String(),
+		PerSender:        perSender,
+		ForwardTarget:    s.ForwardTarget(),
+	}
+	if s.txStreamer.coordinator != nil {
+		chosen := s.txStreamer.coordinator.CurrentlyChosen()
+		status.Chosen = &chosen
+	}
+	return status
+}
+
+// SequencerAdminAPI lets operators control the sequencer for maintenance.
+// It isn't public, so it's only served when explicitly enabled.
+type SequencerAdminAPI struct {
+	sequencer *Sequencer
+}
+
+func (a *SequencerAdminAPI) Pause(ctx context.Context) error {
+	a.sequencer.Pause()
+	return nil
+}
+
+func (a *SequencerAdminAPI) Drain(ctx context.Context) error {
+	a.sequencer.Drain()
+	return nil
+}
+
+func (a *SequencerAdminAPI) Resume(ctx context.Context) error {
+	a.sequencer.Resume()
+	return nil
+}
+
+func (a *SequencerAdminAPI) QueueStatus(ctx context.Context) (*SequencerQueueStatus, error) {
+	return a.sequencer.QueueStatus(), nil
+}
@@ -0,0 +1,96 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestTxQueueTracker(t *testing.T) {
+	tracker := newTxQueueTracker()
+	start := time.Now()
+	alice := common.Address{1}
+	bob := common.Address{2}
+
+	first := tracker.add(alice, start)
+	tracker.add(alice, start.Add(time.Second))
+	tracker.add(bob, start.Add(2*time.Second))
+
+	count, oldest, perSender := tracker.snapshot(start.Add(5 * time.Second))
+	if count != 3 || oldest != 5*time.Second {
+		Fail(t, "unexpected queue snapshot", count, oldest)
+	}
+	if perSender[alice] != 2 || perSender[bob] != 1 {
+		Fail(t, "unexpected per-sender counts", perSender)
+	}
+
+	tracker.remove(first)
+	count, oldest, perSender = tracker.snapshot(start.Add(5 * time.Second))
+	if count != 2 || oldest != 4*time.Second || perSender[alice] != 1 {
+		Fail(t, "unexpected queue snapshot after removal", count, oldest, perSender)
+	}
+}
+
+func TestSequencerBundleQueueStatus(t *testing.T) {
+	ownerKey, err := crypto.GenerateKey()
+	Require(t, err)
+	owner := crypto.PubkeyToAddress(ownerKey.PublicKey)
+	streamer, _, bc := NewTransactionStreamerForTest(t, owner)
+	sequencer, err := NewSequencer(streamer, nil, func() *SequencerConfig { return &TestSequencerConfig })
+	Require(t, err)
+
+	signer := types.LatestSigner(bc.Config())
+	var txs types.Transactions
+	for nonce := uint64(0); nonce < 2; nonce++ {
+		tx, err := types.SignNewTx(ownerKey, signer, &types.DynamicFeeTx{
+			ChainID:   bc.Config().ChainID,
+			Nonce:     nonce,
+			GasFeeCap: big.NewInt(1e9),
+			Gas:       1_000_000,
+			To:        &common.Address{1},
+		})
+		Require(t, err)
+		txs = append(txs, tx)
+	}
+
+	sequencer.Drain()
+	err = sequencer.PublishBundle(context.Background(), txs, nil)
+	if !errors.Is(err, ErrSequencerDraining) {
+		Fail(t, "draining sequencer didn't reject bundle", err)
+	}
+	sequencer.Resume()
+
+	// The sequencer isn't started, so the bundle waits in its queue until the context is canceled
+	ctx, cancel := context.WithCancel(context.Background())
+	published := make(chan error, 1)
+	go func() {
+		published <- sequencer.PublishBundle(ctx, txs, nil)
+	}()
+	var status *SequencerQueueStatus
+	for i := 0; ; i++ {
+		status = sequencer.QueueStatus()
+		if status.BundleQueueDepth == 1 {
+			break
+		}
+		if i == 100 {
+			Fail(t, "bundle wasn't queued", status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if status.Tracked != 2 || status.PerSender[owner] != 2 {
+		Fail(t, "bundle transactions weren't tracked", status)
+	}
+	cancel()
+	if err := <-published; !errors.Is(err, context.Canceled) {
+		Fail(t, "unexpected result publishing bundle", err)
+	}
+}
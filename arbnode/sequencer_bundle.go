@@ -0,0 +1,326 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/pkg/errors"
+	flag "github.com/spf13/pflag"
+
+	"github.com/ethereum/go-ethereum/arbitrum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/offchainlabs/nitro/arbos"
+	"github.com/offchainlabs/nitro/arbos/arbosState"
+)
+
+var (
+	bundleSequencedCounter = metrics.NewRegisteredCounter("arb/sequencer/bundle/sequenced", nil)
+	bundleFailedCounter    = metrics.NewRegisteredCounter("arb/sequencer/bundle/failed", nil)
+)
+
+type BundleConfig struct {
+	QueueSize         int    `koanf:"queue-size"`
+	MaxTxs            int    `koanf:"max-txs" reload:"hot"`
+	MaxTargetBlockAge uint64 `koanf:"max-target-block-age" reload:"hot"`
+}
+
+var DefaultBundleConfig = BundleConfig{
+	QueueSize:         64,
+	MaxTxs:            16,
+	MaxTargetBlockAge: 10,
+}
+
+var TestBundleConfig = BundleConfig{
+	QueueSize:         16,
+	MaxTxs:            16,
+	MaxTargetBlockAge: 10,
+}
+
+func BundleConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Int(prefix+".queue-size", DefaultBundleConfig.QueueSize, "size of the pending bundle queue")
+	f.Int(prefix+".max-txs", DefaultBundleConfig.MaxTxs, "maximum number of transactions in a bundle (0 disables bundles)")
+	f.Uint64(prefix+".max-target-block-age", DefaultBundleConfig.MaxTargetBlockAge, "maximum number of blocks past its target block a bundle may still be sequenced in")
+}
+
+var (
+	ErrBundleFailed       = errors.New("bundle failed")
+	ErrBundleExpired      = errors.New("bundle target block too old")
+	ErrBundleTooLarge     = errors.New("bundle too large")
+	ErrBundlesUnsupported = errors.New("bundles are not supported by this node")
+)
+
+// BundlePublisher is implemented by the transaction publishers that can pass on bundles
+type BundlePublisher interface {
+	PublishBundle(ctx context.Context, txs types.Transactions, targetBlock *uint64) error
+}
+
+// BundleArgs is the JSON-RPC representation of a bundle.
+// If TargetBlock is set, the bundle is dropped once it can't be sequenced
+// within the sequencer's maximum target block age of that block.
+type BundleArgs struct {
+	Txs         []hexutil.Bytes `json:"txs"`
+	TargetBlock *hexutil.Uint64 `json:"targetBlock,omitempty"`
+}
+
+func NewBundleArgs(txs types.Transactions, targetBlock *uint64) (*BundleArgs, error) {
+	args := &BundleArgs{}
+	for _, tx := range txs {
+		txBytes, err := tx.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		args.Txs = append(args.Txs, txBytes)
+	}
+	if targetBlock != nil {
+		target := hexutil.Uint64(*targetBlock)
+		args.TargetBlock = &target
+	}
+	return args, nil
+}
+
+func (a *BundleArgs) Decode() (types.Transactions, *uint64, error) {
+	txs := make(types.Transactions, 0, len(a.Txs))
+	for i, txBytes := range a.Txs {
+		tx := new(types.Transaction)
+		if err := tx.UnmarshalBinary(txBytes); err != nil {
+			return nil, nil, fmt.Errorf("error decoding bundle transaction %v: %w", i, err)
+		}
+		txs = append(txs, tx)
+	}
+	var targetBlock *uint64
+	if a.TargetBlock != nil {
+		target := uint64(*a.TargetBlock)
+		targetBlock = &target
+	}
+	return txs, targetBlock, nil
+}
+
+type bundleQueueItem struct {
+	txs            types.Transactions
+	targetBlock    *uint64
+	resultChan     chan<- error
+	returnedResult bool
+	ctx            context.Context
+	tracker        *txQueueTracker
+	trackingIds    []uint64
+}
+
+func (i *bundleQueueItem) untrack() {
+	if i.tracker != nil {
+		for _, id := range i.trackingIds {
+			i.tracker.remove(id)
+		}
+	}
+}
+
+func (i *bundleQueueItem) returnResult(err error) {
+	if i.returnedResult {
+		log.Error("attempting to return result to already finished bundle", "err", err)
+		return
+	}
+	i.returnedResult = true
+	i.untrack()
+	i.resultChan <- err
+	close(i.resultChan)
+}
+
+// PublishBundle sequences txs together in a block of their own, in order.
+// If any of them is invalid or reverts, none of them are sequenced.
+func (s *Sequencer) PublishBundle(parentCtx context.Context, txs types.Transactions, targetBlock *uint64) error {
+	config := s.config()
+	if len(txs) == 0 {
+		return fmt.Errorf("%w: empty bundle", ErrBundleFailed)
+	}
+	if len(txs) > config.Bundle.MaxTxs {
+		return fmt.Errorf("%w: %v transactions, maximum %v", ErrBundleTooLarge, len(txs), config.Bundle.MaxTxs)
+	}
+
+	forwarder := s.GetForwarder()
+	if forwarder != nil {
+		err := forwarder.PublishBundle(parentCtx, txs, targetBlock)
+		if !errors.Is(err, ErrNoSequencer) {
+			return err
+		}
+	}
+
+	if s.state() == SequencerStateDraining {
+		return ErrSequencerDraining
+	}
+
+	for _, tx := range txs {
+		if err := s.checkSubmittedTx(tx); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancelFunc := s.ctxWithQueueTimeout(parentCtx)
+	defer cancelFunc()
+
+	resultChan := make(chan error, 1)
+	item := bundleQueueItem{
+		txs:         txs,
+		targetBlock: targetBlock,
+		resultChan:  resultChan,
+		ctx:         ctx,
+		tracker:     s.queueTracker,
+	}
+	signer := types.LatestSigner(s.txStreamer.bc.Config())
+	now := time.Now()
+	for _, tx := range txs {
+		// As with single transactions, the sender is only used for queue introspection
+		sender, _ := types.Sender(signer, tx)
+		item.trackingIds = append(item.trackingIds, s.queueTracker.add(sender, now))
+	}
+	select {
+	case s.bundleQueue <- item:
+	case <-ctx.Done():
+		item.untrack()
+		return ctx.Err()
+	}
+
+	select {
+	case res := <-resultChan:
+		return res
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// sequenceBundle attempts to sequence a bundle in a block of its own.
+// It's called from createBlock, and returns whether a block was made.
+func (s *Sequencer) sequenceBundle(item bundleQueueItem, config *SequencerConfig) (returnValue bool) {
+	defer func() {
+		panicErr := recover()
+		if panicErr != nil {
+			log.Error("sequencer bundle block creation panicked", "panic", panicErr, "backtrace", string(debug.Stack()))
+			if !item.returnedResult {
+				item.returnResult(sequencerInternalError)
+			}
+			returnValue = true
+		}
+	}()
+
+	if err := item.ctx.Err(); err != nil {
+		item.returnResult(err)
+		return false
+	}
+	if item.targetBlock != nil {
+		nextBlock := s.txStreamer.bc.CurrentBlock().NumberU64() + 1
+		if nextBlock > *item.targetBlock+config.Bundle.MaxTargetBlockAge {
+			item.returnResult(fmt.Errorf("%w: target block %v, next block %v", ErrBundleExpired, *item.targetBlock, nextBlock))
+			return false
+		}
+	}
+	forwarder := s.GetForwarder()
+	if forwarder != nil {
+		item.returnResult(forwarder.PublishBundle(item.ctx, item.txs, item.targetBlock))
+		return false
+	}
+
+	totalSize := 0
+	for _, tx := range item.txs {
+		txBytes, err := tx.MarshalBinary()
+		if err != nil {
+			item.returnResult(err)
+			return false
+		}
+		totalSize += len(txBytes)
+	}
+	if totalSize > config.MaxTxDataSize {
+		item.returnResult(core.ErrOversizedData)
+		return false
+	}
+
+	header := s.nextMessageHeader(config)
+	if header == nil {
+		item.returnResult(ErrNoSequencer)
+		return false
+	}
+
+	for _, tx := range item.txs {
+		s.pendingTxFeed.SendPending(tx)
+	}
+	sendRejected := func(err error) {
+		for _, tx := range item.txs {
+			s.pendingTxFeed.SendResult(tx, PendingTxStatusRejected, err)
+		}
+	}
+
+	s.nonceCache.Resize(config.NonceCacheSize)
+	s.nonceCache.BeginNewBlock()
+	hooks := &arbos.SequencingHooks{
+		PreTxFilter: s.preTxFilter,
+		PostTxFilter: func(header *types.Header, arbState *arbosState.ArbosState, tx *types.Transaction, sender common.Address, dataGas uint64, result *core.ExecutionResult) error {
+			if result.Err != nil {
+				return arbitrum.NewRevertReason(result)
+			}
+			return s.postTxFilter(header, arbState, tx, sender, dataGas, result)
+		},
+		DiscardInvalidTxsEarly: true,
+		TxErrors:               []error{},
+	}
+	hooks.BlockFilter = func(_ *types.Header, _ *state.StateDB, _ types.Transactions, _ types.Receipts) error {
+		if len(hooks.TxErrors) != len(item.txs) {
+			return fmt.Errorf("%w: unexpected number of error results: %v vs number of txes %v", ErrBundleFailed, len(hooks.TxErrors), len(item.txs))
+		}
+		for i, err := range hooks.TxErrors {
+			if err != nil {
+				return fmt.Errorf("%w: transaction %v (%v): %v", ErrBundleFailed, i, item.txs[i].Hash(), err)
+			}
+		}
+		return nil
+	}
+
+	start := time.Now()
+	block, err := s.txStreamer.SequenceTransactions(header, item.txs, hooks)
+	blockCreationTimer.Update(time.Since(start))
+	if errors.Is(err, ErrRetrySequencer) {
+		// we changed roles, so forward the bundle if we have where to
+		forwarder := s.GetForwarder()
+		if forwarder != nil {
+			item.returnResult(forwarder.PublishBundle(item.ctx, item.txs, item.targetBlock))
+		} else {
+			sendRejected(ErrNoSequencer)
+			item.returnResult(ErrNoSequencer)
+		}
+		return false
+	}
+	if err != nil {
+		if errors.Is(err, ErrBundleFailed) {
+			bundleFailedCounter.Inc(1)
+		} else {
+			log.Warn("error sequencing bundle", "err", err)
+		}
+		sendRejected(err)
+		item.returnResult(err)
+		return false
+	}
+	if block == nil {
+		bundleFailedCounter.Inc(1)
+		err = fmt.Errorf("%w: no block produced", ErrBundleFailed)
+		sendRejected(err)
+		item.returnResult(err)
+		return false
+	}
+
+	successfulBlocksCounter.Inc(1)
+	bundleSequencedCounter.Inc(1)
+	s.nonceCache.Finalize(block)
+	for _, tx := range item.txs {
+		s.pendingTxFeed.SendIncluded(tx, block)
+	}
+	s.promoteHeldTxs(block)
+	item.returnResult(nil)
+	return true
+}
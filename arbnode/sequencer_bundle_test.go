@@ -0,0 +1,103 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func sequenceTestBundle(t *testing.T, sequencer *Sequencer, txs types.Transactions) error {
+	t.Helper()
+	resultChan := make(chan error, 1)
+	sequencer.sequenceBundle(bundleQueueItem{
+		txs:        txs,
+		resultChan: resultChan,
+		ctx:        context.Background(),
+	}, sequencer.config())
+	return <-resultChan
+}
+
+func TestSequenceBundleAllOrNothing(t *testing.T) {
+	ownerKey, err := crypto.GenerateKey()
+	Require(t, err)
+	owner := crypto.PubkeyToAddress(ownerKey.PublicKey)
+	streamer, _, bc := NewTransactionStreamerForTest(t, owner)
+	sequencer, err := NewSequencer(streamer, nil, func() *SequencerConfig { return &TestSequencerConfig })
+	Require(t, err)
+
+	signer := types.LatestSigner(bc.Config())
+	gasFeeCap := new(big.Int).Mul(bc.CurrentBlock().BaseFee(), common.Big2)
+	newTx := func(key *ecdsa.PrivateKey, nonce uint64) *types.Transaction {
+		tx, err := types.SignNewTx(key, signer, &types.DynamicFeeTx{
+			ChainID:   bc.Config().ChainID,
+			Nonce:     nonce,
+			GasFeeCap: gasFeeCap,
+			Gas:       1_000_000,
+			To:        &common.Address{1},
+			Value:     common.Big1,
+		})
+		Require(t, err)
+		return tx
+	}
+	first := newTx(ownerKey, 0)
+	last := newTx(ownerKey, 1)
+	// the middle transaction skips a nonce, so it fails
+	middle := newTx(ownerKey, 5)
+
+	events, sub := sequencer.PendingTxFeed().Subscribe(16)
+	defer sub.Unsubscribe()
+	expectEvents := func(status string, txs ...*types.Transaction) {
+		t.Helper()
+		for _, tx := range txs {
+			event := <-events
+			if event.Hash != tx.Hash() || event.Status != status {
+				Fail(t, "expected", status, "event for", tx.Hash(), "but got", event)
+			}
+		}
+	}
+
+	startBlock := bc.CurrentBlock().NumberU64()
+	err = sequenceTestBundle(t, sequencer, types.Transactions{first, middle, last})
+	// the failing transaction's error is only described, as the bundle error wraps ErrBundleFailed
+	if !errors.Is(err, ErrBundleFailed) || !strings.Contains(err.Error(), core.ErrNonceTooHigh.Error()) {
+		Fail(t, "unexpected result sequencing bundle with a failing transaction", err)
+	}
+	if bc.CurrentBlock().NumberU64() != startBlock {
+		Fail(t, "failed bundle produced a block")
+	}
+	statedb, err := bc.StateAt(bc.CurrentBlock().Root())
+	Require(t, err)
+	if statedb.GetNonce(owner) != 0 {
+		Fail(t, "transactions from the failed bundle were executed")
+	}
+	expectEvents(PendingTxStatusPending, first, middle, last)
+	expectEvents(PendingTxStatusRejected, first, middle, last)
+
+	Require(t, sequenceTestBundle(t, sequencer, types.Transactions{first, last}))
+	block := bc.CurrentBlock()
+	if block.NumberU64() != startBlock+1 {
+		Fail(t, "bundle wasn't sequenced in a single block", block.NumberU64())
+	}
+	var hashes []common.Hash
+	for _, tx := range block.Transactions() {
+		if tx.Type() != types.ArbitrumInternalTxType {
+			hashes = append(hashes, tx.Hash())
+		}
+	}
+	if len(hashes) != 2 || hashes[0] != first.Hash() || hashes[1] != last.Hash() {
+		Fail(t, "unexpected transactions in bundle block", hashes)
+	}
+	expectEvents(PendingTxStatusPending, first, last)
+	expectEvents(PendingTxStatusIncluded, first, last)
+}
@@ -0,0 +1,187 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+var (
+	noncePoolSizeGauge       = metrics.NewRegisteredGauge("arb/sequencer/noncepool/size", nil)
+	noncePoolParkedCounter   = metrics.NewRegisteredCounter("arb/sequencer/noncepool/parked", nil)
+	noncePoolPromotedCounter = metrics.NewRegisteredCounter("arb/sequencer/noncepool/promoted", nil)
+	noncePoolEvictedCounter  = metrics.NewRegisteredCounter("arb/sequencer/noncepool/evicted", nil)
+	noncePoolRejectedCounter = metrics.NewRegisteredCounter("arb/sequencer/noncepool/rejected", nil)
+)
+
+type NonceHoldingPoolConfig struct {
+	Enable       bool          `koanf:"enable"`
+	MaxPerSender int           `koanf:"max-per-sender" reload:"hot"`
+	MaxTotal     int           `koanf:"max-total" reload:"hot"`
+	Timeout      time.Duration `koanf:"timeout" reload:"hot"`
+}
+
+var DefaultNonceHoldingPoolConfig = NonceHoldingPoolConfig{
+	Enable:       false,
+	MaxPerSender: 16,
+	MaxTotal:     1024,
+	Timeout:      time.Minute,
+}
+
+var TestNonceHoldingPoolConfig = NonceHoldingPoolConfig{
+	Enable:       false,
+	MaxPerSender: 4,
+	MaxTotal:     64,
+	Timeout:      time.Second * 5,
+}
+
+func NonceHoldingPoolConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", DefaultNonceHoldingPoolConfig.Enable, "hold transactions with a nonce above the sender's state nonce until the gap is filled, instead of rejecting them (also enable it on nodes forwarding to such a sequencer, so full validation pre-checking lets them through)")
+	f.Int(prefix+".max-per-sender", DefaultNonceHoldingPoolConfig.MaxPerSender, "maximum number of transactions held for a single sender")
+	f.Int(prefix+".max-total", DefaultNonceHoldingPoolConfig.MaxTotal, "maximum number of transactions held across all senders")
+	f.Duration(prefix+".timeout", DefaultNonceHoldingPoolConfig.Timeout, "maximum amount of time a transaction is held before it's dropped")
+}
+
+type heldTx struct {
+	tx     *types.Transaction
+	expiry time.Time
+}
+
+// nonceHoldingPool parks transactions whose nonce is ahead of their sender's
+// state nonce, so they can be sequenced once the earlier nonces land.
+type nonceHoldingPool struct {
+	mutex    sync.Mutex
+	bySender map[common.Address]map[uint64]heldTx
+	total    int
+}
+
+func newNonceHoldingPool() *nonceHoldingPool {
+	return &nonceHoldingPool{
+		bySender: make(map[common.Address]map[uint64]heldTx),
+	}
+}
+
+// Hold parks tx, replacing any held transaction from the same sender with the same nonce.
+// It returns false if the pool limits don't allow the transaction to be held.
+func (p *nonceHoldingPool) Hold(sender common.Address, tx *types.Transaction, config *NonceHoldingPoolConfig) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	held := p.bySender[sender]
+	if _, replacing := held[tx.Nonce()]; !replacing {
+		if len(held) >= config.MaxPerSender || p.total >= config.MaxTotal {
+			noncePoolRejectedCounter.Inc(1)
+			return false
+		}
+		if held == nil {
+			held = make(map[uint64]heldTx)
+			p.bySender[sender] = held
+		}
+		p.total++
+	}
+	held[tx.Nonce()] = heldTx{
+		tx:     tx,
+		expiry: time.Now().Add(config.Timeout),
+	}
+	noncePoolParkedCounter.Inc(1)
+	noncePoolSizeGauge.Update(int64(p.total))
+	return true
+}
+
+func (p *nonceHoldingPool) Len() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.total
+}
+
+func (p *nonceHoldingPool) Senders() []common.Address {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	senders := make([]common.Address, 0, len(p.bySender))
+	for sender := range p.bySender {
+		senders = append(senders, sender)
+	}
+	return senders
+}
+
+// Promote removes and returns the sender's held transactions that directly follow
+// stateNonce, in nonce order. Held transactions below stateNonce can never be
+// included anymore, so they're dropped.
+func (p *nonceHoldingPool) Promote(sender common.Address, stateNonce uint64) []*types.Transaction {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	held := p.bySender[sender]
+	var promoted []*types.Transaction
+	for nonce := range held {
+		if nonce < stateNonce {
+			delete(held, nonce)
+			p.total--
+			noncePoolEvictedCounter.Inc(1)
+		}
+	}
+	for {
+		entry, ok := held[stateNonce]
+		if !ok {
+			break
+		}
+		delete(held, stateNonce)
+		p.total--
+		promoted = append(promoted, entry.tx)
+		stateNonce++
+	}
+	if len(held) == 0 {
+		delete(p.bySender, sender)
+	}
+	noncePoolPromotedCounter.Inc(int64(len(promoted)))
+	noncePoolSizeGauge.Update(int64(p.total))
+	return promoted
+}
+
+// Evict drops the held transactions which have expired by now, returning how many were dropped.
+func (p *nonceHoldingPool) Evict(now time.Time) int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	evicted := 0
+	for sender, held := range p.bySender {
+		for nonce, entry := range held {
+			if now.After(entry.expiry) {
+				delete(held, nonce)
+				evicted++
+			}
+		}
+		if len(held) == 0 {
+			delete(p.bySender, sender)
+		}
+	}
+	p.total -= evicted
+	noncePoolEvictedCounter.Inc(int64(evicted))
+	noncePoolSizeGauge.Update(int64(p.total))
+	return evicted
+}
+
+// Drain empties the pool, returning every held transaction in nonce order per sender.
+func (p *nonceHoldingPool) Drain() []*types.Transaction {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	var txs []*types.Transaction
+	for _, held := range p.bySender {
+		var senderTxs []*types.Transaction
+		for _, entry := range held {
+			senderTxs = append(senderTxs, entry.tx)
+		}
+		sort.Slice(senderTxs, func(i, j int) bool { return senderTxs[i].Nonce() < senderTxs[j].Nonce() })
+		txs = append(txs, senderTxs...)
+	}
+	p.bySender = make(map[common.Address]map[uint64]heldTx)
+	p.total = 0
+	noncePoolSizeGauge.Update(0)
+	return txs
+}
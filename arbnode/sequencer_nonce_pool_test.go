@@ -0,0 +1,113 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/offchainlabs/nitro/arbos/arbosState"
+)
+
+func TestNonceHoldingPool(t *testing.T) {
+	config := NonceHoldingPoolConfig{
+		Enable:       true,
+		MaxPerSender: 3,
+		MaxTotal:     4,
+		Timeout:      time.Minute,
+	}
+	pool := newNonceHoldingPool()
+	alice := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	bob := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	newTx := func(nonce uint64) *types.Transaction {
+		return types.NewTx(&types.LegacyTx{Nonce: nonce})
+	}
+
+	for _, nonce := range []uint64{5, 3, 2} {
+		if !pool.Hold(alice, newTx(nonce), &config) {
+			Fail(t, "failed to hold alice's tx with nonce", nonce)
+		}
+	}
+	if pool.Hold(alice, newTx(7), &config) {
+		Fail(t, "held more than the per sender limit")
+	}
+	// Replacing an already held nonce doesn't count against the limit
+	if !pool.Hold(alice, newTx(5), &config) {
+		Fail(t, "failed to replace alice's tx with nonce 5")
+	}
+	if !pool.Hold(bob, newTx(10), &config) {
+		Fail(t, "failed to hold bob's tx")
+	}
+	if pool.Hold(bob, newTx(11), &config) {
+		Fail(t, "held more than the total limit")
+	}
+
+	// Nonce 1 hasn't landed yet, so nothing is promoted
+	if promoted := pool.Promote(alice, 1); len(promoted) != 0 {
+		Fail(t, "promoted", len(promoted), "txs with an unfilled nonce gap")
+	}
+	promoted := pool.Promote(alice, 2)
+	if len(promoted) != 2 || promoted[0].Nonce() != 2 || promoted[1].Nonce() != 3 {
+		Fail(t, "unexpected promoted txs", promoted)
+	}
+	// Nonce 5 is still held, waiting on nonce 4
+	if pool.Len() != 2 {
+		Fail(t, "expected 2 held txs but have", pool.Len())
+	}
+	// Once the state nonce passes a held tx, it's dropped
+	if promoted := pool.Promote(alice, 6); len(promoted) != 0 {
+		Fail(t, "promoted stale txs", promoted)
+	}
+	if pool.Len() != 1 {
+		Fail(t, "expected 1 held tx but have", pool.Len())
+	}
+
+	if evicted := pool.Evict(time.Now()); evicted != 0 {
+		Fail(t, "evicted", evicted, "txs before they expired")
+	}
+	if evicted := pool.Evict(time.Now().Add(2 * time.Minute)); evicted != 1 {
+		Fail(t, "expected to evict 1 tx but evicted", evicted)
+	}
+	if pool.Len() != 0 || len(pool.Senders()) != 0 {
+		Fail(t, "pool not empty after eviction")
+	}
+}
+
+func TestPreCheckTxAllowsFutureNonce(t *testing.T) {
+	ownerKey, err := crypto.GenerateKey()
+	Require(t, err)
+	_, _, bc := NewTransactionStreamerForTest(t, crypto.PubkeyToAddress(ownerKey.PublicKey))
+	header := bc.CurrentBlock().Header()
+	statedb, err := bc.StateAt(header.Root)
+	Require(t, err)
+	arbState, err := arbosState.OpenSystemArbosState(statedb, nil, true)
+	Require(t, err)
+
+	signer := types.LatestSigner(bc.Config())
+	for _, nonce := range []uint64{0, 5} {
+		tx, err := types.SignNewTx(ownerKey, signer, &types.DynamicFeeTx{
+			ChainID:   bc.Config().ChainID,
+			Nonce:     nonce,
+			GasFeeCap: new(big.Int).Mul(header.BaseFee, common.Big2),
+			Gas:       1_000_000,
+			To:        &common.Address{},
+		})
+		Require(t, err)
+		// with the nonce holding pool enabled, future nonces are left for it, even at full validation
+		Require(t, PreCheckTx(bc.Config(), header, statedb, arbState, tx, nil, TxPreCheckerStrictnessFullValidation, true), "nonce", nonce)
+		err = PreCheckTx(bc.Config(), header, statedb, arbState, tx, nil, TxPreCheckerStrictnessFullValidation, false)
+		if nonce == 0 {
+			Require(t, err)
+		} else if !errors.Is(err, core.ErrNonceTooHigh) {
+			Fail(t, "full validation without the nonce holding pool didn't reject future nonce", nonce, err)
+		}
+	}
+}
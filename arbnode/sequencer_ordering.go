@@ -0,0 +1,147 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"container/heap"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/offchainlabs/nitro/util/arbmath"
+)
+
+var (
+	orderingReorderedCounter      = metrics.NewRegisteredCounter("arb/sequencer/ordering/reordered", nil)
+	orderingDisplacementHistogram = metrics.NewRegisteredHistogram("arb/sequencer/ordering/displacement", nil, metrics.NewExpDecaySample(1028, 0.015))
+)
+
+const (
+	TxOrderingFifo        = "fifo"
+	TxOrderingFeePriority = "fee-priority"
+)
+
+func validateTxOrdering(ordering string) error {
+	switch ordering {
+	case TxOrderingFifo, TxOrderingFeePriority:
+		return nil
+	default:
+		return fmt.Errorf("unknown sequencer tx ordering \"%v\" (expected \"%v\" or \"%v\")", ordering, TxOrderingFifo, TxOrderingFeePriority)
+	}
+}
+
+// effectiveTip returns the tip a transaction pays per gas on top of baseFee.
+// It's negative if the transaction's fee cap is below the base fee.
+func effectiveTip(tx *types.Transaction, baseFee *big.Int) *big.Int {
+	if baseFee == nil {
+		return tx.GasTipCap()
+	}
+	return arbmath.BigMin(tx.GasTipCap(), arbmath.BigSub(tx.GasFeeCap(), baseFee))
+}
+
+type orderingEntry struct {
+	item     txQueueItem
+	arrival  int
+	tip      *big.Int
+	group    int
+	groupPos int
+}
+
+// orderingHeap holds the next pending entry of every sender group,
+// ordered by descending tip and then by arrival.
+type orderingHeap []*orderingEntry
+
+func (h orderingHeap) Len() int { return len(h) }
+
+func (h orderingHeap) Less(i, j int) bool {
+	cmp := h[i].tip.Cmp(h[j].tip)
+	if cmp != 0 {
+		return cmp > 0
+	}
+	return h[i].arrival < h[j].arrival
+}
+
+func (h orderingHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *orderingHeap) Push(x interface{}) {
+	*h = append(*h, x.(*orderingEntry))
+}
+
+func (h *orderingHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	entry := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return entry
+}
+
+// orderByFeePriority groups the queue items by sender, keeps each sender's
+// transactions in nonce order, and repeatedly picks the sender whose next
+// transaction pays the highest effective tip. Ties keep arrival order.
+// Items whose sender can't be recovered are kept in their own group.
+func orderByFeePriority(items []txQueueItem, signer types.Signer, baseFee *big.Int) []txQueueItem {
+	if len(items) < 2 {
+		return items
+	}
+	var groups [][]*orderingEntry
+	groupBySender := make(map[common.Address]int)
+	for i, item := range items {
+		entry := &orderingEntry{
+			item:    item,
+			arrival: i,
+			tip:     effectiveTip(item.tx, baseFee),
+		}
+		sender, err := types.Sender(signer, item.tx)
+		group, found := groupBySender[sender]
+		if err != nil || !found {
+			group = len(groups)
+			groups = append(groups, nil)
+			if err == nil {
+				groupBySender[sender] = group
+			}
+		}
+		entry.group = group
+		groups[group] = append(groups[group], entry)
+	}
+	for _, group := range groups {
+		// Insertion sort by nonce, stable so equal nonces keep arrival order.
+		// Groups are short, and usually already sorted.
+		for i := 1; i < len(group); i++ {
+			for j := i; j > 0 && group[j].item.tx.Nonce() < group[j-1].item.tx.Nonce(); j-- {
+				group[j], group[j-1] = group[j-1], group[j]
+			}
+		}
+		for i, entry := range group {
+			entry.groupPos = i
+		}
+	}
+
+	pending := make(orderingHeap, 0, len(groups))
+	for _, group := range groups {
+		pending = append(pending, group[0])
+	}
+	heap.Init(&pending)
+	ordered := make([]txQueueItem, 0, len(items))
+	for pending.Len() > 0 {
+		entry := heap.Pop(&pending).(*orderingEntry)
+		newPos := len(ordered)
+		ordered = append(ordered, entry.item)
+		if newPos != entry.arrival {
+			orderingReorderedCounter.Inc(1)
+		}
+		displacement := int64(newPos - entry.arrival)
+		if displacement < 0 {
+			displacement = -displacement
+		}
+		orderingDisplacementHistogram.Update(displacement)
+		group := groups[entry.group]
+		if entry.groupPos+1 < len(group) {
+			heap.Push(&pending, group[entry.groupPos+1])
+		}
+	}
+	return ordered
+}
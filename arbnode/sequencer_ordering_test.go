@@ -0,0 +1,72 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestOrderByFeePriority(t *testing.T) {
+	chainConfig := params.ArbitrumDevTestChainConfig()
+	signer := types.LatestSignerForChainID(chainConfig.ChainID)
+	baseFee := big.NewInt(params.GWei)
+
+	var items []txQueueItem
+	addTx := func(t *testing.T, key string, nonce uint64, tipGwei int64) {
+		t.Helper()
+		privKey, err := crypto.ToECDSA(crypto.Keccak256([]byte(key)))
+		Require(t, err)
+		tx, err := types.SignNewTx(privKey, signer, &types.DynamicFeeTx{
+			ChainID:   chainConfig.ChainID,
+			Nonce:     nonce,
+			GasTipCap: big.NewInt(tipGwei * params.GWei),
+			GasFeeCap: big.NewInt((tipGwei + 1) * params.GWei),
+			Gas:       params.TxGas,
+			To:        &common.Address{},
+		})
+		Require(t, err)
+		items = append(items, txQueueItem{tx: tx})
+	}
+
+	// A low-tip spammer submits first, then two higher-tip senders.
+	addTx(t, "spam", 0, 1)
+	addTx(t, "spam", 1, 1)
+	addTx(t, "spam", 2, 1)
+	// This sender's transactions arrive out of nonce order, and the first has the lowest tip.
+	addTx(t, "bob", 1, 10)
+	addTx(t, "bob", 0, 2)
+	addTx(t, "alice", 0, 5)
+
+	ordered := orderByFeePriority(items, signer, baseFee)
+	if len(ordered) != len(items) {
+		Fail(t, "expected", len(items), "items but got", len(ordered))
+	}
+
+	// alice (tip 5) beats bob's first tx (tip 2), which must precede bob's nonce 1 (tip 10).
+	// Then bob's nonce 1 (tip 10) goes, and the spammer's transactions follow in nonce order.
+	expected := []int{5, 4, 3, 0, 1, 2}
+	for i, item := range ordered {
+		if item.tx != items[expected[i]].tx {
+			Fail(t, "unexpected tx at position", i, "got nonce", item.tx.Nonce(), "tip", item.tx.GasTipCap())
+		}
+	}
+
+	// Equal tips keep arrival order.
+	items = nil
+	addTx(t, "first", 0, 3)
+	addTx(t, "second", 0, 3)
+	addTx(t, "third", 0, 3)
+	ordered = orderByFeePriority(items, signer, baseFee)
+	for i := range ordered {
+		if ordered[i].tx != items[i].tx {
+			Fail(t, "equal tip transactions were reordered at position", i)
+		}
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
@@ -48,7 +49,7 @@ type TransactionStreamer struct {
 	fatalErrChan  chan<- error
 	configFetcher TransactionStreamerConfigFetcher
 
-	insertionMutex            sync.Mutex // cannot be acquired while reorgMutex or createBlocksMutex is held
+	insertionMutex            sync.Mutex // cannot be acquired while reorgMutex, createBlocksMutex, or reorgSendMutex is held
 	createBlocksMutex         sync.Mutex // cannot be acquired while reorgMutex is held
 	reorgMutex                sync.RWMutex
 	reorgPending              uint32 // atomic, indicates whether the reorgMutex is attempting to be acquired
@@ -71,20 +72,37 @@ type TransactionStreamer struct {
 	broadcastServer *broadcaster.Broadcaster
 	validator       *validator.BlockValidator
 	inboxReader     *InboxReader
+	newBlockHooks   []func(*types.Block, types.Receipts)
+
+	reorgFeed         event.Feed
+	unsentReorgEvents []ReorgEvent // protected by the insertionMutex, and sent once it's released
+	reorgSendMutex    sync.Mutex   // keeps reorg events in order once they're taken from unsentReorgEvents
 }
 
 type TransactionStreamerConfig struct {
 	MaxBroadcastQueueSize int `koanf:"max-broadcaster-queue-size"`
+	PrefetchMessages      int `koanf:"prefetch-messages"`
+	ParseWorkers          int `koanf:"parse-workers"`
+	SenderRecoveryWorkers int `koanf:"sender-recovery-workers"`
+	WarmStateMessages     int `koanf:"warm-state-messages"`
 }
 
 type TransactionStreamerConfigFetcher func() *TransactionStreamerConfig
 
 var DefaultTransactionStreamerConfig = TransactionStreamerConfig{
 	MaxBroadcastQueueSize: 10_000,
+	PrefetchMessages:      128,
+	ParseWorkers:          0,
+	SenderRecoveryWorkers: 0,
+	WarmStateMessages:     0,
 }
 
 func TransactionStreamerConfigAddOptions(prefix string, f *flag.FlagSet) {
 	f.Int(prefix+".max-broadcaster-queue-size", DefaultTransactionStreamerConfig.MaxBroadcastQueueSize, "maximum cache of pending broadcaster messages")
+	f.Int(prefix+".prefetch-messages", DefaultTransactionStreamerConfig.PrefetchMessages, "number of messages to parse and recover the senders of ahead of block creation")
+	f.Int(prefix+".parse-workers", DefaultTransactionStreamerConfig.ParseWorkers, "number of goroutines parsing prefetched messages (0 = number of CPU cores)")
+	f.Int(prefix+".sender-recovery-workers", DefaultTransactionStreamerConfig.SenderRecoveryWorkers, "number of goroutines recovering the senders of prefetched transactions (0 = number of CPU cores)")
+	f.Int(prefix+".warm-state-messages", DefaultTransactionStreamerConfig.WarmStateMessages, "number of prefetched messages whose transactions' accounts and storage are loaded to warm the state cache while creating a block (0 = disabled)")
 }
 
 func NewTransactionStreamer(
@@ -130,6 +148,21 @@ func (s *TransactionStreamer) SetBlockValidator(validator *validator.BlockValida
 	s.validator = validator
 }
 
+// AddNewBlockHook registers a function to be called with each block written by the streamer, and its receipts.
+// Hooks are called from block creation, so they must return quickly.
+func (s *TransactionStreamer) AddNewBlockHook(hook func(*types.Block, types.Receipts)) {
+	if s.Started() {
+		panic("trying to add new block hook after start")
+	}
+	s.newBlockHooks = append(s.newBlockHooks, hook)
+}
+
+func (s *TransactionStreamer) runNewBlockHooks(block *types.Block, receipts types.Receipts) {
+	for _, hook := range s.newBlockHooks {
+		hook(block, receipts)
+	}
+}
+
 func (s *TransactionStreamer) SetSeqCoordinator(coordinator *SeqCoordinator) {
 	if s.Started() {
 		panic("trying to set coordinator after start")
@@ -167,17 +200,22 @@ func (s *TransactionStreamer) cleanupInconsistentState() error {
 }
 
 func (s *TransactionStreamer) ReorgTo(count arbutil.MessageIndex) error {
-	return s.ReorgToAndEndBatch(s.db.NewBatch(), count)
+	return s.ReorgToAndEndBatch(s.db.NewBatch(), count, ReorgCauseManual)
 }
 
-func (s *TransactionStreamer) ReorgToAndEndBatch(batch ethdb.Batch, count arbutil.MessageIndex) error {
+func (s *TransactionStreamer) ReorgToAndEndBatch(batch ethdb.Batch, count arbutil.MessageIndex, cause ReorgCause) error {
 	s.insertionMutex.Lock()
-	defer s.insertionMutex.Unlock()
-	err := s.reorgToInternal(batch, count)
+	defer s.unlockInsertion()
+	reorg, err := s.reorgToInternal(batch, count, cause)
 	if err != nil {
 		return err
 	}
-	return batch.Write()
+	err = batch.Write()
+	if err != nil {
+		return err
+	}
+	s.queueReorgEvent(reorg)
+	return nil
 }
 
 func deleteStartingAt(db ethdb.Database, batch ethdb.Batch, prefix []byte, minKey []byte) error {
@@ -192,17 +230,23 @@ func deleteStartingAt(db ethdb.Database, batch ethdb.Batch, prefix []byte, minKe
 	return iter.Error()
 }
 
-func (s *TransactionStreamer) reorgToInternal(batch ethdb.Batch, count arbutil.MessageIndex) error {
+// reorgToInternal returns an event describing the reorg, or nil if nothing was reorged, to send once the batch is written
+func (s *TransactionStreamer) reorgToInternal(batch ethdb.Batch, count arbutil.MessageIndex, cause ReorgCause) (*ReorgEvent, error) {
 	if count == 0 {
-		return errors.New("cannot reorg out init message")
+		return nil, errors.New("cannot reorg out init message")
 	}
 	atomic.AddUint32(&s.reorgPending, 1)
 	s.reorgMutex.Lock()
 	defer s.reorgMutex.Unlock()
 	atomic.AddUint32(&s.reorgPending, ^uint32(0)) // decrement
+	oldMessageCount, err := s.GetMessageCount()
+	if err != nil {
+		return nil, err
+	}
+	oldHead := s.bc.CurrentHeader()
 	blockNum, err := s.MessageCountToBlockNumber(count)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	// We can safely cast blockNum to a uint64 as we checked count == 0 above
 	targetBlock := s.bc.GetBlockByNumber(uint64(blockNum))
@@ -210,13 +254,13 @@ func (s *TransactionStreamer) reorgToInternal(batch ethdb.Batch, count arbutil.M
 		if s.validator != nil {
 			err = s.validator.ReorgToBlock(targetBlock.NumberU64(), targetBlock.Hash())
 			if err != nil {
-				return err
+				return nil, err
 			}
 		}
 
 		err = s.bc.ReorgToOldBlock(targetBlock)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	} else {
 		log.Warn("reorg target block not found", "block", blockNum)
@@ -224,10 +268,25 @@ func (s *TransactionStreamer) reorgToInternal(batch ethdb.Batch, count arbutil.M
 
 	err = deleteStartingAt(s.db, batch, messagePrefix, uint64ToKey(uint64(count)))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return setMessageCount(batch, count)
+	err = setMessageCount(batch, count)
+	if err != nil {
+		return nil, err
+	}
+	if count >= oldMessageCount && oldHead.Number.Uint64() <= uint64(blockNum) {
+		return nil, nil
+	}
+	return &ReorgEvent{
+		Cause:              cause,
+		OldMessageCount:    oldMessageCount,
+		NewMessageCount:    count,
+		FirstAffectedBlock: uint64(blockNum) + 1,
+		LastAffectedBlock:  oldHead.Number.Uint64(),
+		OldHeadHash:        oldHead.Hash(),
+		NewHeadHash:        s.bc.CurrentHeader().Hash(),
+	}, nil
 }
 
 func setMessageCount(batch ethdb.KeyValueWriter, count arbutil.MessageIndex) error {
@@ -256,6 +315,10 @@ func (s *TransactionStreamer) GetMessage(seqNum arbutil.MessageIndex) (*arbstate
 	key := dbKey(messagePrefix, uint64(seqNum))
 	data, err := s.db.Get(key)
 	if err != nil {
+		pruned, pruneErr := readInboxPruneState(s.db)
+		if pruneErr == nil && wasPruned(uint64(seqNum), uint64(pruned.MessageCount)) {
+			return nil, fmt.Errorf("message %v was %w", seqNum, ErrPruned)
+		}
 		return nil, err
 	}
 	var message arbstate.MessageWithMetadata
@@ -304,7 +367,7 @@ func (s *TransactionStreamer) AddBroadcastMessages(feedMessages []*broadcaster.B
 	}
 
 	s.insertionMutex.Lock()
-	defer s.insertionMutex.Unlock()
+	defer s.unlockInsertion()
 
 	var batch ethdb.Batch
 	var feedReorg bool
@@ -415,7 +478,7 @@ func (s *TransactionStreamer) GetMessageCountSync() (arbutil.MessageIndex, error
 
 func (s *TransactionStreamer) AddMessagesAndEndBatch(pos arbutil.MessageIndex, messagesAreConfirmed bool, messages []arbstate.MessageWithMetadata, batch ethdb.Batch) error {
 	s.insertionMutex.Lock()
-	defer s.insertionMutex.Unlock()
+	defer s.unlockInsertion()
 
 	return s.addMessagesAndEndBatchImpl(pos, messagesAreConfirmed, messages, batch)
 }
@@ -614,8 +677,14 @@ func (s *TransactionStreamer) addMessagesAndEndBatchImpl(messageStartPos arbutil
 	}
 
 	if confirmedReorg {
+		cause := ReorgCauseFeedConflict
+		replaced, err := s.GetMessage(messageStartPos)
+		if err == nil && replaced.Message.Header.Kind == arbos.L1MessageType_Invalid {
+			// only the coordinator inserts invalid messages
+			cause = ReorgCauseCoordinatorInvalidation
+		}
 		reorgBatch := s.db.NewBatch()
-		err = s.reorgToInternal(reorgBatch, messageStartPos)
+		reorg, err := s.reorgToInternal(reorgBatch, messageStartPos, cause)
 		if err != nil {
 			return err
 		}
@@ -623,6 +692,7 @@ func (s *TransactionStreamer) addMessagesAndEndBatchImpl(messageStartPos arbutil
 		if err != nil {
 			return err
 		}
+		s.queueReorgEvent(reorg)
 	} else if feedReorg {
 		if !time.Now().After(s.nextAllowedPendingReorgLog) {
 			return nil
@@ -798,6 +868,7 @@ func (s *TransactionStreamer) SequenceTransactions(header *arbos.L1IncomingMessa
 	if s.validator != nil {
 		s.validator.NewBlock(block, lastBlockHeader, msgWithMeta)
 	}
+	s.runNewBlockHooks(block, receipts)
 
 	return block, nil
 }
@@ -880,6 +951,11 @@ func (s *TransactionStreamer) BlockNumberToMessageCount(blockNum uint64) (arbuti
 	return arbutil.BlockNumberToMessageCount(blockNum, genesis), nil
 }
 
+// GetHeadMessageCount returns the number of messages the head block was built from
+func (s *TransactionStreamer) GetHeadMessageCount() (arbutil.MessageIndex, error) {
+	return s.BlockNumberToMessageCount(s.bc.CurrentBlock().NumberU64())
+}
+
 func (s *TransactionStreamer) MessageCountToBlockNumber(messageNum arbutil.MessageIndex) (int64, error) {
 	genesis, err := s.GetGenesisBlockNumber()
 	if err != nil {
@@ -974,6 +1050,17 @@ func (s *TransactionStreamer) createBlocks(ctx context.Context) error {
 		return s.inboxReader.GetSequencerMessageBytes(ctx, batchNum)
 	}
 
+	prefetcher := newMessagePrefetcher(ctx, s, batchFetcher, pos, msgCount)
+	defer prefetcher.StopAndWait()
+	// the warmer must be stopped before the prefetcher, as it waits on prefetched messages
+	var warmer *stateWarmer
+	var warmedUntil arbutil.MessageIndex
+	defer func() {
+		if warmer != nil {
+			warmer.InterruptAndWait()
+		}
+	}()
+
 	for pos < msgCount {
 
 		statedb, err = s.bc.StateAt(lastBlockHeader.Root)
@@ -993,20 +1080,38 @@ func (s *TransactionStreamer) createBlocks(ctx context.Context) error {
 
 		statedb.StartPrefetcher("TransactionStreamer")
 
-		msg, err := s.GetMessage(pos)
+		prefetched, err := prefetcher.Next()
 		if err != nil {
+			if ctx.Err() != nil {
+				// nolint:nilerr
+				return nil
+			}
 			return err
 		}
+		msg := prefetched.msg
+
+		warmStateMessages := s.configFetcher().WarmStateMessages
+		if warmStateMessages > 0 && (warmer == nil || warmer.Finished()) {
+			// Each message is warmed once, so those upcoming while a warmer was still running aren't skipped
+			upcoming := prefetcher.Upcoming(warmStateMessages)
+			for len(upcoming) > 0 && upcoming[0].pos < warmedUntil {
+				upcoming = upcoming[1:]
+			}
+			if len(upcoming) > 0 {
+				warmer = warmState(statedb, upcoming)
+				warmedUntil = upcoming[len(upcoming)-1].pos + 1
+			}
+		}
 
 		startTime := time.Now()
-		block, receipts, err := arbos.ProduceBlock(
+		block, receipts, err := arbos.ProduceBlockFromTransactions(
 			msg.Message,
+			prefetched.txes,
 			msg.DelayedMessagesRead,
 			lastBlockHeader,
 			statedb,
 			s.bc,
 			s.bc.Config(),
-			batchFetcher,
 		)
 		if err != nil {
 			return err
@@ -1030,6 +1135,7 @@ func (s *TransactionStreamer) createBlocks(ctx context.Context) error {
 		if s.validator != nil {
 			s.validator.NewBlock(block, lastBlockHeader, *msg)
 		}
+		s.runNewBlockHooks(block, receipts)
 
 		if time.Now().After(s.nextScheduledVersionCheck) {
 			s.nextScheduledVersionCheck = time.Now().Add(time.Minute)
@@ -0,0 +1,324 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+type TxFilterConfig struct {
+	RulesFile      string        `koanf:"rules-file" reload:"hot"`
+	ReloadInterval time.Duration `koanf:"reload-interval" reload:"hot"`
+}
+
+var DefaultTxFilterConfig = TxFilterConfig{
+	RulesFile:      "",
+	ReloadInterval: 10 * time.Second,
+}
+
+func (c *TxFilterConfig) Validate() error {
+	if c.RulesFile != "" && c.ReloadInterval <= 0 {
+		return errors.New("sequencer tx filter reload interval must be positive")
+	}
+	return nil
+}
+
+func TxFilterConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.String(prefix+".rules-file", DefaultTxFilterConfig.RulesFile, "path to a JSON file of transaction filter rules (if empty, no rules are applied)")
+	f.Duration(prefix+".reload-interval", DefaultTxFilterConfig.ReloadInterval, "how often to check the rules file for changes")
+}
+
+// Reason codes for transactions rejected by the filter rules
+const (
+	TxFilterReasonSenderDenied        = "sender-denied"
+	TxFilterReasonSenderNotAllowed    = "sender-not-allowed"
+	TxFilterReasonRecipientDenied     = "recipient-denied"
+	TxFilterReasonRecipientNotAllowed = "recipient-not-allowed"
+	TxFilterReasonMethodDenied        = "method-denied"
+	TxFilterReasonContractCreation    = "contract-creation-denied"
+	TxFilterReasonSenderCalldataLimit = "sender-calldata-limit"
+	TxFilterReasonReverted            = "reverted"
+	TxFilterReasonGasUsed             = "gas-used"
+)
+
+const txFilterRejectedMetricPrefix = "arb/sequencer/txfilter/rejected/"
+
+// Matches the "transaction rejected" code of EIP-1474
+const txFilterRejectionRpcErrorCode = -32003
+
+var txFilterReloadedCounter = metrics.NewRegisteredCounter("arb/sequencer/txfilter/reloaded", nil)
+
+// TxFilterError is returned for transactions rejected by the filter rules.
+// It's passed through the RPC layer as a JSON-RPC error carrying the reason code.
+type TxFilterError struct {
+	Reason string
+	Detail string
+}
+
+func (e *TxFilterError) Error() string {
+	return fmt.Sprintf("transaction rejected by sequencer filter (%v): %v", e.Reason, e.Detail)
+}
+
+func (e *TxFilterError) ErrorCode() int {
+	return txFilterRejectionRpcErrorCode
+}
+
+func (e *TxFilterError) ErrorData() interface{} {
+	return e.Reason
+}
+
+func newTxFilterError(reason string, detail string, args ...interface{}) error {
+	metrics.GetOrRegisterCounter(txFilterRejectedMetricPrefix+reason, nil).Inc(1)
+	return &TxFilterError{
+		Reason: reason,
+		Detail: fmt.Sprintf(detail, args...),
+	}
+}
+
+// TxFilterPostRule rejects transactions after execution based on their result.
+// If Recipients is set, the rule only applies to transactions sent to one of them.
+type TxFilterPostRule struct {
+	Recipients   []common.Address `json:"recipients,omitempty"`
+	RejectRevert bool             `json:"reject-revert,omitempty"`
+	MaxGasUsed   uint64           `json:"max-gas-used,omitempty"`
+}
+
+// TxFilterRules is the format of the rules file.
+// Allow lists are only applied when non-empty, and deny lists take precedence over them.
+type TxFilterRules struct {
+	DenySenders          []common.Address   `json:"deny-senders,omitempty"`
+	AllowSenders         []common.Address   `json:"allow-senders,omitempty"`
+	DenyRecipients       []common.Address   `json:"deny-recipients,omitempty"`
+	AllowRecipients      []common.Address   `json:"allow-recipients,omitempty"`
+	DenyMethods          []hexutil.Bytes    `json:"deny-methods,omitempty"`
+	DenyContractCreation bool               `json:"deny-contract-creation,omitempty"`
+	MaxCalldataPerSender uint64             `json:"max-calldata-per-sender,omitempty"`
+	PostRules            []TxFilterPostRule `json:"post-rules,omitempty"`
+}
+
+type compiledPostRule struct {
+	recipients   map[common.Address]struct{}
+	rejectRevert bool
+	maxGasUsed   uint64
+}
+
+type compiledTxFilterRules struct {
+	denySenders          map[common.Address]struct{}
+	allowSenders         map[common.Address]struct{}
+	denyRecipients       map[common.Address]struct{}
+	allowRecipients      map[common.Address]struct{}
+	denyMethods          map[[4]byte]struct{}
+	denyContractCreation bool
+	maxCalldataPerSender uint64
+	postRules            []compiledPostRule
+}
+
+func addressSet(addresses []common.Address) map[common.Address]struct{} {
+	set := make(map[common.Address]struct{}, len(addresses))
+	for _, address := range addresses {
+		set[address] = struct{}{}
+	}
+	return set
+}
+
+func (r *TxFilterRules) compile() (*compiledTxFilterRules, error) {
+	compiled := &compiledTxFilterRules{
+		denySenders:          addressSet(r.DenySenders),
+		allowSenders:         addressSet(r.AllowSenders),
+		denyRecipients:       addressSet(r.DenyRecipients),
+		allowRecipients:      addressSet(r.AllowRecipients),
+		denyMethods:          make(map[[4]byte]struct{}, len(r.DenyMethods)),
+		denyContractCreation: r.DenyContractCreation,
+		maxCalldataPerSender: r.MaxCalldataPerSender,
+	}
+	for _, method := range r.DenyMethods {
+		if len(method) != 4 {
+			return nil, fmt.Errorf("method selector %v is not 4 bytes", method)
+		}
+		var selector [4]byte
+		copy(selector[:], method)
+		compiled.denyMethods[selector] = struct{}{}
+	}
+	for _, rule := range r.PostRules {
+		compiled.postRules = append(compiled.postRules, compiledPostRule{
+			recipients:   addressSet(rule.Recipients),
+			rejectRevert: rule.RejectRevert,
+			maxGasUsed:   rule.MaxGasUsed,
+		})
+	}
+	return compiled, nil
+}
+
+func ParseTxFilterRules(data []byte) (*TxFilterRules, error) {
+	var rules TxFilterRules
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("error parsing tx filter rules: %w", err)
+	}
+	return &rules, nil
+}
+
+// TxFilter applies the rules loaded from a file, reloading them when the file changes.
+// A failed reload keeps the previous rules in place.
+type TxFilter struct {
+	mutex   sync.RWMutex
+	rules   *compiledTxFilterRules
+	path    string
+	modTime time.Time
+
+	// Only accessed from the sequencing thread
+	calldataHeader *types.Header
+	calldataUsed   map[common.Address]uint64
+}
+
+func NewTxFilter() *TxFilter {
+	return &TxFilter{
+		calldataUsed: make(map[common.Address]uint64),
+	}
+}
+
+func (f *TxFilter) getRules() *compiledTxFilterRules {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	return f.rules
+}
+
+// Reload loads the rules file at path, if it has changed since it was last loaded.
+// An empty path clears the rules.
+func (f *TxFilter) Reload(path string) error {
+	if path == "" {
+		f.mutex.Lock()
+		defer f.mutex.Unlock()
+		f.rules = nil
+		f.path = ""
+		f.modTime = time.Time{}
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	f.mutex.RLock()
+	unchanged := f.path == path && f.modTime.Equal(info.ModTime())
+	f.mutex.RUnlock()
+	if unchanged {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	rules, err := ParseTxFilterRules(data)
+	if err != nil {
+		return err
+	}
+	compiled, err := rules.compile()
+	if err != nil {
+		return err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.rules = compiled
+	f.path = path
+	f.modTime = info.ModTime()
+	txFilterReloadedCounter.Inc(1)
+	log.Info("loaded sequencer tx filter rules", "path", path)
+	return nil
+}
+
+func (f *TxFilter) PreCheck(header *types.Header, tx *types.Transaction, sender common.Address) error {
+	rules := f.getRules()
+	if rules == nil {
+		return nil
+	}
+	if _, denied := rules.denySenders[sender]; denied {
+		return newTxFilterError(TxFilterReasonSenderDenied, "sender %v", sender)
+	}
+	if len(rules.allowSenders) > 0 {
+		if _, allowed := rules.allowSenders[sender]; !allowed {
+			return newTxFilterError(TxFilterReasonSenderNotAllowed, "sender %v", sender)
+		}
+	}
+	to := tx.To()
+	if to == nil {
+		if rules.denyContractCreation {
+			return newTxFilterError(TxFilterReasonContractCreation, "sender %v", sender)
+		}
+	} else {
+		if _, denied := rules.denyRecipients[*to]; denied {
+			return newTxFilterError(TxFilterReasonRecipientDenied, "recipient %v", *to)
+		}
+		if len(rules.allowRecipients) > 0 {
+			if _, allowed := rules.allowRecipients[*to]; !allowed {
+				return newTxFilterError(TxFilterReasonRecipientNotAllowed, "recipient %v", *to)
+			}
+		}
+		if len(tx.Data()) >= 4 {
+			var selector [4]byte
+			copy(selector[:], tx.Data())
+			if _, denied := rules.denyMethods[selector]; denied {
+				return newTxFilterError(TxFilterReasonMethodDenied, "method %v", hexutil.Bytes(selector[:]))
+			}
+		}
+	}
+	if rules.maxCalldataPerSender > 0 {
+		used := f.blockCalldataUsed(header)[sender] + uint64(len(tx.Data()))
+		if used > rules.maxCalldataPerSender {
+			return newTxFilterError(TxFilterReasonSenderCalldataLimit, "sender %v would use %v calldata bytes in this block, limit %v", sender, used, rules.maxCalldataPerSender)
+		}
+	}
+	return nil
+}
+
+// blockCalldataUsed returns the calldata used by each sender's txs included in the block being built with header
+func (f *TxFilter) blockCalldataUsed(header *types.Header) map[common.Address]uint64 {
+	// The header is updated as the block is built, so we compare pointers, as the nonce cache does.
+	if f.calldataHeader != header {
+		f.calldataHeader = header
+		f.calldataUsed = make(map[common.Address]uint64)
+	}
+	return f.calldataUsed
+}
+
+// PostCheck checks the tx's execution result. If it passes, the tx is included,
+// so its calldata is only charged to the sender's budget for the block then.
+func (f *TxFilter) PostCheck(header *types.Header, tx *types.Transaction, sender common.Address, result *core.ExecutionResult) error {
+	rules := f.getRules()
+	if rules == nil {
+		return nil
+	}
+	for _, rule := range rules.postRules {
+		if len(rule.recipients) > 0 {
+			if tx.To() == nil {
+				continue
+			}
+			if _, applies := rule.recipients[*tx.To()]; !applies {
+				continue
+			}
+		}
+		if rule.rejectRevert && result.Err != nil {
+			return newTxFilterError(TxFilterReasonReverted, "%v", result.Err)
+		}
+		if rule.maxGasUsed > 0 && result.UsedGas > rule.maxGasUsed {
+			return newTxFilterError(TxFilterReasonGasUsed, "used %v gas, limit %v", result.UsedGas, rule.maxGasUsed)
+		}
+	}
+	if rules.maxCalldataPerSender > 0 {
+		f.blockCalldataUsed(header)[sender] += uint64(len(tx.Data()))
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+func expectTxFilterReason(t *testing.T, err error, reason string) {
+	t.Helper()
+	if reason == "" {
+		Require(t, err)
+		return
+	}
+	var filterErr *TxFilterError
+	if !errors.As(err, &filterErr) {
+		Fail(t, "expected tx filter rejection with reason", reason, "but got", err)
+	}
+	if filterErr.Reason != reason {
+		Fail(t, "expected tx filter rejection with reason", reason, "but got", filterErr.Reason)
+	}
+}
+
+func writeTxFilterRules(t *testing.T, path string, rules string, modTime time.Time) {
+	t.Helper()
+	Require(t, os.WriteFile(path, []byte(rules), 0600))
+	// Set the modification time explicitly, as quick rewrites may otherwise share one
+	Require(t, os.Chtimes(path, modTime, modTime))
+}
+
+func TestTxFilterRules(t *testing.T) {
+	alice := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	bob := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	token := common.HexToAddress("0x3333333333333333333333333333333333333333")
+	blocked := common.HexToAddress("0x4444444444444444444444444444444444444444")
+
+	path := filepath.Join(t.TempDir(), "rules.json")
+	now := time.Now()
+	writeTxFilterRules(t, path, `{
+		"deny-senders": ["0x2222222222222222222222222222222222222222"],
+		"deny-recipients": ["0x4444444444444444444444444444444444444444"],
+		"deny-methods": ["0xa9059cbb"],
+		"deny-contract-creation": true,
+		"max-calldata-per-sender": 10,
+		"post-rules": [{"recipients": ["0x3333333333333333333333333333333333333333"], "reject-revert": true}]
+	}`, now)
+
+	filter := NewTxFilter()
+	Require(t, filter.Reload(path))
+
+	header := &types.Header{}
+	call := func(to *common.Address, data []byte) *types.Transaction {
+		return types.NewTx(&types.LegacyTx{To: to, Data: data})
+	}
+	transfer := []byte{0xa9, 0x05, 0x9c, 0xbb, 0x00}
+
+	expectTxFilterReason(t, filter.PreCheck(header, call(&token, nil), alice), "")
+	expectTxFilterReason(t, filter.PreCheck(header, call(&token, nil), bob), TxFilterReasonSenderDenied)
+	expectTxFilterReason(t, filter.PreCheck(header, call(&blocked, nil), alice), TxFilterReasonRecipientDenied)
+	expectTxFilterReason(t, filter.PreCheck(header, call(&token, transfer), alice), TxFilterReasonMethodDenied)
+	expectTxFilterReason(t, filter.PreCheck(header, call(nil, nil), alice), TxFilterReasonContractCreation)
+
+	// The calldata budget is per sender per block, and only charged for txs passing the post check
+	succeeded := &core.ExecutionResult{UsedGas: 30000}
+	reverted := &core.ExecutionResult{UsedGas: 30000, Err: vm.ErrExecutionReverted}
+	sixBytes := call(&token, make([]byte, 6))
+	expectTxFilterReason(t, filter.PreCheck(header, sixBytes, alice), "")
+	expectTxFilterReason(t, filter.PostCheck(header, sixBytes, alice, reverted), TxFilterReasonReverted)
+	expectTxFilterReason(t, filter.PreCheck(header, sixBytes, alice), "")
+	expectTxFilterReason(t, filter.PostCheck(header, sixBytes, alice, succeeded), "")
+	expectTxFilterReason(t, filter.PreCheck(header, sixBytes, alice), TxFilterReasonSenderCalldataLimit)
+	expectTxFilterReason(t, filter.PreCheck(header, call(&token, make([]byte, 4)), alice), "")
+	newHeader := &types.Header{}
+	expectTxFilterReason(t, filter.PreCheck(newHeader, sixBytes, alice), "")
+
+	// The post rule only applies to the token
+	expectTxFilterReason(t, filter.PostCheck(header, call(&alice, nil), alice, reverted), "")
+
+	// Reloading switches to the new rules
+	writeTxFilterRules(t, path, `{"allow-senders": ["0x2222222222222222222222222222222222222222"]}`, now.Add(time.Second))
+	Require(t, filter.Reload(path))
+	expectTxFilterReason(t, filter.PreCheck(header, call(&token, nil), bob), "")
+	expectTxFilterReason(t, filter.PreCheck(header, call(&token, nil), alice), TxFilterReasonSenderNotAllowed)
+
+	// A broken rules file keeps the previous rules
+	writeTxFilterRules(t, path, `{"deny-methods": ["0x01"]}`, now.Add(2*time.Second))
+	if filter.Reload(path) == nil {
+		Fail(t, "loaded rules with an invalid method selector")
+	}
+	expectTxFilterReason(t, filter.PreCheck(header, call(&token, nil), alice), TxFilterReasonSenderNotAllowed)
+
+	// No rules file means no rules
+	Require(t, filter.Reload(""))
+	expectTxFilterReason(t, filter.PreCheck(header, call(&token, nil), alice), "")
+}
@@ -6,6 +6,7 @@ package arbnode
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core"
@@ -14,20 +15,25 @@ import (
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/offchainlabs/nitro/arbos/arbosState"
 	"github.com/offchainlabs/nitro/arbos/l1pricing"
+	"github.com/offchainlabs/nitro/arbutil"
 	"github.com/offchainlabs/nitro/util/arbmath"
 )
 
 type TxPreChecker struct {
 	TransactionPublisher
-	bc            *core.BlockChain
-	getStrictness func() uint
+	bc                  *core.BlockChain
+	getStrictness       func() uint
+	getAllowFutureNonce func() bool
 }
 
-func NewTxPreChecker(publisher TransactionPublisher, bc *core.BlockChain, getStrictness func() uint) *TxPreChecker {
+// NewTxPreChecker creates a pre-checker which lets future nonces through full validation if getAllowFutureNonce returns true,
+// as the sequencer's nonce holding pool holds them until the nonce gap is filled
+func NewTxPreChecker(publisher TransactionPublisher, bc *core.BlockChain, getStrictness func() uint, getAllowFutureNonce func() bool) *TxPreChecker {
 	return &TxPreChecker{
 		TransactionPublisher: publisher,
 		bc:                   bc,
 		getStrictness:        getStrictness,
+		getAllowFutureNonce:  getAllowFutureNonce,
 	}
 }
 
@@ -46,7 +52,21 @@ func MakeNonceError(sender common.Address, txNonce uint64, stateNonce uint64) er
 	}
 }
 
-func PreCheckTx(chainConfig *params.ChainConfig, header *types.Header, statedb *state.StateDB, arbos *arbosState.ArbosState, tx *types.Transaction, strictness uint) error {
+// PreCheckTx checks tx against the state after the block with the given header.
+// If options is set, the conditions are checked as if the tx were in the block after it, made now.
+// Unless allowFutureNonce is set, full validation rejects nonces above the sender's state nonce.
+func PreCheckTx(chainConfig *params.ChainConfig, header *types.Header, statedb *state.StateDB, arbos *arbosState.ArbosState, tx *types.Transaction, options *arbutil.ConditionalOptions, strictness uint, allowFutureNonce bool) error {
+	// Conditions are enforced at any strictness, as the submitter asked for the tx to be dropped if they fail
+	if options != nil {
+		// The next block's timestamp is the time it's made, but never before its parent's
+		nextTimestamp := uint64(time.Now().Unix())
+		if nextTimestamp < header.Time {
+			nextTimestamp = header.Time
+		}
+		if err := options.Check(header.Number.Uint64()+1, nextTimestamp, statedb); err != nil {
+			return err
+		}
+	}
 	if strictness < TxPreCheckerStrictnessAlwaysCompatible {
 		return nil
 	}
@@ -86,7 +106,7 @@ func PreCheckTx(chainConfig *params.ChainConfig, header *types.Header, statedb *
 	if arbmath.BigLessThan(balance, cost) {
 		return fmt.Errorf("%w: address %v have %v want %v", core.ErrInsufficientFunds, sender, balance, cost)
 	}
-	if strictness >= TxPreCheckerStrictnessFullValidation && tx.Nonce() > stateNonce {
+	if strictness >= TxPreCheckerStrictnessFullValidation && tx.Nonce() > stateNonce && !allowFutureNonce {
 		return MakeNonceError(sender, tx.Nonce(), stateNonce)
 	}
 	dataCost, _ := arbos.L1PricingState().GetPosterInfo(tx, l1pricing.BatchPosterAddress)
@@ -97,7 +117,7 @@ func PreCheckTx(chainConfig *params.ChainConfig, header *types.Header, statedb *
 	return nil
 }
 
-func (c *TxPreChecker) PublishTransaction(ctx context.Context, tx *types.Transaction) error {
+func (c *TxPreChecker) PublishTransaction(ctx context.Context, tx *types.Transaction, options *arbutil.ConditionalOptions) error {
 	block := c.bc.CurrentBlock()
 	statedb, err := c.bc.StateAt(block.Root())
 	if err != nil {
@@ -107,9 +127,35 @@ func (c *TxPreChecker) PublishTransaction(ctx context.Context, tx *types.Transac
 	if err != nil {
 		return err
 	}
-	err = PreCheckTx(c.bc.Config(), block.Header(), statedb, arbos, tx, c.getStrictness())
+	err = PreCheckTx(c.bc.Config(), block.Header(), statedb, arbos, tx, options, c.getStrictness(), c.getAllowFutureNonce())
 	if err != nil {
 		return err
 	}
-	return c.TransactionPublisher.PublishTransaction(ctx, tx)
+	return c.TransactionPublisher.PublishTransaction(ctx, tx, options)
+}
+
+// PublishBundle pre-checks each of the bundle's transactions against the latest state, as if it were submitted alone
+func (c *TxPreChecker) PublishBundle(ctx context.Context, txs types.Transactions, targetBlock *uint64) error {
+	publisher, ok := c.TransactionPublisher.(BundlePublisher)
+	if !ok {
+		return ErrBundlesUnsupported
+	}
+	block := c.bc.CurrentBlock()
+	statedb, err := c.bc.StateAt(block.Root())
+	if err != nil {
+		return err
+	}
+	arbos, err := arbosState.OpenSystemArbosState(statedb, nil, true)
+	if err != nil {
+		return err
+	}
+	strictness := c.getStrictness()
+	for i, tx := range txs {
+		// bundles aren't held, so their future nonces are always rejected
+		err = PreCheckTx(c.bc.Config(), block.Header(), statedb, arbos, tx, nil, strictness, false)
+		if err != nil {
+			return fmt.Errorf("%w: transaction %v (%v): %v", ErrBundleFailed, i, tx.Hash(), err)
+		}
+	}
+	return publisher.PublishBundle(ctx, txs, targetBlock)
 }
@@ -0,0 +1,45 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/offchainlabs/nitro/arbos/arbosState"
+	"github.com/offchainlabs/nitro/arbutil"
+)
+
+func TestPreCheckTxConditionsAtAnyStrictness(t *testing.T) {
+	_, _, bc := NewTransactionStreamerForTest(t, common.Address{})
+	header := bc.CurrentBlock().Header()
+	statedb, err := bc.StateAt(header.Root)
+	Require(t, err)
+	arbState, err := arbosState.OpenSystemArbosState(statedb, nil, true)
+	Require(t, err)
+
+	tx := types.NewTx(&types.LegacyTx{})
+	expired := hexutil.Uint64(header.Number.Uint64())
+	options := &arbutil.ConditionalOptions{BlockNumberMax: &expired}
+	for _, strictness := range []uint{TxPreCheckerStrictnessNone, TxPreCheckerStrictnessAlwaysCompatible, TxPreCheckerStrictnessLikelyCompatible} {
+		err = PreCheckTx(bc.Config(), header, statedb, arbState, tx, options, strictness, false)
+		var rejected *arbutil.ConditionalOptionsRejectedError
+		if !errors.As(err, &rejected) {
+			Fail(t, "expired conditions weren't rejected at strictness", strictness, "got", err)
+		}
+	}
+	// the conditions are checked against the next block, which is made now rather than at its parent's time
+	next := hexutil.Uint64(header.Number.Uint64() + 1)
+	started := hexutil.Uint64(time.Now().Unix() - 60)
+	options = &arbutil.ConditionalOptions{BlockNumberMin: &next, TimestampMin: &started}
+	Require(t, PreCheckTx(bc.Config(), header, statedb, arbState, tx, options, TxPreCheckerStrictnessNone, false))
+
+	// without conditions, nothing is checked at strictness none
+	Require(t, PreCheckTx(bc.Config(), header, statedb, arbState, tx, nil, TxPreCheckerStrictnessNone, false))
+}
@@ -0,0 +1,199 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/offchainlabs/nitro/arbutil"
+	"github.com/offchainlabs/nitro/util/containers"
+)
+
+var (
+	senderThrottledCounter = metrics.NewRegisteredCounter("arb/txratelimit/sender/throttled", nil)
+	clientThrottledCounter = metrics.NewRegisteredCounter("arb/txratelimit/client/throttled", nil)
+)
+
+type TxRateLimitConfig struct {
+	Enable         bool    `koanf:"enable"`
+	PerSenderRate  float64 `koanf:"per-sender-rate"`
+	PerSenderBurst int     `koanf:"per-sender-burst"`
+	PerClientRate  float64 `koanf:"per-client-rate"`
+	PerClientBurst int     `koanf:"per-client-burst"`
+	MaxTrackedKeys int     `koanf:"max-tracked-keys"`
+}
+
+var DefaultTxRateLimitConfig = TxRateLimitConfig{
+	Enable:         false,
+	PerSenderRate:  10,
+	PerSenderBurst: 50,
+	PerClientRate:  100,
+	PerClientBurst: 500,
+	MaxTrackedKeys: 100_000,
+}
+
+func TxRateLimitConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", DefaultTxRateLimitConfig.Enable, "enable rate limiting of transaction submissions")
+	f.Float64(prefix+".per-sender-rate", DefaultTxRateLimitConfig.PerSenderRate, "transactions per second allowed from a single sender address (0 = unlimited)")
+	f.Int(prefix+".per-sender-burst", DefaultTxRateLimitConfig.PerSenderBurst, "number of transactions a single sender address may submit in a burst")
+	f.Float64(prefix+".per-client-rate", DefaultTxRateLimitConfig.PerClientRate, "transactions per second allowed from a single RPC client IP, which is the forwarding node's for transactions forwarded to a sequencer (0 = unlimited)")
+	f.Int(prefix+".per-client-burst", DefaultTxRateLimitConfig.PerClientBurst, "number of transactions a single RPC client IP may submit in a burst")
+	f.Int(prefix+".max-tracked-keys", DefaultTxRateLimitConfig.MaxTrackedKeys, "maximum number of senders and clients to track, least recently seen first to be forgotten")
+}
+
+// txRateLimitError is a JSON-RPC error with the "limit exceeded" code of EIP-1474,
+// and data naming the limit that was exceeded
+type txRateLimitError struct {
+	message string
+	limit   string
+}
+
+func (e *txRateLimitError) Error() string {
+	return e.message
+}
+
+func (e *txRateLimitError) ErrorCode() int {
+	return -32005
+}
+
+func (e *txRateLimitError) ErrorData() interface{} {
+	return map[string]string{"limit": e.limit}
+}
+
+var (
+	ErrSenderRateLimited = &txRateLimitError{"transaction rate limit exceeded for sender", "sender"}
+	ErrClientRateLimited = &txRateLimitError{"transaction rate limit exceeded for client", "client"}
+)
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take refills the bucket for the time passed since it was last used, and takes a token if one is available
+func (b *tokenBucket) take(now time.Time, rate float64, burst int) bool {
+	b.tokens += now.Sub(b.last).Seconds() * rate
+	if b.tokens > float64(burst) {
+		b.tokens = float64(burst)
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+type tokenBuckets[K comparable] struct {
+	mutex   sync.Mutex
+	buckets *containers.LruCache[K, *tokenBucket]
+}
+
+func newTokenBuckets[K comparable](size int) *tokenBuckets[K] {
+	return &tokenBuckets[K]{
+		buckets: containers.NewLruCache[K, *tokenBucket](size),
+	}
+}
+
+func (b *tokenBuckets[K]) take(key K, now time.Time, rate float64, burst int) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	bucket, ok := b.buckets.Get(key)
+	if !ok {
+		bucket = &tokenBucket{
+			tokens: float64(burst),
+			last:   now,
+		}
+		b.buckets.Add(key, bucket)
+	}
+	return bucket.take(now, rate, burst)
+}
+
+// TxRateLimiter throttles transaction submissions per sender address and per RPC client,
+// using token buckets, before passing them to the wrapped publisher.
+type TxRateLimiter struct {
+	TransactionPublisher
+	bc      *core.BlockChain
+	config  *TxRateLimitConfig
+	senders *tokenBuckets[common.Address]
+	clients *tokenBuckets[string]
+}
+
+func NewTxRateLimiter(publisher TransactionPublisher, bc *core.BlockChain, config *TxRateLimitConfig) *TxRateLimiter {
+	return &TxRateLimiter{
+		TransactionPublisher: publisher,
+		bc:                   bc,
+		config:               config,
+		senders:              newTokenBuckets[common.Address](config.MaxTrackedKeys),
+		clients:              newTokenBuckets[string](config.MaxTrackedKeys),
+	}
+}
+
+// clientKey identifies the RPC client that submitted the transaction by its IP, if known.
+// Transactions forwarded to a sequencer all have the forwarding node's IP, so clients are best limited on the forwarders.
+func clientKey(ctx context.Context) string {
+	remote := rpc.PeerInfoFromContext(ctx).RemoteAddr
+	if remote == "" {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(remote)
+	if err != nil {
+		return remote
+	}
+	return host
+}
+
+// take takes a token for the client and the sender of tx, or returns the limit that was exceeded
+func (l *TxRateLimiter) take(ctx context.Context, tx *types.Transaction, now time.Time) error {
+	if l.config.PerClientRate > 0 {
+		client := clientKey(ctx)
+		if client != "" && !l.clients.take(client, now, l.config.PerClientRate, l.config.PerClientBurst) {
+			clientThrottledCounter.Inc(1)
+			return ErrClientRateLimited
+		}
+	}
+	if l.config.PerSenderRate > 0 {
+		sender, err := types.Sender(types.LatestSigner(l.bc.Config()), tx)
+		if err != nil {
+			return err
+		}
+		if !l.senders.take(sender, now, l.config.PerSenderRate, l.config.PerSenderBurst) {
+			senderThrottledCounter.Inc(1)
+			return ErrSenderRateLimited
+		}
+	}
+	return nil
+}
+
+func (l *TxRateLimiter) PublishTransaction(ctx context.Context, tx *types.Transaction, options *arbutil.ConditionalOptions) error {
+	if err := l.take(ctx, tx, time.Now()); err != nil {
+		return err
+	}
+	return l.TransactionPublisher.PublishTransaction(ctx, tx, options)
+}
+
+// PublishBundle counts each of the bundle's transactions against the limits, as if it were submitted alone
+func (l *TxRateLimiter) PublishBundle(ctx context.Context, txs types.Transactions, targetBlock *uint64) error {
+	publisher, ok := l.TransactionPublisher.(BundlePublisher)
+	if !ok {
+		return ErrBundlesUnsupported
+	}
+	now := time.Now()
+	for _, tx := range txs {
+		if err := l.take(ctx, tx, now); err != nil {
+			return err
+		}
+	}
+	return publisher.PublishBundle(ctx, txs, targetBlock)
+}
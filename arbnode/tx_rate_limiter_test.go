@@ -0,0 +1,66 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTokenBuckets(t *testing.T) {
+	buckets := newTokenBuckets[string](2)
+	now := time.Now()
+	rate := 2.0
+	burst := 3
+
+	for i := 0; i < burst; i++ {
+		if !buckets.take("a", now, rate, burst) {
+			Fail(t, "throttled within burst at attempt", i)
+		}
+	}
+	if buckets.take("a", now, rate, burst) {
+		Fail(t, "allowed more than the burst")
+	}
+	// Other keys have their own buckets
+	if !buckets.take("b", now, rate, burst) {
+		Fail(t, "throttled a fresh key")
+	}
+
+	// Half a second refills one token at 2 per second
+	now = now.Add(500 * time.Millisecond)
+	if !buckets.take("a", now, rate, burst) {
+		Fail(t, "bucket didn't refill")
+	}
+	if buckets.take("a", now, rate, burst) {
+		Fail(t, "bucket refilled too much")
+	}
+
+	// Refilling is capped at the burst
+	now = now.Add(time.Hour)
+	for i := 0; i < burst; i++ {
+		if !buckets.take("a", now, rate, burst) {
+			Fail(t, "throttled within burst after refill at attempt", i)
+		}
+	}
+	if buckets.take("a", now, rate, burst) {
+		Fail(t, "refilled beyond the burst")
+	}
+}
+
+func TestClientKey(t *testing.T) {
+	// without an RPC peer in the context, the client can't be identified, so it isn't limited
+	if key := clientKey(context.Background()); key != "" {
+		Fail(t, "client without an RPC peer was keyed as", key)
+	}
+}
+
+func TestRateLimitErrorsDistinct(t *testing.T) {
+	senderData := ErrSenderRateLimited.ErrorData()
+	clientData := ErrClientRateLimited.ErrorData()
+	if reflect.DeepEqual(senderData, clientData) {
+		Fail(t, "sender and client rate limit errors have the same data", senderData)
+	}
+}
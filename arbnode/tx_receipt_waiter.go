@@ -0,0 +1,124 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+var (
+	txSyncIncludedCounter = metrics.NewRegisteredCounter("arb/txsync/included", nil)
+	txSyncTimeoutCounter  = metrics.NewRegisteredCounter("arb/txsync/timeout", nil)
+	txSyncWaitTimer       = metrics.NewRegisteredTimer("arb/txsync/wait", nil)
+)
+
+type TxSyncConfig struct {
+	Timeout time.Duration `koanf:"timeout"`
+}
+
+var DefaultTxSyncConfig = TxSyncConfig{
+	Timeout: 10 * time.Second,
+}
+
+func (c *TxSyncConfig) Validate() error {
+	if c.Timeout <= 0 {
+		return fmt.Errorf("invalid tx-sync timeout %v, must be greater than 0", c.Timeout)
+	}
+	return nil
+}
+
+func TxSyncConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Duration(prefix+".timeout", DefaultTxSyncConfig.Timeout, "maximum time arb_sendRawTransactionSync waits for the transaction to be included in a block")
+}
+
+var ErrTxSyncTimeout = errors.New("timed out waiting for transaction to be included in a block")
+
+// txReceiptWaiter notifies callers waiting on a transaction once a block including it is created.
+// Its newBlockHook must be registered with the TransactionStreamer.
+type txReceiptWaiter struct {
+	mutex   sync.Mutex
+	waiters map[common.Hash][]chan *types.Receipt
+}
+
+func newTxReceiptWaiter() *txReceiptWaiter {
+	return &txReceiptWaiter{
+		waiters: make(map[common.Hash][]chan *types.Receipt),
+	}
+}
+
+// wait returns a channel receiving the receipt of the transaction once it's included, and a function to stop waiting.
+// It must be called before the transaction is published, so its block can't be missed.
+func (w *txReceiptWaiter) wait(txHash common.Hash) (<-chan *types.Receipt, func()) {
+	resultChan := make(chan *types.Receipt, 1)
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.waiters[txHash] = append(w.waiters[txHash], resultChan)
+	return resultChan, func() {
+		w.mutex.Lock()
+		defer w.mutex.Unlock()
+		waiters := w.waiters[txHash]
+		for i, waiter := range waiters {
+			if waiter == resultChan {
+				waiters = append(waiters[:i], waiters[i+1:]...)
+				break
+			}
+		}
+		if len(waiters) == 0 {
+			delete(w.waiters, txHash)
+		} else {
+			w.waiters[txHash] = waiters
+		}
+	}
+}
+
+func (w *txReceiptWaiter) newBlockHook(block *types.Block, receipts types.Receipts) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if len(w.waiters) == 0 {
+		return
+	}
+	for _, receipt := range receipts {
+		waiters, ok := w.waiters[receipt.TxHash]
+		if !ok {
+			continue
+		}
+		for _, waiter := range waiters {
+			// Each channel is buffered and only ever sent to once, so this doesn't block
+			waiter <- receipt
+		}
+		delete(w.waiters, receipt.TxHash)
+	}
+}
+
+// publishAndWait publishes tx and waits for it to be included in a block, returning its receipt.
+func (w *txReceiptWaiter) publishAndWait(ctx context.Context, publisher TransactionPublisher, tx *types.Transaction, timeout time.Duration) (*types.Receipt, error) {
+	receiptChan, cancel := w.wait(tx.Hash())
+	defer cancel()
+
+	start := time.Now()
+	ctx, cancelCtx := context.WithTimeout(ctx, timeout)
+	defer cancelCtx()
+	if err := publisher.PublishTransaction(ctx, tx, nil); err != nil {
+		return nil, err
+	}
+	select {
+	case receipt := <-receiptChan:
+		txSyncIncludedCounter.Inc(1)
+		txSyncWaitTimer.Update(time.Since(start))
+		return receipt, nil
+	case <-ctx.Done():
+		txSyncTimeoutCounter.Inc(1)
+		return nil, fmt.Errorf("%w: transaction %v", ErrTxSyncTimeout, tx.Hash())
+	}
+}
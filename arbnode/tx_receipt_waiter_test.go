@@ -0,0 +1,63 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+func TestTxReceiptWaiter(t *testing.T) {
+	waiter := newTxReceiptWaiter()
+	included := types.NewTx(&types.LegacyTx{Nonce: 1})
+	pending := types.NewTx(&types.LegacyTx{Nonce: 2})
+	abandoned := types.NewTx(&types.LegacyTx{Nonce: 3})
+
+	includedChan, cancelIncluded := waiter.wait(included.Hash())
+	defer cancelIncluded()
+	pendingChan, cancelPending := waiter.wait(pending.Hash())
+	defer cancelPending()
+	_, cancelAbandoned := waiter.wait(abandoned.Hash())
+	cancelAbandoned()
+	if _, ok := waiter.waiters[abandoned.Hash()]; ok {
+		Fail(t, "cancelled waiter wasn't removed")
+	}
+
+	header := &types.Header{Number: big.NewInt(1)}
+	block := types.NewBlock(header, types.Transactions{included, abandoned}, nil, nil, trie.NewStackTrie(nil))
+	receipts := types.Receipts{
+		{TxHash: included.Hash(), BlockHash: block.Hash(), GasUsedForL1: 7},
+		{TxHash: abandoned.Hash(), BlockHash: block.Hash()},
+	}
+	waiter.newBlockHook(block, receipts)
+
+	select {
+	case got := <-includedChan:
+		if got != receipts[0] {
+			Fail(t, "notified with the wrong receipt", got)
+		}
+	default:
+		Fail(t, "waiter wasn't notified of its transaction's receipt")
+	}
+	select {
+	case <-pendingChan:
+		Fail(t, "waiter notified of a block without its transaction")
+	default:
+	}
+	if len(waiter.waiters) != 1 {
+		Fail(t, "expected only the pending waiter to remain, but have", len(waiter.waiters))
+	}
+}
+
+func TestTxSyncConfigValidate(t *testing.T) {
+	config := DefaultTxSyncConfig
+	Require(t, config.Validate())
+	config.Timeout = 0
+	if config.Validate() == nil {
+		Fail(t, "zero tx-sync timeout was accepted")
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/offchainlabs/nitro/arbos/arbosState"
 	"github.com/offchainlabs/nitro/arbos/l2pricing"
 	"github.com/offchainlabs/nitro/arbos/util"
+	"github.com/offchainlabs/nitro/arbutil"
 	"github.com/offchainlabs/nitro/solgen/go/precompilesgen"
 	"github.com/offchainlabs/nitro/util/arbmath"
 
@@ -81,20 +82,27 @@ func createNewHeader(prevHeader *types.Header, l1info *L1Info, state *arbosState
 type SequencingHooks struct {
 	TxErrors               []error
 	DiscardInvalidTxsEarly bool
-	PreTxFilter            func(*params.ChainConfig, *types.Header, *state.StateDB, *arbosState.ArbosState, *types.Transaction, common.Address) error
+	PreTxFilter            func(*params.ChainConfig, *types.Header, *state.StateDB, *arbosState.ArbosState, *types.Transaction, *arbutil.ConditionalOptions, common.Address) error
 	PostTxFilter           func(*types.Header, *arbosState.ArbosState, *types.Transaction, common.Address, uint64, *core.ExecutionResult) error
+	// If set, the conditional options of each user tx, in order, passed to the PreTxFilter
+	ConditionalOptionsForTx []*arbutil.ConditionalOptions
+	// If set, called with the block's transactions and receipts once they've all been processed.
+	// An error discards the whole block.
+	BlockFilter func(*types.Header, *state.StateDB, types.Transactions, types.Receipts) error
 }
 
 func noopSequencingHooks() *SequencingHooks {
 	return &SequencingHooks{
 		[]error{},
 		false,
-		func(*params.ChainConfig, *types.Header, *state.StateDB, *arbosState.ArbosState, *types.Transaction, common.Address) error {
+		func(*params.ChainConfig, *types.Header, *state.StateDB, *arbosState.ArbosState, *types.Transaction, *arbutil.ConditionalOptions, common.Address) error {
 			return nil
 		},
 		func(*types.Header, *arbosState.ArbosState, *types.Transaction, common.Address, uint64, *core.ExecutionResult) error {
 			return nil
 		},
+		nil,
+		nil,
 	}
 }
 
@@ -109,6 +117,20 @@ func ProduceBlock(
 	chainConfig *params.ChainConfig,
 	batchFetcher FallibleBatchFetcher,
 ) (*types.Block, types.Receipts, error) {
+	txes, err := ParseBlockTransactions(message, chainConfig, batchFetcher)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ProduceBlockFromTransactions(message, txes, delayedMessagesRead, lastBlockHeader, statedb, chainContext, chainConfig)
+}
+
+// ParseBlockTransactions parses the transactions ProduceBlock executes for a message.
+// A message that fails to parse produces an empty block, but failing to fetch a batch it references is an error.
+func ParseBlockTransactions(
+	message *L1IncomingMessage,
+	chainConfig *params.ChainConfig,
+	batchFetcher FallibleBatchFetcher,
+) (types.Transactions, error) {
 	var batchFetchErr error
 	txes, err := message.ParseL2Transactions(chainConfig.ChainID, func(batchNum uint64, batchHash common.Hash) []byte {
 		data, err := batchFetcher(batchNum)
@@ -124,13 +146,25 @@ func ProduceBlock(
 		return data
 	})
 	if batchFetchErr != nil {
-		return nil, nil, batchFetchErr
+		return nil, batchFetchErr
 	}
 	if err != nil {
 		log.Warn("error parsing incoming message", "err", err)
 		txes = types.Transactions{}
 	}
+	return txes, nil
+}
 
+// ProduceBlockFromTransactions is ProduceBlock for a message whose transactions were already parsed by ParseBlockTransactions
+func ProduceBlockFromTransactions(
+	message *L1IncomingMessage,
+	txes types.Transactions,
+	delayedMessagesRead uint64,
+	lastBlockHeader *types.Header,
+	statedb *state.StateDB,
+	chainContext core.ChainContext,
+	chainConfig *params.ChainConfig,
+) (*types.Block, types.Receipts, error) {
 	hooks := noopSequencingHooks()
 	return ProduceBlockAdvanced(
 		message.Header, txes, delayedMessagesRead, lastBlockHeader, statedb, chainContext, chainConfig, hooks,
@@ -234,12 +268,19 @@ func ProduceBlockAdvanced(
 				return nil, nil, core.ErrGasLimitReached
 			}
 
-			sender, err = signer.Sender(tx)
+			// uses the sender cached in the tx if it was already recovered
+			sender, err = types.Sender(signer, tx)
 			if err != nil {
 				return nil, nil, err
 			}
 
-			if err := hooks.PreTxFilter(chainConfig, header, statedb, state, tx, sender); err != nil {
+			var options *arbutil.ConditionalOptions
+			if len(hooks.ConditionalOptionsForTx) > len(hooks.TxErrors) {
+				// TxErrors has an entry for each user tx processed so far, so it indexes this one
+				options = hooks.ConditionalOptionsForTx[len(hooks.TxErrors)]
+			}
+
+			if err := hooks.PreTxFilter(chainConfig, header, statedb, state, tx, options, sender); err != nil {
 				return nil, nil, err
 			}
 
@@ -397,6 +438,12 @@ func ProduceBlockAdvanced(
 		}
 	}
 
+	if sequencingHooks.BlockFilter != nil {
+		if err := sequencingHooks.BlockFilter(header, statedb, complete, receipts); err != nil {
+			return nil, nil, err
+		}
+	}
+
 	binary.BigEndian.PutUint64(header.Nonce[:], delayedMessagesRead)
 	header.Root = statedb.IntermediateRoot(true)
 
@@ -0,0 +1,103 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbos
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// ProduceBlockAdvanced gets each tx's sender with types.Sender, so senders recovered ahead of time are reused.
+// It must give the same results as recovering the sender with the block's signer.
+func TestBlockSenderMatchesSigner(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	Require(t, err)
+	chainConfig := params.ArbitrumDevTestChainConfig()
+	blockNum := big.NewInt(1)
+	signer := types.MakeSigner(chainConfig, blockNum)
+	to := common.Address{1}
+
+	sign := func(txSigner types.Signer, inner types.TxData) *types.Transaction {
+		tx, err := types.SignNewTx(key, txSigner, inner)
+		Require(t, err)
+		return tx
+	}
+	txs := map[string]*types.Transaction{
+		"dynamic fee": sign(signer, &types.DynamicFeeTx{
+			ChainID:   chainConfig.ChainID,
+			GasFeeCap: big.NewInt(params.GWei),
+			Gas:       params.TxGas,
+			To:        &to,
+		}),
+		"replay protected legacy": sign(types.NewEIP155Signer(chainConfig.ChainID), &types.LegacyTx{
+			GasPrice: big.NewInt(params.GWei),
+			Gas:      params.TxGas,
+			To:       &to,
+		}),
+		"unprotected legacy": sign(types.HomesteadSigner{}, &types.LegacyTx{
+			GasPrice: big.NewInt(params.GWei),
+			Gas:      params.TxGas,
+			To:       &to,
+		}),
+		"wrong chain id": sign(types.LatestSignerForChainID(common.Big1), &types.DynamicFeeTx{
+			ChainID:   common.Big1,
+			GasFeeCap: big.NewInt(params.GWei),
+			Gas:       params.TxGas,
+			To:        &to,
+		}),
+		"unsigned": types.NewTx(&types.ArbitrumUnsignedTx{
+			ChainId:   chainConfig.ChainID,
+			From:      common.Address{2},
+			GasFeeCap: big.NewInt(params.GWei),
+			Gas:       params.TxGas,
+			To:        &to,
+			Value:     common.Big0,
+		}),
+	}
+
+	// a copy of the tx without a cached sender
+	uncached := func(tx *types.Transaction) *types.Transaction {
+		data, err := tx.MarshalBinary()
+		Require(t, err)
+		fresh := new(types.Transaction)
+		Require(t, fresh.UnmarshalBinary(data))
+		return fresh
+	}
+	result := func(sender common.Address, err error) string {
+		return fmt.Sprint(sender, err)
+	}
+
+	for name, tx := range txs {
+		expected := result(signer.Sender(uncached(tx)))
+
+		fresh := uncached(tx)
+		if got := result(types.Sender(signer, fresh)); got != expected {
+			Fail(t, name, "tx sender", got, "differs from the signer's", expected)
+		}
+		// the second call uses the sender cached by the first
+		if got := result(types.Sender(signer, fresh)); got != expected {
+			Fail(t, name, "tx cached sender", got, "differs from the signer's", expected)
+		}
+
+		// the message prefetcher recovers senders with its own signer for the block
+		prefetched := uncached(tx)
+		_, _ = types.Sender(types.MakeSigner(chainConfig, new(big.Int).Set(blockNum)), prefetched)
+		if got := result(types.Sender(signer, prefetched)); got != expected {
+			Fail(t, name, "tx prefetched sender", got, "differs from the signer's", expected)
+		}
+
+		// a sender cached by a different signer is recovered again
+		otherCached := uncached(tx)
+		_, _ = types.Sender(types.HomesteadSigner{}, otherCached)
+		if got := result(types.Sender(signer, otherCached)); got != expected {
+			Fail(t, name, "tx sender cached by another signer", got, "differs from the signer's", expected)
+		}
+	}
+}
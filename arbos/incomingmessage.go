@@ -148,7 +148,7 @@ func (h *L1IncomingMessage) FillInBatchGasCost(batchFetcher FallibleBatchFetcher
 	if batchFetcher == nil || h.Header.Kind != L1MessageType_BatchPostingReport || h.BatchGasCost != nil {
 		return nil
 	}
-	_, _, batchHash, batchNum, _, err := parseBatchPostingReportMessageFields(bytes.NewReader(h.L2msg))
+	_, _, batchHash, batchNum, _, err := ParseBatchPostingReportMessageFields(bytes.NewReader(h.L2msg))
 	if err != nil {
 		return fmt.Errorf("failed to parse batch posting report: %w", err)
 	}
@@ -586,7 +586,8 @@ func parseSubmitRetryableMessage(rd io.Reader, header *L1IncomingMessageHeader,
 	return types.NewTx(tx), err
 }
 
-func parseBatchPostingReportMessageFields(rd io.Reader) (*big.Int, common.Address, common.Hash, uint64, *big.Int, error) {
+// ParseBatchPostingReportMessageFields returns the batch timestamp, poster, data hash, number, and L1 base fee of a batch posting report
+func ParseBatchPostingReportMessageFields(rd io.Reader) (*big.Int, common.Address, common.Hash, uint64, *big.Int, error) {
 	batchTimestamp, err := util.HashFromReader(rd)
 	if err != nil {
 		return nil, common.Address{}, common.Hash{}, 0, nil, err
@@ -632,7 +633,7 @@ func computeBatchGasCost(data []byte) uint64 {
 }
 
 func parseBatchPostingReportMessage(rd io.Reader, chainId *big.Int, msgBatchGasCost *uint64, batchFetcher InfallibleBatchFetcher) (*types.Transaction, error) {
-	batchTimestamp, batchPosterAddr, batchHash, batchNum, l1BaseFee, err := parseBatchPostingReportMessageFields(rd)
+	batchTimestamp, batchPosterAddr, batchHash, batchNum, l1BaseFee, err := ParseBatchPostingReportMessageFields(rd)
 	if err != nil {
 		return nil, err
 	}
@@ -31,6 +31,10 @@ var ErrHashMismatch = errors.New("result does not match expected hash")
 // which will retrieve the full batch data.
 const DASMessageHeaderFlag byte = 0x80
 
+// ExternalDAMessageHeaderFlag indicates that this data is a commitment to an experimental external data availability layer.
+// The low four bits select which registered ExternalDAReader resolves it. Not supported when proving.
+const ExternalDAMessageHeaderFlag byte = 0x10
+
 // TreeDASMessageHeaderFlag indicates that this DAS certificate data employs the new merkelization strategy.
 // Ignored when DASMessageHeaderFlag is not set.
 const TreeDASMessageHeaderFlag byte = 0x08
@@ -48,6 +52,10 @@ func IsDASMessageHeaderByte(header byte) bool {
 	return (DASMessageHeaderFlag & header) > 0
 }
 
+func IsExternalDAMessageHeaderByte(header byte) bool {
+	return header&0xf0 == ExternalDAMessageHeaderFlag
+}
+
 func IsTreeDASMessageHeaderByte(header byte) bool {
 	return (TreeDASMessageHeaderFlag & header) > 0
 }
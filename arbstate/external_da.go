@@ -0,0 +1,51 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbstate
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// ExternalDAReader resolves a commitment posted by an external data availability layer into the batch data it commits to.
+// The data hash is checked by the caller, so implementations needn't verify it.
+type ExternalDAReader interface {
+	GetByCommitment(ctx context.Context, dataHash common.Hash, commitment []byte) ([]byte, error)
+}
+
+// ExternalDAReaders maps external data availability header bytes to the readers for sequencer messages with them.
+// Like the DataAvailabilityReader, they're passed explicitly to whatever parses sequencer messages.
+type ExternalDAReaders map[byte]ExternalDAReader
+
+// SerializeExternalDACommitment builds the sequencer message payload posted on chain in place of the batch data:
+// the header byte, the keccak256 hash of the data, and the external layer's commitment.
+func SerializeExternalDACommitment(header byte, dataHash common.Hash, commitment []byte) []byte {
+	serialized := make([]byte, 0, 1+len(dataHash)+len(commitment))
+	serialized = append(serialized, header)
+	serialized = append(serialized, dataHash.Bytes()...)
+	return append(serialized, commitment...)
+}
+
+// RecoverPayloadFromExternalDABatch returns the batch data committed to by an external data availability payload.
+// Like RecoverPayloadFromDasBatch, it returns nil data for invalid payloads, and an error if the data should be retried.
+func RecoverPayloadFromExternalDABatch(ctx context.Context, batchNum uint64, payload []byte, reader ExternalDAReader) ([]byte, error) {
+	if len(payload) < 1+common.HashLength {
+		log.Error("external data availability payload too short", "batch", batchNum, "length", len(payload))
+		return nil, nil
+	}
+	dataHash := common.BytesToHash(payload[1 : 1+common.HashLength])
+	data, err := reader.GetByCommitment(ctx, dataHash, payload[1+common.HashLength:])
+	if err != nil {
+		return nil, err
+	}
+	if crypto.Keccak256Hash(data) != dataHash {
+		return nil, errors.Wrapf(ErrHashMismatch, "external data availability data for batch %v", batchNum)
+	}
+	return data, nil
+}
@@ -86,7 +86,7 @@ const maxZeroheavyDecompressedLen = 101*MaxDecompressedLen/100 + 64
 const MaxSegmentsPerSequencerMessage = 100 * 1024
 const MinLifetimeSecondsForDataAvailabilityCert = 7 * 24 * 60 * 60 // one week
 
-func parseSequencerMessage(ctx context.Context, batchNum uint64, data []byte, dasReader DataAvailabilityReader, keysetValidationMode KeysetValidationMode) (*sequencerMessage, error) {
+func parseSequencerMessage(ctx context.Context, batchNum uint64, data []byte, dasReader DataAvailabilityReader, externalDAReaders ExternalDAReaders, keysetValidationMode KeysetValidationMode) (*sequencerMessage, error) {
 	if len(data) < 40 {
 		return nil, errors.New("sequencer message missing L1 header")
 	}
@@ -100,6 +100,22 @@ func parseSequencerMessage(ctx context.Context, batchNum uint64, data []byte, da
 	}
 	payload := data[40:]
 
+	if len(payload) > 0 && IsExternalDAMessageHeaderByte(payload[0]) {
+		// Without a reader the batch can't be derived, so it's an error to be retried rather than an empty batch
+		reader := externalDAReaders[payload[0]]
+		if reader == nil {
+			return nil, errors.Errorf("no external data availability reader configured, but sequencer message %v found with its header %#x", batchNum, payload[0])
+		}
+		var err error
+		payload, err = RecoverPayloadFromExternalDABatch(ctx, batchNum, payload, reader)
+		if err != nil {
+			return nil, err
+		}
+		if payload == nil {
+			return parsedMsg, nil
+		}
+	}
+
 	if len(payload) > 0 && IsDASMessageHeaderByte(payload[0]) {
 		if dasReader == nil {
 			log.Error("No DAS Reader configured, but sequencer message found with DAS header")
@@ -160,6 +176,33 @@ func parseSequencerMessage(ctx context.Context, batchNum uint64, data []byte, da
 	return parsedMsg, nil
 }
 
+// DecodedSequencerMessage is a sequencer message with its L1 header parsed,
+// and its data availability and compression layers removed.
+type DecodedSequencerMessage struct {
+	MinTimestamp         uint64
+	MaxTimestamp         uint64
+	MinL1Block           uint64
+	MaxL1Block           uint64
+	AfterDelayedMessages uint64
+	Segments             [][]byte
+}
+
+// DecodeSequencerMessage parses a sequencer message exactly as the inbox multiplexer does, for use by tooling.
+func DecodeSequencerMessage(ctx context.Context, batchNum uint64, data []byte, dasReader DataAvailabilityReader, externalDAReaders ExternalDAReaders, keysetValidationMode KeysetValidationMode) (*DecodedSequencerMessage, error) {
+	parsed, err := parseSequencerMessage(ctx, batchNum, data, dasReader, externalDAReaders, keysetValidationMode)
+	if err != nil {
+		return nil, err
+	}
+	return &DecodedSequencerMessage{
+		MinTimestamp:         parsed.minTimestamp,
+		MaxTimestamp:         parsed.maxTimestamp,
+		MinL1Block:           parsed.minL1Block,
+		MaxL1Block:           parsed.maxL1Block,
+		AfterDelayedMessages: parsed.afterDelayedMessages,
+		Segments:             parsed.segments,
+	}, nil
+}
+
 func RecoverPayloadFromDasBatch(
 	ctx context.Context,
 	batchNum uint64,
@@ -271,6 +314,7 @@ type inboxMultiplexer struct {
 	backend                   InboxBackend
 	delayedMessagesRead       uint64
 	dasReader                 DataAvailabilityReader
+	externalDAReaders         ExternalDAReaders
 	cachedSequencerMessage    *sequencerMessage
 	cachedSequencerMessageNum uint64
 	cachedSegmentNum          uint64
@@ -280,11 +324,12 @@ type inboxMultiplexer struct {
 	keysetValidationMode      KeysetValidationMode
 }
 
-func NewInboxMultiplexer(backend InboxBackend, delayedMessagesRead uint64, dasReader DataAvailabilityReader, keysetValidationMode KeysetValidationMode) InboxMultiplexer {
+func NewInboxMultiplexer(backend InboxBackend, delayedMessagesRead uint64, dasReader DataAvailabilityReader, externalDAReaders ExternalDAReaders, keysetValidationMode KeysetValidationMode) InboxMultiplexer {
 	return &inboxMultiplexer{
 		backend:              backend,
 		delayedMessagesRead:  delayedMessagesRead,
 		dasReader:            dasReader,
+		externalDAReaders:    externalDAReaders,
 		keysetValidationMode: keysetValidationMode,
 	}
 }
@@ -312,7 +357,7 @@ func (r *inboxMultiplexer) Pop(ctx context.Context) (*MessageWithMetadata, error
 		}
 		r.cachedSequencerMessageNum = r.backend.GetSequencerInboxPosition()
 		var err error
-		r.cachedSequencerMessage, err = parseSequencerMessage(ctx, r.cachedSequencerMessageNum, bytes, r.dasReader, r.keysetValidationMode)
+		r.cachedSequencerMessage, err = parseSequencerMessage(ctx, r.cachedSequencerMessageNum, bytes, r.dasReader, r.externalDAReaders, r.keysetValidationMode)
 		if err != nil {
 			return nil, err
 		}
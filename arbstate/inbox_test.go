@@ -0,0 +1,99 @@
+// Copyright 2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbstate
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+
+	"github.com/offchainlabs/nitro/arbcompress"
+)
+
+func TestDecodeSequencerMessage(t *testing.T) {
+	advanceTimestamp, err := rlp.EncodeToBytes(uint64(5))
+	Require(t, err)
+	segments := [][]byte{
+		append([]byte{BatchSegmentKindAdvanceTimestamp}, advanceTimestamp...),
+		{BatchSegmentKindL2Message, 1, 2, 3},
+		{BatchSegmentKindDelayedMessages},
+	}
+	var encoded []byte
+	for _, segment := range segments {
+		segmentBytes, err := rlp.EncodeToBytes(segment)
+		Require(t, err)
+		encoded = append(encoded, segmentBytes...)
+	}
+	compressed, err := arbcompress.CompressWell(encoded)
+	Require(t, err)
+
+	data := make([]byte, 40)
+	binary.BigEndian.PutUint64(data[:8], 100)
+	binary.BigEndian.PutUint64(data[8:16], 200)
+	binary.BigEndian.PutUint64(data[16:24], 10)
+	binary.BigEndian.PutUint64(data[24:32], 20)
+	binary.BigEndian.PutUint64(data[32:40], 7)
+	data = append(data, BrotliMessageHeaderByte)
+	data = append(data, compressed...)
+
+	decoded, err := DecodeSequencerMessage(context.Background(), 3, data, nil, nil, KeysetValidate)
+	Require(t, err)
+	if decoded.MinTimestamp != 100 || decoded.MaxTimestamp != 200 || decoded.MinL1Block != 10 || decoded.MaxL1Block != 20 || decoded.AfterDelayedMessages != 7 {
+		Fail(t, "unexpected decoded header", decoded)
+	}
+	if len(decoded.Segments) != len(segments) {
+		Fail(t, "decoded", len(decoded.Segments), "segments, expected", len(segments))
+	}
+	for i, segment := range segments {
+		if !bytes.Equal(decoded.Segments[i], segment) {
+			Fail(t, "segment", i, "decoded as", decoded.Segments[i], "expected", segment)
+		}
+	}
+
+	if _, err := DecodeSequencerMessage(context.Background(), 3, data[:39], nil, nil, KeysetValidate); err == nil {
+		Fail(t, "decoded a sequencer message without a full L1 header")
+	}
+}
+
+type testExternalDAReader struct {
+	data []byte
+}
+
+func (r testExternalDAReader) GetByCommitment(ctx context.Context, dataHash common.Hash, commitment []byte) ([]byte, error) {
+	return r.data, nil
+}
+
+func TestExternalDAHeaderWithoutReader(t *testing.T) {
+	batch := []byte{BatchSegmentKindL2Message, 1, 2, 3}
+	segment, err := rlp.EncodeToBytes(batch)
+	Require(t, err)
+	compressed, err := arbcompress.CompressWell(segment)
+	Require(t, err)
+	batchData := append([]byte{BrotliMessageHeaderByte}, compressed...)
+
+	for header := ExternalDAMessageHeaderFlag; header < ExternalDAMessageHeaderFlag+0x10; header++ {
+		data := make([]byte, 40)
+		data = append(data, SerializeExternalDACommitment(header, crypto.Keccak256Hash(batchData), []byte{1, 2})...)
+		// Without a reader the batch can't be derived, so it must not be read as empty
+		if _, err := DecodeSequencerMessage(context.Background(), 1, data, nil, nil, KeysetValidate); err == nil {
+			Fail(t, "decoded a sequencer message without a reader for header", header)
+		}
+		others := ExternalDAReaders{header ^ 1: testExternalDAReader{batchData}}
+		if _, err := DecodeSequencerMessage(context.Background(), 1, data, nil, others, KeysetValidate); err == nil {
+			Fail(t, "decoded a sequencer message with a reader for another header than", header)
+		}
+
+		readers := ExternalDAReaders{header: testExternalDAReader{batchData}}
+		decoded, err := DecodeSequencerMessage(context.Background(), 1, data, nil, readers, KeysetValidate)
+		Require(t, err)
+		if len(decoded.Segments) != 1 || !bytes.Equal(decoded.Segments[0], batch) {
+			Fail(t, "unexpected segments read through the reader for header", header, decoded.Segments)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbutil
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/state"
+)
+
+// MaxConditionalOptionsSize limits the number of accounts and storage slots a conditional transaction may check
+const MaxConditionalOptionsSize = 1000
+
+// ConditionalOptionsRejectedError is returned when a transaction's conditions aren't met,
+// using the "transaction rejected" code of EIP-1474
+type ConditionalOptionsRejectedError struct {
+	message string
+}
+
+func (e *ConditionalOptionsRejectedError) Error() string {
+	return e.message
+}
+
+func (e *ConditionalOptionsRejectedError) ErrorCode() int {
+	return -32003
+}
+
+func newConditionalOptionsRejectedError(format string, args ...interface{}) error {
+	return &ConditionalOptionsRejectedError{fmt.Sprintf("conditional transaction rejected: "+format, args...)}
+}
+
+// ConditionalOptionsLimitError is returned when a transaction has too many conditions,
+// using the "limit exceeded" code of EIP-1474
+type ConditionalOptionsLimitError struct {
+	message string
+}
+
+func (e *ConditionalOptionsLimitError) Error() string {
+	return e.message
+}
+
+func (e *ConditionalOptionsLimitError) ErrorCode() int {
+	return -32005
+}
+
+// RootHashOrSlots is either the expected storage root of an account, or expected values of some of its storage slots.
+// In JSON, it's either a hash or an object mapping slots to values.
+type RootHashOrSlots struct {
+	RootHash  *common.Hash
+	SlotValue map[common.Hash]common.Hash
+}
+
+func (r RootHashOrSlots) MarshalJSON() ([]byte, error) {
+	if r.RootHash != nil {
+		return json.Marshal(r.RootHash)
+	}
+	return json.Marshal(r.SlotValue)
+}
+
+func (r *RootHashOrSlots) UnmarshalJSON(data []byte) error {
+	var hash common.Hash
+	if err := json.Unmarshal(data, &hash); err == nil {
+		r.RootHash = &hash
+		r.SlotValue = nil
+		return nil
+	}
+	var slots map[common.Hash]common.Hash
+	if err := json.Unmarshal(data, &slots); err != nil {
+		return fmt.Errorf("known account must be a storage root or a map of storage slots to values: %w", err)
+	}
+	r.RootHash = nil
+	r.SlotValue = slots
+	return nil
+}
+
+// ConditionalOptions are preconditions on the block and state a transaction is sequenced on.
+// A transaction whose conditions aren't met is rejected instead of being sequenced.
+type ConditionalOptions struct {
+	KnownAccounts  map[common.Address]RootHashOrSlots `json:"knownAccounts,omitempty"`
+	BlockNumberMin *hexutil.Uint64                    `json:"blockNumberMin,omitempty"`
+	BlockNumberMax *hexutil.Uint64                    `json:"blockNumberMax,omitempty"`
+	TimestampMin   *hexutil.Uint64                    `json:"timestampMin,omitempty"`
+	TimestampMax   *hexutil.Uint64                    `json:"timestampMax,omitempty"`
+}
+
+// Size is the number of storage roots and slots the options check
+func (o *ConditionalOptions) Size() int {
+	size := 0
+	for _, account := range o.KnownAccounts {
+		if account.RootHash != nil {
+			size++
+		} else {
+			size += len(account.SlotValue)
+		}
+	}
+	return size
+}
+
+func (o *ConditionalOptions) Validate() error {
+	if size := o.Size(); size > MaxConditionalOptionsSize {
+		return &ConditionalOptionsLimitError{fmt.Sprintf("too many known accounts and storage slots in conditional options: %v, maximum %v", size, MaxConditionalOptionsSize)}
+	}
+	return nil
+}
+
+// CheckBlock checks the block number and timestamp conditions against the block being built
+func (o *ConditionalOptions) CheckBlock(blockNumber uint64, timestamp uint64) error {
+	if o.BlockNumberMin != nil && blockNumber < uint64(*o.BlockNumberMin) {
+		return newConditionalOptionsRejectedError("block number %v is before minimum %v", blockNumber, uint64(*o.BlockNumberMin))
+	}
+	if o.BlockNumberMax != nil && blockNumber > uint64(*o.BlockNumberMax) {
+		return newConditionalOptionsRejectedError("block number %v is past maximum %v", blockNumber, uint64(*o.BlockNumberMax))
+	}
+	if o.TimestampMin != nil && timestamp < uint64(*o.TimestampMin) {
+		return newConditionalOptionsRejectedError("timestamp %v is before minimum %v", timestamp, uint64(*o.TimestampMin))
+	}
+	if o.TimestampMax != nil && timestamp > uint64(*o.TimestampMax) {
+		return newConditionalOptionsRejectedError("timestamp %v is past maximum %v", timestamp, uint64(*o.TimestampMax))
+	}
+	return nil
+}
+
+// CheckState checks the known account conditions against the state the transaction would execute on
+func (o *ConditionalOptions) CheckState(statedb *state.StateDB) error {
+	for address, account := range o.KnownAccounts {
+		if account.RootHash != nil {
+			// StorageTrie includes any storage changes made earlier in the block being built
+			trie := statedb.StorageTrie(address)
+			if trie == nil {
+				return newConditionalOptionsRejectedError("storage of account %v not found", address)
+			}
+			if trie.Hash() != *account.RootHash {
+				return newConditionalOptionsRejectedError("storage root of account %v doesn't match", address)
+			}
+			continue
+		}
+		for slot, value := range account.SlotValue {
+			if statedb.GetState(address, slot) != value {
+				return newConditionalOptionsRejectedError("storage slot %v of account %v doesn't match", slot, address)
+			}
+		}
+	}
+	return nil
+}
+
+func (o *ConditionalOptions) Check(blockNumber uint64, timestamp uint64, statedb *state.StateDB) error {
+	if err := o.CheckBlock(blockNumber, timestamp); err != nil {
+		return err
+	}
+	return o.CheckState(statedb)
+}
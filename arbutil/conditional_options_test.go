@@ -0,0 +1,84 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbutil
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+)
+
+func expectConditionalRejection(t *testing.T, err error, rejected bool) {
+	t.Helper()
+	var rejectedErr *ConditionalOptionsRejectedError
+	if errors.As(err, &rejectedErr) != rejected {
+		t.Fatal("unexpected conditional options check result", err)
+	}
+	if !rejected && err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConditionalOptions(t *testing.T) {
+	account := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	other := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	slot := common.HexToHash("0x01")
+	value := common.HexToHash("0x2a")
+
+	statedb, err := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statedb.SetState(account, slot, value)
+	root := statedb.StorageTrie(account).Hash()
+
+	var options ConditionalOptions
+	err = json.Unmarshal([]byte(`{
+		"knownAccounts": {
+			"0x1111111111111111111111111111111111111111": {"0x0000000000000000000000000000000000000000000000000000000000000001": "0x000000000000000000000000000000000000000000000000000000000000002a"}
+		},
+		"blockNumberMin": "0x10",
+		"blockNumberMax": "0x20",
+		"timestampMax": "0x1000"
+	}`), &options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if options.Size() != 1 {
+		t.Fatal("unexpected options size", options.Size())
+	}
+
+	expectConditionalRejection(t, options.Check(0x10, 0x1000, statedb), false)
+	expectConditionalRejection(t, options.Check(0x0f, 0x1000, statedb), true)
+	expectConditionalRejection(t, options.Check(0x21, 0x1000, statedb), true)
+	expectConditionalRejection(t, options.Check(0x10, 0x1001, statedb), true)
+
+	// Slot conditions see changes made earlier in the block
+	statedb.SetState(account, slot, common.HexToHash("0x2b"))
+	expectConditionalRejection(t, options.Check(0x10, 0x1000, statedb), true)
+	statedb.SetState(account, slot, value)
+
+	// Storage roots round trip through JSON as plain hashes
+	options = ConditionalOptions{
+		KnownAccounts: map[common.Address]RootHashOrSlots{
+			account: {RootHash: &root},
+		},
+	}
+	data, err := json.Marshal(&options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded ConditionalOptions
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	expectConditionalRejection(t, decoded.CheckState(statedb), false)
+
+	decoded.KnownAccounts[other] = RootHashOrSlots{RootHash: &root}
+	expectConditionalRejection(t, decoded.CheckState(statedb), true)
+}
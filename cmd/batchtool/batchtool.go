@@ -0,0 +1,370 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rlp"
+
+	"github.com/offchainlabs/nitro/arbcompress"
+	"github.com/offchainlabs/nitro/arbnode"
+	"github.com/offchainlabs/nitro/arbos"
+	"github.com/offchainlabs/nitro/arbos/l1pricing"
+	"github.com/offchainlabs/nitro/arbstate"
+	"github.com/offchainlabs/nitro/cmd/genericconf"
+	"github.com/offchainlabs/nitro/cmd/util/confighelpers"
+	"github.com/offchainlabs/nitro/das"
+	"github.com/offchainlabs/nitro/solgen/go/bridgegen"
+)
+
+type BatchToolConfig struct {
+	L1URL          string                 `koanf:"l1-url"`
+	SequencerInbox string                 `koanf:"sequencer-inbox"`
+	FromBlock      uint64                 `koanf:"from-block"`
+	ChainId        uint64                 `koanf:"chain-id"`
+	TxHash         string                 `koanf:"tx"`
+	Batch          int64                  `koanf:"batch"`
+	File           string                 `koanf:"file"`
+	PrevDelayed    int64                  `koanf:"prev-delayed-messages"`
+	DASURL         string                 `koanf:"das-url"`
+	ExternalDA     das.ExternalDAConfig   `koanf:"external-da"`
+	ConfConfig     genericconf.ConfConfig `koanf:"conf"`
+}
+
+func parseBatchToolConfig(args []string) (*BatchToolConfig, error) {
+	f := flag.NewFlagSet("batchtool", flag.ContinueOnError)
+	f.String("l1-url", "", "URL of the L1 node to fetch batches from")
+	f.String("sequencer-inbox", "", "address of the sequencer inbox contract")
+	f.Uint64("from-block", 0, "L1 block the sequencer inbox was deployed at, to bound searching for a batch number")
+	f.Uint64("chain-id", 42161, "L2 chain id, used to decode unsigned transactions")
+	f.String("tx", "", "hash of the L1 transaction that posted the batch")
+	f.Int64("batch", -1, "sequence number of the batch to decode")
+	f.String("file", "", "file containing a raw sequencer message, including its 40 byte L1 header")
+	f.Int64("prev-delayed-messages", -1, "delayed message count after the previous batch, which numbers the delayed messages read by a --file (read from L1 otherwise)")
+	f.String("das-url", "", "URL of a REST DAS endpoint to resolve data availability certificates with")
+	das.ExternalDAConfigAddOptions("external-da", f)
+	genericconf.ConfConfigAddOptions("conf", f)
+
+	k, err := confighelpers.BeginCommonParse(f, args)
+	if err != nil {
+		return nil, err
+	}
+
+	var config BatchToolConfig
+	if err := confighelpers.EndCommonParse(k, &config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+func main() {
+	if err := run(context.Background(), os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string) error {
+	config, err := parseBatchToolConfig(args)
+	if err != nil {
+		return err
+	}
+
+	var dasReader arbstate.DataAvailabilityReader
+	if config.DASURL != "" {
+		dasReader, err = das.NewRestfulDasClientFromURL(config.DASURL)
+		if err != nil {
+			return err
+		}
+	}
+
+	var externalDAReaders arbstate.ExternalDAReaders
+	if config.ExternalDA.Mode != "" {
+		externalDA, err := das.NewExternalDA(&config.ExternalDA)
+		if err != nil {
+			return err
+		}
+		externalDAReaders = arbstate.ExternalDAReaders{config.ExternalDA.HeaderByte: externalDA}
+	}
+
+	var batchNum uint64
+	var sequencerMsg []byte
+	// The delayed messages read by the batch start from where the previous batch left off
+	var prevDelayed *uint64
+	if config.File != "" {
+		sequencerMsg, err = os.ReadFile(config.File)
+		if err != nil {
+			return err
+		}
+		if config.Batch >= 0 {
+			batchNum = uint64(config.Batch)
+		}
+		if config.PrevDelayed >= 0 {
+			prevDelayedCount := uint64(config.PrevDelayed)
+			prevDelayed = &prevDelayedCount
+		}
+	} else {
+		batchNum, sequencerMsg, prevDelayed, err = fetchBatch(ctx, config)
+		if err != nil {
+			return err
+		}
+	}
+
+	decoded, err := arbstate.DecodeSequencerMessage(ctx, batchNum, sequencerMsg, dasReader, externalDAReaders, arbstate.KeysetDontValidate)
+	if err != nil {
+		return err
+	}
+	printSequencerMessage(batchNum, sequencerMsg, decoded, prevDelayed, new(big.Int).SetUint64(config.ChainId))
+	return nil
+}
+
+// fetchBatch fetches and serializes a batch from L1, by either the transaction that posted it or its sequence number.
+// It also returns the delayed message count after the previous batch, if that could be found.
+func fetchBatch(ctx context.Context, config *BatchToolConfig) (uint64, []byte, *uint64, error) {
+	if config.L1URL == "" || !common.IsHexAddress(config.SequencerInbox) {
+		return 0, nil, nil, errors.New("--l1-url and --sequencer-inbox are required unless decoding a --file")
+	}
+	client, err := ethclient.DialContext(ctx, config.L1URL)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	inboxAddr := common.HexToAddress(config.SequencerInbox)
+	inbox, err := arbnode.NewSequencerInbox(client, inboxAddr, int64(config.FromBlock))
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	var blockNum, batchNum uint64
+	if config.TxHash != "" {
+		blockNum, batchNum, err = findBatchByTx(ctx, client, inboxAddr, common.HexToHash(config.TxHash))
+	} else if config.Batch >= 0 {
+		batchNum = uint64(config.Batch)
+		blockNum, err = findBatchBlock(ctx, client, inbox, batchNum, config.FromBlock)
+	} else {
+		return 0, nil, nil, errors.New("one of --tx, --batch or --file must be specified")
+	}
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	batch, err := lookupBatch(ctx, inbox, batchNum, blockNum)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	data, err := batch.Serialize(ctx, client)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	var prevDelayed uint64
+	if batchNum > 0 {
+		prevBlockNum, err := findBatchBlock(ctx, client, inbox, batchNum-1, config.FromBlock)
+		if err == nil {
+			var prevBatch *arbnode.SequencerInboxBatch
+			prevBatch, err = lookupBatch(ctx, inbox, batchNum-1, prevBlockNum)
+			if err == nil {
+				prevDelayed = prevBatch.AfterDelayedCount
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to find the previous batch's delayed message count: %v\n", err)
+			return batchNum, data, nil, nil
+		}
+	}
+	return batchNum, data, &prevDelayed, nil
+}
+
+func lookupBatch(ctx context.Context, inbox *arbnode.SequencerInbox, batchNum uint64, blockNum uint64) (*arbnode.SequencerInboxBatch, error) {
+	block := new(big.Int).SetUint64(blockNum)
+	batches, err := inbox.LookupBatchesInRange(ctx, block, block)
+	if err != nil {
+		return nil, err
+	}
+	for _, batch := range batches {
+		if batch.SequenceNumber == batchNum {
+			return batch, nil
+		}
+	}
+	return nil, fmt.Errorf("batch %v not found in L1 block %v", batchNum, blockNum)
+}
+
+func findBatchByTx(ctx context.Context, client *ethclient.Client, inboxAddr common.Address, txHash common.Hash) (uint64, uint64, error) {
+	receipt, err := client.TransactionReceipt(ctx, txHash)
+	if err != nil {
+		return 0, 0, err
+	}
+	filterer, err := bridgegen.NewSequencerInboxFilterer(inboxAddr, client)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, log := range receipt.Logs {
+		if log.Address != inboxAddr {
+			continue
+		}
+		delivered, err := filterer.ParseSequencerBatchDelivered(*log)
+		if err != nil {
+			continue
+		}
+		return receipt.BlockNumber.Uint64(), delivered.BatchSequenceNumber.Uint64(), nil
+	}
+	return 0, 0, fmt.Errorf("transaction %v didn't deliver a sequencer batch", txHash)
+}
+
+// findBatchBlock binary searches for the L1 block a batch was posted in, which requires an archive L1 node
+func findBatchBlock(ctx context.Context, client *ethclient.Client, inbox *arbnode.SequencerInbox, batchNum uint64, fromBlock uint64) (uint64, error) {
+	latest, err := client.BlockNumber(ctx)
+	if err != nil {
+		return 0, err
+	}
+	count, err := inbox.GetBatchCount(ctx, new(big.Int).SetUint64(latest))
+	if err != nil {
+		return 0, err
+	}
+	if count <= batchNum {
+		return 0, fmt.Errorf("batch %v hasn't been posted yet, batch count is %v", batchNum, count)
+	}
+	low, high := fromBlock, latest
+	for low < high {
+		mid := low + (high-low)/2
+		count, err := inbox.GetBatchCount(ctx, new(big.Int).SetUint64(mid))
+		if err != nil {
+			return 0, err
+		}
+		if count > batchNum {
+			high = mid
+		} else {
+			low = mid + 1
+		}
+	}
+	return low, nil
+}
+
+func printSequencerMessage(batchNum uint64, sequencerMsg []byte, decoded *arbstate.DecodedSequencerMessage, prevDelayed *uint64, chainId *big.Int) {
+	fmt.Printf("Batch %v (%v bytes)\n", batchNum, len(sequencerMsg))
+	fmt.Printf("  timestamp bounds: %v - %v\n", decoded.MinTimestamp, decoded.MaxTimestamp)
+	fmt.Printf("  L1 block bounds: %v - %v\n", decoded.MinL1Block, decoded.MaxL1Block)
+	if len(sequencerMsg) > 40 {
+		payload := sequencerMsg[40:]
+		switch {
+		case arbstate.IsDASMessageHeaderByte(payload[0]):
+			fmt.Printf("  layers: data availability certificate\n")
+		case arbstate.IsZeroheavyEncodedHeaderByte(payload[0]):
+			fmt.Printf("  layers: zeroheavy\n")
+		case arbstate.IsBrotliMessageHeaderByte(payload[0]):
+			fmt.Printf("  layers: brotli\n")
+		}
+	}
+	fmt.Printf("  segments: %v\n", len(decoded.Segments))
+
+	var delayedRead uint64
+	if prevDelayed != nil {
+		delayedRead = *prevDelayed
+		fmt.Printf("  delayed messages: %v before, %v after\n", delayedRead, decoded.AfterDelayedMessages)
+	} else {
+		fmt.Printf("  delayed messages: unknown before (see --prev-delayed-messages), %v after\n", decoded.AfterDelayedMessages)
+	}
+
+	var timestamp, blockNumber uint64
+	for i, segment := range decoded.Segments {
+		if len(segment) == 0 {
+			fmt.Printf("[%v] empty segment\n", i)
+			continue
+		}
+		kind := segment[0]
+		segment = segment[1:]
+		switch kind {
+		case arbstate.BatchSegmentKindAdvanceTimestamp, arbstate.BatchSegmentKindAdvanceL1BlockNumber:
+			advancing, err := rlp.NewStream(bytes.NewReader(segment), 16).Uint64()
+			if err != nil {
+				fmt.Printf("[%v] invalid advancing segment: %v\n", i, err)
+				continue
+			}
+			if kind == arbstate.BatchSegmentKindAdvanceTimestamp {
+				timestamp += advancing
+				fmt.Printf("[%v] advance timestamp by %v to %v\n", i, advancing, clamp(timestamp, decoded.MinTimestamp, decoded.MaxTimestamp))
+			} else {
+				blockNumber += advancing
+				fmt.Printf("[%v] advance L1 block by %v to %v\n", i, advancing, clamp(blockNumber, decoded.MinL1Block, decoded.MaxL1Block))
+			}
+		case arbstate.BatchSegmentKindDelayedMessages:
+			if prevDelayed == nil {
+				fmt.Printf("[%v] read the next delayed message\n", i)
+			} else if delayedRead >= decoded.AfterDelayedMessages {
+				// The multiplexer turns reads past the batch's delayed message count into invalid messages
+				fmt.Printf("[%v] invalid read past the batch's delayed message count %v\n", i, decoded.AfterDelayedMessages)
+			} else {
+				fmt.Printf("[%v] read delayed message %v\n", i, delayedRead)
+				delayedRead++
+			}
+		case arbstate.BatchSegmentKindL2Message, arbstate.BatchSegmentKindL2MessageBrotli:
+			if kind == arbstate.BatchSegmentKindL2MessageBrotli {
+				decompressed, err := arbcompress.Decompress(segment, arbos.MaxL2MessageSize)
+				if err != nil {
+					fmt.Printf("[%v] invalid brotli L2 message: %v\n", i, err)
+					continue
+				}
+				segment = decompressed
+			}
+			printL2Message(i, segment, clamp(timestamp, decoded.MinTimestamp, decoded.MaxTimestamp), clamp(blockNumber, decoded.MinL1Block, decoded.MaxL1Block), chainId)
+		default:
+			fmt.Printf("[%v] unknown segment kind %v (%v bytes)\n", i, kind, len(segment))
+		}
+	}
+	// After the batch's segments, the multiplexer reads any delayed messages left up to the batch's delayed message count
+	if prevDelayed != nil && delayedRead < decoded.AfterDelayedMessages {
+		fmt.Printf("[end] implicitly read delayed messages %v to %v\n", delayedRead, decoded.AfterDelayedMessages-1)
+	}
+}
+
+func printL2Message(index int, l2msg []byte, timestamp uint64, blockNumber uint64, chainId *big.Int) {
+	msg := &arbos.L1IncomingMessage{
+		Header: &arbos.L1IncomingMessageHeader{
+			Kind:        arbos.L1MessageType_L2Message,
+			Poster:      l1pricing.BatchPosterAddress,
+			BlockNumber: blockNumber,
+			Timestamp:   timestamp,
+			L1BaseFee:   big.NewInt(0),
+		},
+		L2msg: l2msg,
+	}
+	txes, err := msg.ParseL2Transactions(chainId, func(uint64, common.Hash) []byte { return nil })
+	if err != nil {
+		fmt.Printf("[%v] L2 message (%v bytes) failed to parse: %v\n", index, len(l2msg), err)
+		return
+	}
+	fmt.Printf("[%v] L2 message (%v bytes) with %v transactions\n", index, len(l2msg), len(txes))
+	signer := types.LatestSignerForChainID(chainId)
+	for _, tx := range txes {
+		from := "unknown"
+		if sender, err := types.Sender(signer, tx); err == nil {
+			from = sender.Hex()
+		}
+		to := "contract creation"
+		if tx.To() != nil {
+			to = tx.To().Hex()
+		}
+		fmt.Printf("      tx %v type %v from %v to %v nonce %v value %v gas %v data %v bytes\n", tx.Hash(), tx.Type(), from, to, tx.Nonce(), tx.Value(), tx.Gas(), len(tx.Data()))
+	}
+}
+
+func clamp(val, min, max uint64) uint64 {
+	if val < min {
+		return min
+	}
+	if val > max {
+		return max
+	}
+	return val
+}
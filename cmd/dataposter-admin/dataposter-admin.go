@@ -0,0 +1,105 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/offchainlabs/nitro/arbnode"
+)
+
+const usage = `Usage: dataposter-admin [node rpc url] [command]
+
+Commands:
+  list                          list the batch poster's queued transactions
+  rbf [nonce] [fee cap wei]     replace the queued transaction with the given nonce, using the given fee cap
+  cancel [nonce] [fee cap wei]  replace the given nonce with a zero value transfer to the batch poster itself
+  resync                        discard the queue, resuming posting from the L1 nonce
+
+The node must serve the batchposter API, and hold the data poster's redis lock if one is configured.
+`
+
+func main() {
+	if err := run(context.Background(), os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+}
+
+func parseNonceAndFeeCap(args []string) (hexutil.Uint64, *hexutil.Big, error) {
+	if len(args) != 2 {
+		return 0, nil, errors.New(usage)
+	}
+	nonce, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to parse nonce: %w", err)
+	}
+	feeCap, ok := new(big.Int).SetString(args[1], 10)
+	if !ok || feeCap.Sign() <= 0 {
+		return 0, nil, fmt.Errorf("invalid fee cap \"%v\"", args[1])
+	}
+	return hexutil.Uint64(nonce), (*hexutil.Big)(feeCap), nil
+}
+
+func run(ctx context.Context, args []string) error {
+	if len(args) < 2 {
+		return errors.New(usage)
+	}
+	client, err := rpc.DialContext(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	command, args := args[1], args[2:]
+	switch command {
+	case "list":
+		if len(args) != 0 {
+			return errors.New(usage)
+		}
+		var queued []arbnode.BatchPosterQueuedTx
+		if err := client.CallContext(ctx, &queued, "batchposter_queuedTransactions"); err != nil {
+			return err
+		}
+		encoded, err := json.MarshalIndent(queued, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(encoded))
+	case "rbf", "cancel":
+		nonce, feeCap, err := parseNonceAndFeeCap(args)
+		if err != nil {
+			return err
+		}
+		method := "batchposter_replaceByFee"
+		if command == "cancel" {
+			method = "batchposter_cancelNonce"
+		}
+		if err := client.CallContext(ctx, nil, method, nonce, feeCap); err != nil {
+			return err
+		}
+		fmt.Printf("sent replacement for nonce %v with fee cap %v\n", uint64(nonce), feeCap.ToInt())
+	case "resync":
+		if len(args) != 0 {
+			return errors.New(usage)
+		}
+		var nonce hexutil.Uint64
+		if err := client.CallContext(ctx, &nonce, "batchposter_resyncQueue"); err != nil {
+			return err
+		}
+		fmt.Printf("discarded queue, posting will resume at nonce %v\n", uint64(nonce))
+	default:
+		return errors.New(usage)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/offchainlabs/nitro/arbnode"
+)
+
+const usage = `Usage: message-archive [command]
+
+Commands:
+  export [node rpc url] [from batch] [to batch] [name]  have the node write the batches, their messages, and the delayed messages they read to an archive in its export directory
+  verify [node rpc url]                                 check the node's latest batch and delayed message accumulators match L1
+  inspect [path]                                        check an archive's checksum and print its header
+
+To import an archive, start a node whose database ends where the archive starts with --node.message-archive.import-file.
+The node must serve the arbarchive API for export and verify, and needs --node.message-archive.export-dir set to export.
+`
+
+func main() {
+	if err := run(context.Background(), os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+}
+
+func printJSON(val interface{}) error {
+	encoded, err := json.MarshalIndent(val, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(encoded))
+	return nil
+}
+
+func run(ctx context.Context, args []string) error {
+	if len(args) < 2 {
+		return errors.New(usage)
+	}
+	command, args := args[0], args[1:]
+	switch command {
+	case "export":
+		if len(args) != 4 {
+			return errors.New(usage)
+		}
+		fromBatch, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("failed to parse from batch: %w", err)
+		}
+		toBatch, err := strconv.ParseUint(args[2], 10, 64)
+		if err != nil {
+			return fmt.Errorf("failed to parse to batch: %w", err)
+		}
+		client, err := rpc.DialContext(ctx, args[0])
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+		var header arbnode.MessageArchiveHeader
+		if err := client.CallContext(ctx, &header, "arbarchive_exportBatches", fromBatch, toBatch, args[3]); err != nil {
+			return err
+		}
+		return printJSON(header)
+	case "verify":
+		if len(args) != 1 {
+			return errors.New(usage)
+		}
+		client, err := rpc.DialContext(ctx, args[0])
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+		if err := client.CallContext(ctx, nil, "arbarchive_verifyAgainstL1"); err != nil {
+			return err
+		}
+		fmt.Println("the node's inbox matches L1")
+	case "inspect":
+		if len(args) != 1 {
+			return errors.New(usage)
+		}
+		file, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		header, err := arbnode.VerifyMessageArchive(file)
+		if err != nil {
+			return err
+		}
+		return printJSON(header)
+	default:
+		return errors.New(usage)
+	}
+	return nil
+}
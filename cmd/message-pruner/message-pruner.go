@@ -0,0 +1,113 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	"github.com/offchainlabs/nitro/arbnode"
+	"github.com/offchainlabs/nitro/cmd/genericconf"
+	"github.com/offchainlabs/nitro/cmd/util/confighelpers"
+	"github.com/offchainlabs/nitro/validator"
+)
+
+type MessagePrunerToolConfig struct {
+	Database         string                 `koanf:"database"`
+	ChainDatabase    string                 `koanf:"chain-database"`
+	L1URL            string                 `koanf:"l1-url"`
+	Rollup           string                 `koanf:"rollup"`
+	RetentionBatches uint64                 `koanf:"retention-batches"`
+	DryRun           bool                   `koanf:"dry-run"`
+	ConfConfig       genericconf.ConfConfig `koanf:"conf"`
+}
+
+func parseMessagePrunerToolConfig(args []string) (*MessagePrunerToolConfig, error) {
+	f := flag.NewFlagSet("message-pruner", flag.ContinueOnError)
+	f.String("database", "", "path of the stopped node's arbitrumdata database, e.g. <data dir>/nitro/arbitrumdata")
+	f.String("chain-database", "", "path of the stopped node's l2chaindata database, e.g. <data dir>/nitro/l2chaindata, so messages it hasn't built blocks from are kept")
+	f.String("l1-url", "", "URL of the L1 node to read the latest confirmed rollup node from")
+	f.String("rollup", "", "address of the rollup contract")
+	f.Uint64("retention-batches", arbnode.DefaultMessagePrunerConfig.RetentionBatches, "number of the latest batches to keep, along with the messages and delayed messages they read")
+	f.Bool("dry-run", false, "only print how far the database would be pruned")
+	genericconf.ConfConfigAddOptions("conf", f)
+
+	k, err := confighelpers.BeginCommonParse(f, args)
+	if err != nil {
+		return nil, err
+	}
+
+	var config MessagePrunerToolConfig
+	if err := confighelpers.EndCommonParse(k, &config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+func main() {
+	if err := run(context.Background(), os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string) error {
+	config, err := parseMessagePrunerToolConfig(args)
+	if err != nil {
+		return err
+	}
+	if config.Database == "" || config.ChainDatabase == "" || config.L1URL == "" || !common.IsHexAddress(config.Rollup) {
+		return errors.New("--database, --chain-database, --l1-url and --rollup are required")
+	}
+
+	client, err := ethclient.DialContext(ctx, config.L1URL)
+	if err != nil {
+		return err
+	}
+	rollup, err := validator.NewRollupWatcher(common.HexToAddress(config.Rollup), client, bind.CallOpts{})
+	if err != nil {
+		return err
+	}
+	db, err := rawdb.NewLevelDBDatabase(config.Database, 0, 0, "", config.DryRun)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	chainDb, err := rawdb.NewLevelDBDatabase(config.ChainDatabase, 0, 0, "", true)
+	if err != nil {
+		return err
+	}
+	defer chainDb.Close()
+
+	prunerConfig := arbnode.DefaultMessagePrunerConfig
+	prunerConfig.RetentionBatches = config.RetentionBatches
+	pruner, err := arbnode.NewOfflineMessagePruner(db, chainDb, rollup, func() *arbnode.MessagePrunerConfig { return &prunerConfig })
+	if err != nil {
+		return err
+	}
+
+	if config.DryRun {
+		safe, err := pruner.SafeBatchCount(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("would prune the first %v batches\n", safe)
+		return nil
+	}
+	state, err := pruner.Prune(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("pruned up to batch count %v, message count %v, and delayed message count %v\n", state.BatchCount, state.MessageCount, state.DelayedCount)
+	return nil
+}
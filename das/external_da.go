@@ -0,0 +1,186 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package das
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	flag "github.com/spf13/pflag"
+
+	"github.com/offchainlabs/nitro/arbstate"
+)
+
+// ExternalDAConfig configures an experimental external data availability layer,
+// which the batch poster can publish batches to, and the inbox reader can resolve them from.
+type ExternalDAConfig struct {
+	Mode       string        `koanf:"mode"`
+	Directory  string        `koanf:"directory"`
+	URL        string        `koanf:"url"`
+	Timeout    time.Duration `koanf:"timeout"`
+	HeaderByte uint8         `koanf:"header-byte"`
+}
+
+var DefaultExternalDAConfig = ExternalDAConfig{
+	Mode:       "",
+	Timeout:    10 * time.Second,
+	HeaderByte: arbstate.ExternalDAMessageHeaderFlag,
+}
+
+func ExternalDAConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.String(prefix+".mode", DefaultExternalDAConfig.Mode, "EXPERIMENTAL: external data availability layer to use, either \"file\" or \"http\" (disabled if empty)")
+	f.String(prefix+".directory", DefaultExternalDAConfig.Directory, "directory batches are stored in, for the file mode")
+	f.String(prefix+".url", DefaultExternalDAConfig.URL, "URL of the server batches are stored in, for the http mode")
+	f.Duration(prefix+".timeout", DefaultExternalDAConfig.Timeout, "timeout for requests to the http server")
+	f.Uint8(prefix+".header-byte", DefaultExternalDAConfig.HeaderByte, "sequencer message header byte identifying batches stored in this layer, from 0x10 to 0x1f")
+}
+
+// ExternalDA stores batches in an external data availability layer, returning an opaque commitment to post on chain
+type ExternalDA interface {
+	arbstate.ExternalDAReader
+	Store(ctx context.Context, data []byte) (commitment []byte, err error)
+	fmt.Stringer
+}
+
+func NewExternalDA(config *ExternalDAConfig) (ExternalDA, error) {
+	if !arbstate.IsExternalDAMessageHeaderByte(config.HeaderByte) {
+		return nil, fmt.Errorf("invalid external data availability header byte %#x", config.HeaderByte)
+	}
+	switch config.Mode {
+	case "file":
+		return NewFileExternalDA(config.Directory)
+	case "http":
+		return NewHTTPExternalDA(config.URL, config.Timeout)
+	default:
+		return nil, fmt.Errorf("unknown external data availability mode \"%v\"", config.Mode)
+	}
+}
+
+// FileExternalDA stores each batch in a file named by its hash, so it can stand in for an external layer in tests
+type FileExternalDA struct {
+	directory string
+}
+
+func NewFileExternalDA(directory string) (*FileExternalDA, error) {
+	if directory == "" {
+		return nil, errors.New("file external data availability requires a directory")
+	}
+	if err := os.MkdirAll(directory, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileExternalDA{directory: directory}, nil
+}
+
+func (s *FileExternalDA) path(dataHash common.Hash) string {
+	return filepath.Join(s.directory, dataHash.Hex())
+}
+
+// Store writes the data, and returns an empty commitment, as the data hash identifies it
+func (s *FileExternalDA) Store(ctx context.Context, data []byte) ([]byte, error) {
+	path := s.path(crypto.Keccak256Hash(data))
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return nil, err
+	}
+	return []byte{}, os.Rename(tmpPath, path)
+}
+
+func (s *FileExternalDA) GetByCommitment(ctx context.Context, dataHash common.Hash, commitment []byte) ([]byte, error) {
+	data, err := os.ReadFile(s.path(dataHash))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (s *FileExternalDA) String() string {
+	return fmt.Sprintf("FileExternalDA(%v)", s.directory)
+}
+
+const externalDAStorePath = "/store"
+const externalDAGetPath = "/get/"
+
+// ExternalDAStoreResponse is returned by an external data availability server for a POST of raw batch data to /store
+type ExternalDAStoreResponse struct {
+	Commitment string `json:"commitment"` // base64 encoded
+}
+
+// ExternalDAGetResponse is returned by an external data availability server for a GET of /get/<hex commitment>
+type ExternalDAGetResponse struct {
+	Data string `json:"data"` // base64 encoded
+}
+
+// HTTPExternalDA talks to an external data availability server, or a local stand-in for one
+type HTTPExternalDA struct {
+	url    string
+	client *http.Client
+}
+
+func NewHTTPExternalDA(url string, timeout time.Duration) (*HTTPExternalDA, error) {
+	if !(strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://")) {
+		return nil, fmt.Errorf("protocol prefix 'http://' or 'https://' must be specified for HTTPExternalDA; got '%s'", url)
+	}
+	return &HTTPExternalDA{
+		url:    strings.TrimSuffix(url, "/"),
+		client: &http.Client{Timeout: timeout},
+	}, nil
+}
+
+func (s *HTTPExternalDA) do(req *http.Request, response interface{}) error {
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP error with status %d returned by server: %s", res.StatusCode, http.StatusText(res.StatusCode))
+	}
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, response)
+}
+
+func (s *HTTPExternalDA) Store(ctx context.Context, data []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url+externalDAStorePath, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	var response ExternalDAStoreResponse
+	if err := s.do(req, &response); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(response.Commitment)
+}
+
+func (s *HTTPExternalDA) GetByCommitment(ctx context.Context, dataHash common.Hash, commitment []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url+externalDAGetPath+hexutil.Encode(commitment), nil)
+	if err != nil {
+		return nil, err
+	}
+	var response ExternalDAGetResponse
+	if err := s.do(req, &response); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(response.Data)
+}
+
+func (s *HTTPExternalDA) String() string {
+	return fmt.Sprintf("HTTPExternalDA(%v)", s.url)
+}
@@ -0,0 +1,103 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package das
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// a local stand-in for an external data availability server, which uses sequential ids as commitments
+func newExternalDAStandInServer(t *testing.T) *httptest.Server {
+	var mutex sync.Mutex
+	var stored [][]byte
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		var response interface{}
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == externalDAStorePath:
+			data, err := io.ReadAll(r.Body)
+			Require(t, err)
+			stored = append(stored, data)
+			commitment := []byte{byte(len(stored) - 1)}
+			response = ExternalDAStoreResponse{Commitment: base64.StdEncoding.EncodeToString(commitment)}
+		case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, externalDAGetPath):
+			commitment, err := hexutil.Decode(strings.TrimPrefix(r.URL.Path, externalDAGetPath))
+			if err != nil || len(commitment) != 1 || int(commitment[0]) >= len(stored) {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			response = ExternalDAGetResponse{Data: base64.StdEncoding.EncodeToString(stored[commitment[0]])}
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		Require(t, json.NewEncoder(w).Encode(response))
+	}))
+}
+
+func testExternalDARoundTrip(t *testing.T, da ExternalDA) {
+	ctx := context.Background()
+	batches := [][]byte{[]byte("first batch"), []byte("second batch")}
+	var commitments [][]byte
+	for _, batch := range batches {
+		commitment, err := da.Store(ctx, batch)
+		Require(t, err)
+		commitments = append(commitments, commitment)
+	}
+	for i, batch := range batches {
+		data, err := da.GetByCommitment(ctx, crypto.Keccak256Hash(batch), commitments[i])
+		Require(t, err)
+		if !bytes.Equal(data, batch) {
+			Fail(t, da, "returned", string(data), "for batch", i)
+		}
+	}
+}
+
+func TestFileExternalDA(t *testing.T) {
+	da, err := NewExternalDA(&ExternalDAConfig{Mode: "file", Directory: t.TempDir(), HeaderByte: 0x11})
+	Require(t, err)
+	testExternalDARoundTrip(t, da)
+
+	_, err = da.GetByCommitment(context.Background(), common.Hash{1}, nil)
+	if err != ErrNotFound {
+		Fail(t, "expected not found error, got", err)
+	}
+}
+
+func TestHTTPExternalDA(t *testing.T) {
+	server := newExternalDAStandInServer(t)
+	defer server.Close()
+	da, err := NewExternalDA(&ExternalDAConfig{Mode: "http", URL: server.URL, Timeout: time.Second, HeaderByte: 0x10})
+	Require(t, err)
+	testExternalDARoundTrip(t, da)
+
+	_, err = da.GetByCommitment(context.Background(), common.Hash{}, []byte{100})
+	if err == nil {
+		Fail(t, "expected error fetching unknown commitment")
+	}
+}
+
+func TestExternalDAHeaderByte(t *testing.T) {
+	for _, header := range []byte{0x00, 0x08, 0x20, 0x80, 0x88} {
+		_, err := NewExternalDA(&ExternalDAConfig{Mode: "file", Directory: t.TempDir(), HeaderByte: header})
+		if err == nil {
+			Fail(t, "accepted header byte", header)
+		}
+	}
+}
@@ -539,6 +539,13 @@ func findBatchContainingBlock(node *arbnode.Node, genesis uint64, block uint64)
 		return 0, fmt.Errorf("%wblock %v is part of genesis", blockInGenesis, block)
 	}
 	pos := arbutil.BlockNumberToMessageCount(block, genesis) - 1
+	pruned, err := node.InboxTracker.GetPruneState()
+	if err != nil {
+		return 0, err
+	}
+	if pos < pruned.MessageCount {
+		return 0, fmt.Errorf("block %v was posted in one of the first %v batches, whose records were %w", block, pruned.BatchCount, arbnode.ErrPruned)
+	}
 	high, err := node.InboxTracker.GetBatchCount()
 	if err != nil {
 		return 0, err
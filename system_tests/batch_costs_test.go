@@ -0,0 +1,90 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbtest
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/offchainlabs/nitro/arbnode"
+	"github.com/offchainlabs/nitro/solgen/go/precompilesgen"
+	"github.com/offchainlabs/nitro/util/arbmath"
+)
+
+func TestBatchPostingCosts(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	conf := arbnode.ConfigDefaultL1Test()
+	conf.BatchCosts = arbnode.TestBatchCostTrackerConfig
+	l2info, node, l2client, l1info, _, l1client, l1stack := createTestNodeOnL1WithConfig(t, ctx, true, conf, nil, nil)
+	defer requireClose(t, l1stack)
+	defer node.StopAndWait()
+
+	l2info.GenerateAccount("User2")
+	tx := l2info.PrepareTx("Owner", "User2", l2info.TransferGas, common.Big1, nil)
+	Require(t, l2client.SendTransaction(ctx, tx))
+	_, err := EnsureTxSucceeded(ctx, l2client, tx)
+	Require(t, err)
+
+	// batch 0 is the init message, so wait for the batch with the transfer, and its report, to be recorded
+	for i := 120; ; i-- {
+		// advance L1, so the batch gets posted and its report gets sequenced
+		SendWaitTestTransactions(t, ctx, l1client, []*types.Transaction{
+			l1info.PrepareTx("Faucet", "User", 30000, big.NewInt(1e12), nil),
+		})
+		count, err := node.BatchCostTracker.RecordedBatchCount()
+		Require(t, err)
+		if count > 1 {
+			break
+		}
+		if i == 0 {
+			Fail(t, "batch posting cost wasn't recorded")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	cost, err := node.BatchCostTracker.GetBatchPostingCost(1)
+	Require(t, err)
+	meta, err := node.InboxTracker.GetBatchMetadata(1)
+	Require(t, err)
+	if cost.Accumulator != meta.Accumulator || cost.L1Block != meta.L1Block {
+		Fail(t, "cost doesn't match the batch metadata", cost, meta)
+	}
+	receipt, err := l1client.TransactionReceipt(ctx, cost.L1TxHash)
+	Require(t, err)
+	if receipt.GasUsed != cost.L1GasUsed || receipt.BlockNumber.Uint64() != cost.L1Block {
+		Fail(t, "cost doesn't match the batch's L1 receipt", cost)
+	}
+	if cost.L1Cost.Cmp(arbmath.BigMulByUint(cost.L1GasPrice, cost.L1GasUsed)) != 0 || cost.L1GasPrice.Sign() <= 0 {
+		Fail(t, "unexpected L1 cost", cost.L1Cost, "for gas price", cost.L1GasPrice)
+	}
+	if cost.DataLocation != arbnode.BatchSinkCalldata || cost.DataBytes == 0 || cost.L2Messages == 0 || cost.L2Transactions == 0 {
+		Fail(t, "unexpected batch contents", cost)
+	}
+
+	if !cost.HasReport || cost.ReportedDataGas == 0 {
+		Fail(t, "batch posting report wasn't linked to the cost", cost)
+	}
+	reportBlock, err := l2client.BlockByNumber(ctx, new(big.Int).SetUint64(cost.ReportL2Block))
+	Require(t, err)
+	if reportBlock.Transactions()[0].Type() != types.ArbitrumInternalTxType {
+		Fail(t, "report block doesn't start with an internal transaction")
+	}
+	arbGasInfo, err := precompilesgen.NewArbGasInfo(common.HexToAddress("0x6c"), l2client)
+	Require(t, err)
+	perBatchGas, err := arbGasInfo.GetPerBatchGasCharge(&bind.CallOpts{Context: ctx, BlockNumber: new(big.Int).SetUint64(cost.ReportL2Block - 1)})
+	Require(t, err)
+	expectedCredit := arbmath.BigMulByUint(cost.ReportedL1BaseFee, uint64(perBatchGas)+cost.ReportedDataGas)
+	if cost.L1PricingCredit.Cmp(expectedCredit) != 0 {
+		Fail(t, "L1 pricing credit", cost.L1PricingCredit, "expected", expectedCredit)
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbtest
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/offchainlabs/nitro/arbnode"
+)
+
+func TestBatchProvenance(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	l2info, node, l2client, l1info, _, l1client, l1stack := createTestNodeOnL1(t, ctx, true)
+	defer requireClose(t, l1stack)
+	defer node.StopAndWait()
+
+	l2info.GenerateAccount("User2")
+	tx := l2info.PrepareTx("Owner", "User2", l2info.TransferGas, common.Big1, nil)
+	Require(t, l2client.SendTransaction(ctx, tx))
+	receipt, err := EnsureTxSucceeded(ctx, l2client, tx)
+	Require(t, err)
+	l2Block := receipt.BlockNumber.Uint64()
+
+	rpcClient, err := node.Stack.Attach()
+	Require(t, err)
+	defer rpcClient.Close()
+
+	var provenance arbnode.BlockProvenance
+	for i := 60; ; i-- {
+		// advance L1, so the batch gets posted and read back
+		SendWaitTestTransactions(t, ctx, l1client, []*types.Transaction{
+			l1info.PrepareTx("Faucet", "User", 30000, big.NewInt(1e12), nil),
+		})
+		err = rpcClient.CallContext(ctx, &provenance, "arb_getBlockProvenance", l2Block)
+		if err == nil {
+			break
+		}
+		if i == 0 {
+			Require(t, err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	if provenance.L2Block != l2Block || provenance.Batch == nil {
+		Fail(t, "unexpected provenance", provenance)
+	}
+	batch := provenance.Batch
+	if provenance.MessageIndex < batch.FirstMessage || provenance.MessageIndex >= batch.MessageCount {
+		Fail(t, "message", provenance.MessageIndex, "not in batch message range", batch.FirstMessage, batch.MessageCount)
+	}
+	if l2Block < batch.FirstL2Block || l2Block > batch.LastL2Block {
+		Fail(t, "block", l2Block, "not in batch block range", batch.FirstL2Block, batch.LastL2Block)
+	}
+	if batch.DataLocation != arbnode.BatchSinkCalldata || batch.DASCertificate != nil {
+		Fail(t, "unexpected batch data location", batch.DataLocation)
+	}
+	if batch.L1Confirmations == 0 {
+		Fail(t, "batch has no L1 confirmations")
+	}
+	// no rollup node has been confirmed in this test
+	if provenance.Confirmed || batch.Confirmed {
+		Fail(t, "block unexpectedly confirmed")
+	}
+
+	l1Receipt, err := l1client.TransactionReceipt(ctx, batch.L1TxHash)
+	Require(t, err)
+	if l1Receipt.BlockNumber.Uint64() != batch.L1Block || l1Receipt.BlockHash != batch.L1BlockHash {
+		Fail(t, "batch tx was included in L1 block", l1Receipt.BlockNumber, l1Receipt.BlockHash, "not", batch.L1Block, batch.L1BlockHash)
+	}
+
+	var sameBatch arbnode.BatchInfo
+	Require(t, rpcClient.CallContext(ctx, &sameBatch, "arb_getBatch", batch.BatchNumber))
+	if sameBatch.L1TxHash != batch.L1TxHash || sameBatch.Accumulator != batch.Accumulator || sameBatch.MessageCount != batch.MessageCount {
+		Fail(t, "arb_getBatch returned", sameBatch, "but the block's provenance has", *batch)
+	}
+	meta, err := node.InboxTracker.GetBatchMetadata(batch.BatchNumber)
+	Require(t, err)
+	if meta.Accumulator != batch.Accumulator || meta.DelayedMessageCount != batch.DelayedMessageCount {
+		Fail(t, "batch info doesn't match the batch metadata", meta)
+	}
+}
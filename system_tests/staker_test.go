@@ -18,9 +18,11 @@ import (
 
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/offchainlabs/nitro/arbnode"
+	"github.com/offchainlabs/nitro/arbnode/dataposter"
 	"github.com/offchainlabs/nitro/arbutil"
 	"github.com/offchainlabs/nitro/solgen/go/rollupgen"
 	"github.com/offchainlabs/nitro/util/colors"
@@ -119,7 +121,7 @@ func stakerTestImpl(t *testing.T, faultyStaker bool, honestStakerInactive bool)
 		TargetMachineCount: 4,
 	}
 
-	valWalletA, err := validator.NewContractValidatorWallet(nil, l2nodeA.DeployInfo.ValidatorWalletCreator, l2nodeA.DeployInfo.Rollup, l2nodeA.L1Reader, &l1authA, 0, func(common.Address) {})
+	valWalletA, err := validator.NewContractValidatorWallet(nil, nil, l2nodeA.DeployInfo.ValidatorWalletCreator, l2nodeA.DeployInfo.Rollup, l2nodeA.L1Reader, &l1authA, 0, func(common.Address) {})
 	Require(t, err)
 	if honestStakerInactive {
 		valConfig.Strategy = "Defensive"
@@ -152,7 +154,7 @@ func stakerTestImpl(t *testing.T, faultyStaker bool, honestStakerInactive bool)
 	err = stakerA.Initialize(ctx)
 	Require(t, err)
 
-	valWalletB, err := validator.NewEoaValidatorWallet(l2nodeB.DeployInfo.Rollup, l2nodeB.L1Reader.Client(), &l1authB)
+	valWalletB, err := validator.NewEoaValidatorWallet(nil, l2nodeB.DeployInfo.Rollup, l2nodeB.L1Reader.Client(), &l1authB)
 	Require(t, err)
 	valConfig.Strategy = "MakeNodes"
 	statelessB, err := validator.NewStatelessBlockValidator(
@@ -180,7 +182,7 @@ func stakerTestImpl(t *testing.T, faultyStaker bool, honestStakerInactive bool)
 	err = stakerB.Initialize(ctx)
 	Require(t, err)
 
-	valWalletC, err := validator.NewContractValidatorWallet(nil, l2nodeA.DeployInfo.ValidatorWalletCreator, l2nodeA.DeployInfo.Rollup, l2nodeA.L1Reader, nil, 0, func(common.Address) {})
+	valWalletC, err := validator.NewContractValidatorWallet(nil, nil, l2nodeA.DeployInfo.ValidatorWalletCreator, l2nodeA.DeployInfo.Rollup, l2nodeA.L1Reader, nil, 0, func(common.Address) {})
 	Require(t, err)
 	valConfig.Strategy = "Watchtower"
 	stakerC, err := validator.NewStaker(
@@ -341,3 +343,57 @@ func stakerTestImpl(t *testing.T, faultyStaker bool, honestStakerInactive bool)
 func TestStakersCooperative(t *testing.T) {
 	stakerTestImpl(t, false, false)
 }
+
+func TestValidatorWalletCreatedByDataPoster(t *testing.T) {
+	t.Parallel()
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+	_, l2node, _, l1info, _, l1client, l1stack := createTestNodeOnL1(t, ctx, true)
+	defer requireClose(t, l1stack)
+	defer l2node.StopAndWait()
+
+	l1info.GenerateAccount("Validator")
+	TransferBalance(t, "Faucet", "Validator", big.NewInt(params.Ether), l1info, l1client, ctx)
+	l1auth := l1info.GetDefaultTransactOpts("Validator", ctx)
+
+	dataPosterConfig := validator.DefaultStakerDataPosterConfig
+	dataPosterConfig.UseDBStorage = false
+	dataPoster, err := validator.NewStakerDataPoster(nil, l2node.L1Reader, &l1auth, func() *dataposter.DataPosterConfig { return &dataPosterConfig })
+	Require(t, err)
+	walletCreator := l2node.DeployInfo.ValidatorWalletCreator
+	wallet, err := validator.NewContractValidatorWallet(dataPoster, nil, walletCreator, l2node.DeployInfo.Rollup, l2node.L1Reader, &l1auth, 0, func(common.Address) {})
+	Require(t, err)
+	Require(t, wallet.Initialize(ctx))
+
+	builder, err := validator.NewValidatorTxBuilder(wallet)
+	Require(t, err)
+	Require(t, builder.SendTransaction(ctx, types.NewTx(&types.LegacyTx{To: &l2node.DeployInfo.Rollup, Value: common.Big0})))
+	tx, err := wallet.ExecuteTransactions(ctx, builder, common.Address{})
+	Require(t, err)
+	if tx == nil || *tx.To() != walletCreator || wallet.Address() != nil {
+		Fail(t, "executing transactions without a wallet didn't queue its creation", tx)
+	}
+	pending, err := dataPoster.HasPendingTransactions(ctx)
+	Require(t, err)
+	if !pending {
+		Fail(t, "wallet creation wasn't queued with the data poster")
+	}
+
+	_, err = EnsureTxSucceeded(ctx, l1client, tx)
+	Require(t, err)
+	pending, err = dataPoster.HasPendingTransactions(ctx)
+	Require(t, err)
+	if pending {
+		Fail(t, "data poster still has a pending transaction after the wallet was created")
+	}
+	created, err := validator.GetValidatorWalletContract(ctx, walletCreator, 0, &l1auth, l2node.L1Reader, false)
+	Require(t, err)
+	if created == nil {
+		Fail(t, "validator wallet wasn't created")
+	}
+	// The wallet finds its creation log from then on
+	Require(t, wallet.Initialize(ctx))
+	if wallet.Address() == nil || *wallet.Address() != *created {
+		Fail(t, "wallet has address", wallet.Address(), "instead of the created", *created)
+	}
+}
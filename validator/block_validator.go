@@ -19,6 +19,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/offchainlabs/nitro/arbstate"
@@ -836,6 +837,25 @@ func (v *BlockValidator) writeLastValidatedToDb(blockNumber uint64, blockHash co
 	return nil
 }
 
+// ReadLastValidatedPosition reads the global state position after the last block recorded as valid in the block validator's database.
+// It returns false if no block has been recorded as valid.
+func ReadLastValidatedPosition(db ethdb.KeyValueReader) (GlobalStatePosition, bool, error) {
+	exists, err := db.Has(lastBlockValidatedInfoKey)
+	if err != nil || !exists {
+		return GlobalStatePosition{}, false, err
+	}
+	infoBytes, err := db.Get(lastBlockValidatedInfoKey)
+	if err != nil {
+		return GlobalStatePosition{}, false, err
+	}
+	var info lastBlockValidatedDbInfo
+	err = rlp.DecodeBytes(infoBytes, &info)
+	if err != nil {
+		return GlobalStatePosition{}, false, err
+	}
+	return info.AfterPosition, true, nil
+}
+
 func (v *BlockValidator) progressValidated() {
 	v.reorgMutex.Lock()
 	defer v.reorgMutex.Unlock()
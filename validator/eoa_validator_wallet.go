@@ -6,6 +6,7 @@ package validator
 import (
 	"context"
 
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -20,15 +21,18 @@ type EoaValidatorWallet struct {
 	rollupAddress           common.Address
 	challengeManager        *challengegen.ChallengeManager
 	challengeManagerAddress common.Address
+	dataPoster              *StakerDataPoster
 }
 
 var _ ValidatorWalletInterface = (*EoaValidatorWallet)(nil)
 
-func NewEoaValidatorWallet(rollupAddress common.Address, l1Client arbutil.L1Interface, auth *bind.TransactOpts) (*EoaValidatorWallet, error) {
+// The data poster is optional. If it's nil, transactions are sent directly, and aren't replaced by fee.
+func NewEoaValidatorWallet(dataPoster *StakerDataPoster, rollupAddress common.Address, l1Client arbutil.L1Interface, auth *bind.TransactOpts) (*EoaValidatorWallet, error) {
 	return &EoaValidatorWallet{
 		auth:          auth,
 		client:        l1Client,
 		rollupAddress: rollupAddress,
+		dataPoster:    dataPoster,
 	}, nil
 }
 
@@ -80,6 +84,9 @@ func (w *EoaValidatorWallet) ExecuteTransactions(ctx context.Context, builder *V
 		return nil, nil
 	}
 	tx := builder.transactions[0] // we ignore future txs and only execute the first
+	if w.dataPoster != nil {
+		return postStakerTx(ctx, w.dataPoster, *tx.To(), tx.Data(), tx.Value(), tx.Gas(), stakerTxMetaFor(builder.transactions[:1]))
+	}
 	err := w.client.SendTransaction(ctx, tx)
 	return tx, err
 }
@@ -88,6 +95,21 @@ func (w *EoaValidatorWallet) TimeoutChallenges(ctx context.Context, timeouts []u
 	if len(timeouts) == 0 {
 		return nil, nil
 	}
+	if w.dataPoster != nil {
+		calldata, err := challengeManagerABI.Pack("timeout", timeouts[0])
+		if err != nil {
+			return nil, err
+		}
+		gas, err := w.client.EstimateGas(ctx, ethereum.CallMsg{
+			From: w.auth.From,
+			To:   &w.challengeManagerAddress,
+			Data: calldata,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return postStakerTx(ctx, w.dataPoster, w.challengeManagerAddress, calldata, nil, gas, StakerTxMeta{Methods: []string{"timeout"}})
+	}
 	auth := *w.auth
 	auth.Context = ctx
 	return w.challengeManager.Timeout(&auth, timeouts[0])
@@ -100,3 +122,7 @@ func (w *EoaValidatorWallet) CanBatchTxs() bool {
 func (v *EoaValidatorWallet) AuthIfEoa() *bind.TransactOpts {
 	return v.auth
 }
+
+func (v *EoaValidatorWallet) DataPoster() *StakerDataPoster {
+	return v.dataPoster
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/pkg/errors"
 	flag "github.com/spf13/pflag"
 
+	"github.com/offchainlabs/nitro/arbnode/dataposter"
 	"github.com/offchainlabs/nitro/util/stopwaiter"
 )
 
@@ -51,20 +52,21 @@ func L1PostingStrategyAddOptions(prefix string, f *flag.FlagSet) {
 }
 
 type L1ValidatorConfig struct {
-	Enable                   bool              `koanf:"enable"`
-	Strategy                 string            `koanf:"strategy"`
-	StakerInterval           time.Duration     `koanf:"staker-interval"`
-	MakeAssertionInterval    time.Duration     `koanf:"make-assertion-interval"`
-	L1PostingStrategy        L1PostingStrategy `koanf:"posting-strategy"`
-	DisableChallenge         bool              `koanf:"disable-challenge"`
-	TargetMachineCount       int               `koanf:"target-machine-count"`
-	ConfirmationBlocks       int64             `koanf:"confirmation-blocks"`
-	UseSmartContractWallet   bool              `koanf:"use-smart-contract-wallet"`
-	OnlyCreateWalletContract bool              `koanf:"only-create-wallet-contract"`
-	StartFromStaked          bool              `koanf:"start-validation-from-staked"`
-	ContractWalletAddress    string            `koanf:"contract-wallet-address"`
-	GasRefunderAddress       string            `koanf:"gas-refunder-address"`
-	Dangerous                DangerousConfig   `koanf:"dangerous"`
+	Enable                   bool                        `koanf:"enable"`
+	Strategy                 string                      `koanf:"strategy"`
+	StakerInterval           time.Duration               `koanf:"staker-interval"`
+	MakeAssertionInterval    time.Duration               `koanf:"make-assertion-interval"`
+	L1PostingStrategy        L1PostingStrategy           `koanf:"posting-strategy"`
+	DisableChallenge         bool                        `koanf:"disable-challenge"`
+	TargetMachineCount       int                         `koanf:"target-machine-count"`
+	ConfirmationBlocks       int64                       `koanf:"confirmation-blocks"`
+	UseSmartContractWallet   bool                        `koanf:"use-smart-contract-wallet"`
+	OnlyCreateWalletContract bool                        `koanf:"only-create-wallet-contract"`
+	StartFromStaked          bool                        `koanf:"start-validation-from-staked"`
+	ContractWalletAddress    string                      `koanf:"contract-wallet-address"`
+	GasRefunderAddress       string                      `koanf:"gas-refunder-address"`
+	DataPoster               dataposter.DataPosterConfig `koanf:"data-poster"`
+	Dangerous                DangerousConfig             `koanf:"dangerous"`
 }
 
 var DefaultL1ValidatorConfig = L1ValidatorConfig{
@@ -81,6 +83,7 @@ var DefaultL1ValidatorConfig = L1ValidatorConfig{
 	StartFromStaked:          true,
 	ContractWalletAddress:    "",
 	GasRefunderAddress:       "",
+	DataPoster:               DefaultStakerDataPosterConfig,
 	Dangerous:                DefaultDangerousConfig,
 }
 
@@ -98,6 +101,7 @@ func L1ValidatorConfigAddOptions(prefix string, f *flag.FlagSet) {
 	f.Bool(prefix+".start-validation-from-staked", DefaultL1ValidatorConfig.StartFromStaked, "assume staked nodes are valid")
 	f.String(prefix+".contract-wallet-address", DefaultL1ValidatorConfig.ContractWalletAddress, "validator smart contract wallet public address")
 	f.String(prefix+".gas-refunder-address", DefaultL1ValidatorConfig.GasRefunderAddress, "The gas refunder contract address (optional)")
+	dataposter.DataPosterConfigAddOptions(prefix+".data-poster", f, DefaultL1ValidatorConfig.DataPoster)
 	DangerousConfigAddOptions(prefix+".dangerous", f)
 }
 
@@ -213,6 +217,9 @@ func (s *Staker) Initialize(ctx context.Context) error {
 
 func (s *Staker) Start(ctxIn context.Context) {
 	s.StopWaiter.Start(ctxIn, s)
+	if dataPoster := s.wallet.DataPoster(); dataPoster != nil {
+		dataPoster.Start(ctxIn)
+	}
 	backoff := time.Second
 	s.CallIteratively(func(ctx context.Context) time.Duration {
 		err := s.updateBlockValidatorModuleRoot(ctx)
@@ -220,7 +227,10 @@ func (s *Staker) Start(ctxIn context.Context) {
 			log.Warn("error updating latest wasm module root", "err", err)
 		}
 		arbTx, err := s.Act(ctx)
-		if err == nil && arbTx != nil {
+		if err == nil && arbTx != nil && s.wallet.DataPoster() != nil {
+			// The data poster replaces the transaction by fee as needed, and Act waits for it to be confirmed
+			log.Info("queued staker transaction", "hash", arbTx.Hash(), "nonce", arbTx.Nonce())
+		} else if err == nil && arbTx != nil {
 			_, err = s.l1Reader.WaitForTxApproval(ctx, arbTx)
 			err = errors.Wrap(err, "error waiting for tx receipt")
 			if err == nil {
@@ -246,6 +256,13 @@ func (s *Staker) Start(ctxIn context.Context) {
 	})
 }
 
+func (s *Staker) StopAndWait() {
+	s.StopWaiter.StopAndWait()
+	if dataPoster := s.wallet.DataPoster(); dataPoster != nil && dataPoster.Started() {
+		dataPoster.StopAndWait()
+	}
+}
+
 func (s *Staker) IsWhitelisted(ctx context.Context) (bool, error) {
 	callOpts := s.getCallOpts(ctx)
 	whitelistDisabled, err := s.rollup.ValidatorWhitelistDisabled(callOpts)
@@ -321,6 +338,17 @@ func (s *Staker) Act(ctx context.Context) (*types.Transaction, error) {
 			log.Warn("validator address isn't whitelisted", "address", s.wallet.Address(), "txSender", s.wallet.TxSenderAddress())
 		}
 	}
+	if dataPoster := s.wallet.DataPoster(); dataPoster != nil {
+		pending, err := dataPoster.HasPendingTransactions(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if pending {
+			// The rollup state we'd act on doesn't reflect our queued transaction yet
+			log.Info("waiting for queued staker transaction to be confirmed")
+			return nil, nil
+		}
+	}
 	if !s.shouldAct(ctx) {
 		// The fact that we're delaying acting is alreay logged in `shouldAct`
 		return nil, nil
@@ -0,0 +1,101 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package validator
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/offchainlabs/nitro/arbnode/dataposter"
+	"github.com/offchainlabs/nitro/solgen/go/challengegen"
+	"github.com/offchainlabs/nitro/solgen/go/rollupgen"
+	"github.com/offchainlabs/nitro/util/headerreader"
+)
+
+var rollupUserLogicABI abi.ABI
+var challengeManagerABI abi.ABI
+
+func init() {
+	parsedRollup, err := abi.JSON(strings.NewReader(rollupgen.RollupUserLogicABI))
+	if err != nil {
+		panic(err)
+	}
+	rollupUserLogicABI = parsedRollup
+
+	parsedChallengeManager, err := abi.JSON(strings.NewReader(challengegen.ChallengeManagerABI))
+	if err != nil {
+		panic(err)
+	}
+	challengeManagerABI = parsedChallengeManager
+}
+
+// StakerTxMeta is stored alongside each transaction queued by the staker's data poster
+type StakerTxMeta struct {
+	Methods []string // the rollup and challenge manager methods the transaction calls
+}
+
+type StakerDataPoster = dataposter.DataPoster[StakerTxMeta]
+
+var DefaultStakerDataPosterConfig = func() dataposter.DataPosterConfig {
+	config := dataposter.DefaultDataPosterConfig
+	// The staker has no redis, so keep its queue across restarts in the database
+	config.UseDBStorage = true
+	return config
+}()
+
+// stakerDataPosterLock is always held, as a staker's data poster is never shared between nodes
+type stakerDataPosterLock struct{}
+
+func (stakerDataPosterLock) AttemptLock(context.Context) bool {
+	return true
+}
+
+func NewStakerDataPoster(db ethdb.Database, l1Reader *headerreader.HeaderReader, auth *bind.TransactOpts, config dataposter.DataPosterConfigFetcher) (*StakerDataPoster, error) {
+	// Staker transactions are independent, so there's nothing to retrieve from L1
+	metadataRetriever := func(ctx context.Context, blockNum *big.Int) (StakerTxMeta, error) {
+		return StakerTxMeta{}, nil
+	}
+	return dataposter.NewDataPoster(db, l1Reader, auth, nil, stakerDataPosterLock{}, config, metadataRetriever)
+}
+
+func describeCall(data []byte) string {
+	if len(data) < 4 {
+		return "transfer"
+	}
+	for _, contractABI := range []*abi.ABI{&rollupUserLogicABI, &challengeManagerABI, &validatorABI} {
+		method, err := contractABI.MethodById(data[:4])
+		if err == nil {
+			return method.Name
+		}
+	}
+	return hexutil.Encode(data[:4])
+}
+
+func stakerTxMetaFor(txs []*types.Transaction) StakerTxMeta {
+	methods := make([]string, 0, len(txs))
+	for _, tx := range txs {
+		methods = append(methods, describeCall(tx.Data()))
+	}
+	return StakerTxMeta{Methods: methods}
+}
+
+// postStakerTx queues a transaction with the staker's data poster, which replaces it by fee until it's confirmed
+func postStakerTx(ctx context.Context, dataPoster *StakerDataPoster, to common.Address, calldata []byte, value *big.Int, gasLimit uint64, meta StakerTxMeta) (*types.Transaction, error) {
+	nonce, _, err := dataPoster.GetNextNonceAndMeta(ctx)
+	if err != nil {
+		return nil, err
+	}
+	log.Info("queueing staker transaction", "nonce", nonce, "to", to, "methods", meta.Methods)
+	return dataPoster.PostTransaction(ctx, time.Now(), nonce, meta, to, calldata, gasLimit, value)
+}
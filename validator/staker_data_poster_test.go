@@ -0,0 +1,40 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package validator
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+func TestStakerTxMeta(t *testing.T) {
+	confirmData, err := rollupUserLogicABI.Pack("confirmNextNode", common.Hash{1}, common.Hash{2})
+	Require(t, err)
+	timeoutData, err := challengeManagerABI.Pack("timeout", uint64(3))
+	Require(t, err)
+	to := common.Address{4}
+	var txs []*types.Transaction
+	for _, data := range [][]byte{confirmData, timeoutData, {}, {0xde, 0xad, 0xbe, 0xef}} {
+		txs = append(txs, types.NewTx(&types.DynamicFeeTx{To: &to, Value: new(big.Int), Data: data}))
+	}
+
+	meta := stakerTxMetaFor(txs)
+	expected := []string{"confirmNextNode", "timeout", "transfer", "0xdeadbeef"}
+	if !reflect.DeepEqual(meta.Methods, expected) {
+		Fail(t, "unexpected methods", meta.Methods, "expected", expected)
+	}
+
+	encoded, err := rlp.EncodeToBytes(meta)
+	Require(t, err)
+	var decoded StakerTxMeta
+	Require(t, rlp.DecodeBytes(encoded, &decoded))
+	if !reflect.DeepEqual(decoded, meta) {
+		Fail(t, "meta changed when RLP encoded", decoded, "expected", meta)
+	}
+}
@@ -50,6 +50,7 @@ type InboxTrackerInterface interface {
 	GetBatchMessageCount(seqNum uint64) (arbutil.MessageIndex, error)
 	GetBatchAcc(seqNum uint64) (common.Hash, error)
 	GetBatchCount() (uint64, error)
+	GetPrunedBatchCount() (uint64, error)
 }
 
 type TransactionStreamerInterface interface {
@@ -107,7 +108,20 @@ func GlobalStatePositionsFor(
 func FindBatchContainingMessageIndex(
 	tracker InboxTrackerInterface, pos arbutil.MessageIndex, high uint64,
 ) (uint64, error) {
-	var low uint64
+	low, err := tracker.GetPrunedBatchCount()
+	if err != nil {
+		return 0, err
+	}
+	if low > 0 {
+		// the message count of the last pruned batch is kept to search from
+		prunedCount, err := tracker.GetBatchMessageCount(low - 1)
+		if err != nil {
+			return 0, err
+		}
+		if pos < prunedCount {
+			return 0, fmt.Errorf("message %v is part of the first %v batches, which were pruned", pos, low)
+		}
+	}
 	// Iteration preconditions:
 	// - high >= low
 	// - msgCount(low - 1) <= pos implies low <= target
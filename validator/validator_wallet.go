@@ -20,6 +20,7 @@ import (
 )
 
 var validatorABI abi.ABI
+var validatorWalletCreatorABI abi.ABI
 var walletCreatedID common.Hash
 
 func init() {
@@ -33,6 +34,7 @@ func init() {
 	if err != nil {
 		panic(err)
 	}
+	validatorWalletCreatorABI = parsedValidatorWalletCreator
 	walletCreatedID = parsedValidatorWalletCreator.Events["WalletCreated"].ID
 }
 
@@ -49,6 +51,7 @@ type ValidatorWalletInterface interface {
 	TimeoutChallenges(context.Context, []uint64) (*types.Transaction, error)
 	CanBatchTxs() bool
 	AuthIfEoa() *bind.TransactOpts
+	DataPoster() *StakerDataPoster
 }
 
 type ContractValidatorWallet struct {
@@ -62,11 +65,13 @@ type ContractValidatorWallet struct {
 	rollup                  *rollupgen.RollupUserLogic
 	rollupAddress           common.Address
 	challengeManagerAddress common.Address
+	dataPoster              *StakerDataPoster
 }
 
 var _ ValidatorWalletInterface = (*ContractValidatorWallet)(nil)
 
-func NewContractValidatorWallet(address *common.Address, walletFactoryAddr, rollupAddress common.Address, l1Reader L1ReaderInterface, auth *bind.TransactOpts, rollupFromBlock int64, onWalletCreated func(common.Address)) (*ContractValidatorWallet, error) {
+// The data poster is optional. If it's nil, transactions are sent directly, and aren't replaced by fee.
+func NewContractValidatorWallet(dataPoster *StakerDataPoster, address *common.Address, walletFactoryAddr, rollupAddress common.Address, l1Reader L1ReaderInterface, auth *bind.TransactOpts, rollupFromBlock int64, onWalletCreated func(common.Address)) (*ContractValidatorWallet, error) {
 	var con *rollupgen.ValidatorWallet
 	if address != nil {
 		var err error
@@ -89,6 +94,7 @@ func NewContractValidatorWallet(address *common.Address, walletFactoryAddr, roll
 		rollupAddress:     rollupAddress,
 		rollup:            rollup,
 		rollupFromBlock:   rollupFromBlock,
+		dataPoster:        dataPoster,
 	}, nil
 }
 
@@ -152,7 +158,49 @@ func (v *ContractValidatorWallet) From() common.Address {
 	return v.auth.From
 }
 
+// postTransaction queues a call to the wallet contract with the staker's data poster
+func (v *ContractValidatorWallet) postTransaction(ctx context.Context, calldata []byte, value *big.Int, meta StakerTxMeta) (*types.Transaction, error) {
+	gas, err := v.l1Reader.Client().EstimateGas(ctx, ethereum.CallMsg{
+		From:  v.auth.From,
+		To:    v.address,
+		Value: value,
+		Data:  calldata,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error estimating gas for validator wallet transaction")
+	}
+	return postStakerTx(ctx, v.dataPoster, *v.address, calldata, value, gas, meta)
+}
+
+// queueWalletCreation queues the creation of the validator smart contract wallet with the staker's data poster
+func (v *ContractValidatorWallet) queueWalletCreation(ctx context.Context) (*types.Transaction, error) {
+	if v.auth == nil {
+		return nil, errors.New("cannot create validator smart contract wallet without key wallet")
+	}
+	var initialExecutorAllowedDests []common.Address
+	calldata, err := validatorWalletCreatorABI.Pack("createWallet", initialExecutorAllowedDests)
+	if err != nil {
+		return nil, err
+	}
+	gas, err := v.l1Reader.Client().EstimateGas(ctx, ethereum.CallMsg{
+		From: v.auth.From,
+		To:   &v.walletFactoryAddr,
+		Data: calldata,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error estimating gas for validator wallet creation")
+	}
+	return postStakerTx(ctx, v.dataPoster, v.walletFactoryAddr, calldata, nil, gas, StakerTxMeta{Methods: []string{"createWallet"}})
+}
+
 func (v *ContractValidatorWallet) executeTransaction(ctx context.Context, tx *types.Transaction, gasRefunder common.Address) (*types.Transaction, error) {
+	if v.dataPoster != nil {
+		calldata, err := validatorABI.Pack("executeTransactionWithGasRefunder", gasRefunder, tx.Data(), *tx.To(), tx.Value())
+		if err != nil {
+			return nil, err
+		}
+		return v.postTransaction(ctx, calldata, tx.Value(), stakerTxMetaFor([]*types.Transaction{tx}))
+	}
 	oldAuthValue := v.auth.Value
 	v.auth.Value = tx.Value()
 	defer (func() { v.auth.Value = oldAuthValue })()
@@ -217,10 +265,15 @@ func (v *ContractValidatorWallet) ExecuteTransactions(ctx context.Context, build
 		return nil, nil
 	}
 
-	err := v.populateWallet(ctx, true)
+	// The data poster can't wait for the wallet to be created, so its creation is queued below instead
+	err := v.populateWallet(ctx, v.dataPoster == nil)
 	if err != nil {
 		return nil, err
 	}
+	if v.address == nil {
+		// The wallet is found by its creation log once this is confirmed, and the transactions are retried then
+		return v.queueWalletCreation(ctx)
+	}
 
 	if len(txes) == 1 {
 		arbTx, err := v.executeTransaction(ctx, txes[0], gasRefunder)
@@ -248,22 +301,46 @@ func (v *ContractValidatorWallet) ExecuteTransactions(ctx context.Context, build
 		return nil, err
 	}
 
-	oldAuthValue := v.auth.Value
-	v.auth.Value = new(big.Int).Sub(totalAmount, balanceInContract)
-	if v.auth.Value.Sign() < 0 {
-		v.auth.Value.SetInt64(0)
+	callValue := new(big.Int).Sub(totalAmount, balanceInContract)
+	if callValue.Sign() < 0 {
+		callValue.SetInt64(0)
 	}
-	defer (func() { v.auth.Value = oldAuthValue })()
 
-	arbTx, err := v.con.ExecuteTransactionsWithGasRefunder(v.auth, gasRefunder, data, dest, amount)
-	if err != nil {
-		return nil, err
+	var arbTx *types.Transaction
+	if v.dataPoster != nil {
+		calldata, err := validatorABI.Pack("executeTransactionsWithGasRefunder", gasRefunder, data, dest, amount)
+		if err != nil {
+			return nil, err
+		}
+		arbTx, err = v.postTransaction(ctx, calldata, callValue, stakerTxMetaFor(txes))
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		oldAuthValue := v.auth.Value
+		v.auth.Value = callValue
+		defer (func() { v.auth.Value = oldAuthValue })()
+
+		arbTx, err = v.con.ExecuteTransactionsWithGasRefunder(v.auth, gasRefunder, data, dest, amount)
+		if err != nil {
+			return nil, err
+		}
 	}
 	builder.transactions = nil
 	return arbTx, nil
 }
 
 func (v *ContractValidatorWallet) TimeoutChallenges(ctx context.Context, challenges []uint64) (*types.Transaction, error) {
+	if v.dataPoster != nil {
+		if v.address == nil {
+			return nil, errors.New("cannot timeout challenges without a validator smart contract wallet")
+		}
+		calldata, err := validatorABI.Pack("timeoutChallenges", v.challengeManagerAddress, challenges)
+		if err != nil {
+			return nil, err
+		}
+		return v.postTransaction(ctx, calldata, nil, StakerTxMeta{Methods: []string{"timeoutChallenges"}})
+	}
 	return v.con.TimeoutChallenges(v.auth, v.challengeManagerAddress, challenges)
 }
 
@@ -306,6 +383,10 @@ func (v *ContractValidatorWallet) AuthIfEoa() *bind.TransactOpts {
 	return nil
 }
 
+func (v *ContractValidatorWallet) DataPoster() *StakerDataPoster {
+	return v.dataPoster
+}
+
 func GetValidatorWalletContract(
 	ctx context.Context,
 	validatorWalletFactoryAddr common.Address,